@@ -7,13 +7,19 @@ import (
 	"fmt"
 	"math"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/cmd/api/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/audit"
 	internalDTO "github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -22,20 +28,65 @@ type CreateTicketPayload struct {
 	CategoryID    *int64  `json:"category_id,omitempty"`
 	NoSerial      *string `json:"no_serial,omitempty"`
 	OrderNumber   *string `json:"order_number,omitempty"`
-	OrderStage    *string `json:"order_stage,omitempty"`
 	Capex         *string `json:"capex,omitempty"`
 	InvoiceNumber *string `json:"invoice_number,omitempty"`
 	Supplier      *string `json:"supplier,omitempty"`
 }
 
+// fields returns the JSON names of the optional fields set in the payload.
+func (p CreateTicketPayload) fields() []string {
+	return setFields(map[string]bool{
+		"no_serial":      p.NoSerial != nil,
+		"order_number":   p.OrderNumber != nil,
+		"capex":          p.Capex != nil,
+		"invoice_number": p.InvoiceNumber != nil,
+		"supplier":       p.Supplier != nil,
+	})
+}
+
+type TransitionPayload struct {
+	To string `json:"to" validate:"required"`
+}
+
 type UpdateTicketPayload struct {
 	OrderNumber   *string `json:"order_number,omitempty" validate:"omitempty,max=100"`
-	OrderStage    *string `json:"order_stage,omitempty" validate:"omitempty,max=100"`
 	Capex         *string `json:"capex,omitempty" validate:"omitempty,max=50"`
 	InvoiceNumber *string `json:"invoice_number,omitempty" validate:"omitempty,max=50"`
 	Supplier      *string `json:"supplier,omitempty" validate:"omitempty,max=100"`
 }
 
+func (p UpdateTicketPayload) fields() []string {
+	return setFields(map[string]bool{
+		"order_number":   p.OrderNumber != nil,
+		"capex":          p.Capex != nil,
+		"invoice_number": p.InvoiceNumber != nil,
+		"supplier":       p.Supplier != nil,
+	})
+}
+
+func upsertFields(dtos []internalDTO.TicketUpsertDTO) []string {
+	set := make(map[string]bool)
+	for _, d := range dtos {
+		set["no_serial"] = set["no_serial"] || d.NoSerial != nil
+		set["order_number"] = set["order_number"] || d.OrderNumber != nil
+		set["capex"] = set["capex"] || d.Capex != nil
+		set["invoice_number"] = set["invoice_number"] || d.InvoiceNumber != nil
+		set["supplier"] = set["supplier"] || d.Supplier != nil
+	}
+	return setFields(set)
+}
+
+func setFields(set map[string]bool) []string {
+	var fields []string
+	for f, ok := range set {
+		if ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
 func (app *application) createAssetReplacementTicketHandler(w http.ResponseWriter, r *http.Request) {
 	app.logger.Info("POST /v1/asset-replacement-tickets recibido")
 
@@ -54,6 +105,10 @@ func (app *application) createAssetReplacementTicketHandler(w http.ResponseWrite
 		return
 	}
 
+	if !app.authorizeFields(w, r, payload.fields()) {
+		return
+	}
+
 	t := &store.AssetReplacementTicket{
 		TicketID:      payload.TicketID,
 		CategoryID:    store.SqlInt64(payload.CategoryID),
@@ -62,17 +117,19 @@ func (app *application) createAssetReplacementTicketHandler(w http.ResponseWrite
 		Capex:         store.SqlString(payload.Capex),
 		InvoiceNumber: store.SqlString(payload.InvoiceNumber),
 		Supplier:      store.SqlString(payload.Supplier),
+		StageProcess:  sql.NullString{String: workflow.Initial.String(), Valid: true},
 	}
 
 	ctx := r.Context()
 	if err := app.store.Tickets.Create(ctx, t); err != nil {
 		app.logger.Errorw("Error creando ticket", "error", err)
 
-		app.internalServerError(w, r, err)
+		app.errorResponse(w, r, err)
 		return
 	}
 	app.logger.Infof("Ticket creado: %+v", t)
 
+	w.Header().Set("ETag", ticketETag(t))
 	_ = app.jsonResponse(w, http.StatusCreated, dto.FromEntity(t))
 }
 
@@ -87,18 +144,20 @@ func (app *application) getAssetReplacementTicketHandler(w http.ResponseWriter,
 	ctx := r.Context()
 	ticket, err := app.store.Tickets.GetByID(ctx, id)
 	if err != nil {
-		switch {
-		case errors.Is(err, store.ErrNotFound):
-			app.notFoundResponse(w, r, err)
-		default:
-			app.internalServerError(w, r, err)
-		}
+		app.errorResponse(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", ticketETag(ticket))
+	if etagMatches(r.Header.Values("If-None-Match"), ticketETag(ticket), true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	_ = app.jsonResponse(w, http.StatusOK, dto.FromEntity(ticket))
 }
 
+// updateAssetReplacementTicketHandler applies the payload to the ticket if
+// it is still the version named by If-Match, see checkIfMatch.
 func (app *application) updateAssetReplacementTicketHandler(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "ticketID")
 	id, err := strconv.ParseInt(idParam, 10, 64)
@@ -117,10 +176,17 @@ func (app *application) updateAssetReplacementTicketHandler(w http.ResponseWrite
 		return
 	}
 
+	if !app.authorizeFields(w, r, payload.fields()) {
+		return
+	}
+
 	ctx := r.Context()
 	t, err := app.store.Tickets.GetByID(ctx, id)
 	if err != nil {
-		app.notFoundResponse(w, r, err)
+		app.errorResponse(w, r, err)
+		return
+	}
+	if !app.checkIfMatch(w, r, t) {
 		return
 	}
 
@@ -130,12 +196,23 @@ func (app *application) updateAssetReplacementTicketHandler(w http.ResponseWrite
 	if payload.Capex != nil {
 		t.Capex = store.SqlString(payload.Capex)
 	}
+	if payload.InvoiceNumber != nil {
+		t.InvoiceNumber = store.SqlString(payload.InvoiceNumber)
+	}
+	if payload.Supplier != nil {
+		t.Supplier = store.SqlString(payload.Supplier)
+	}
 
 	if err := app.store.Tickets.Update(ctx, t); err != nil {
-		app.internalServerError(w, r, err)
+		if errors.Is(err, store.ErrVersionMismatch) {
+			app.ticketChangedResponse(w, r, id)
+			return
+		}
+		app.errorResponse(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", ticketETag(t))
 	_ = app.jsonResponse(w, http.StatusOK, dto.FromEntity(t))
 }
 
@@ -148,114 +225,531 @@ func (app *application) deleteAssetReplacementTicketHandler(w http.ResponseWrite
 	}
 
 	ctx := r.Context()
-	if err := app.store.Tickets.Delete(ctx, id); err != nil {
-		switch {
-		case errors.Is(err, store.ErrNotFound):
-			app.notFoundResponse(w, r, err)
-		default:
-			app.internalServerError(w, r, err)
+	t, err := app.store.Tickets.GetByID(ctx, id)
+	if err != nil {
+		app.errorResponse(w, r, err)
+		return
+	}
+	if !app.checkIfMatch(w, r, t) {
+		return
+	}
+
+	if err := app.store.Tickets.Delete(ctx, id, t.Version); err != nil {
+		if errors.Is(err, store.ErrVersionMismatch) {
+			app.ticketChangedResponse(w, r, id)
+			return
 		}
+		app.errorResponse(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (app *application) getAllAssetReplacementTicketsHandler(w http.ResponseWriter, r *http.Request) {
-	stageParam := r.URL.Query().Get("stage")
-	pageParam := r.URL.Query().Get("page")
-	limitParam := r.URL.Query().Get("limit")
+// errTicketChanged answers a write on an outdated version of a ticket.
+var errTicketChanged = apierror.New(apierror.CodePreconditionFailed, "el ticket fue modificado por otra solicitud")
+
+// ticketETag is the entity tag of a ticket, its version.
+func ticketETag(t *store.AssetReplacementTicket) string {
+	return `"` + strconv.FormatInt(t.Version, 10) + `"`
+}
 
-	stage, _ := strconv.Atoi(stageParam)
-	if stage <= 0 {
-		stage = 1
+// etagMatches reports whether any entity tag listed in the If-Match or
+// If-None-Match header values is etag or "*". Weak tags only match when weak
+// is set, If-Match requiring the strong comparison.
+func etagMatches(values []string, etag string, weak bool) bool {
+	for _, v := range values {
+		for _, tag := range strings.Split(v, ",") {
+			tag = strings.TrimSpace(tag)
+			if weak {
+				tag = strings.TrimPrefix(tag, "W/")
+			}
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	page, _ := strconv.Atoi(pageParam)
-	if page < 1 {
-		page = 1
+// checkIfMatch requires a write on t to name its current ETag in If-Match.
+// It answers 428 when the header is missing and 412 with the current ticket
+// when it names another version, and then returns false.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, t *store.AssetReplacementTicket) bool {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		app.problemResponse(w, r, apierror.New(apierror.CodePreconditionRequired, "falta el encabezado If-Match con el ETag del ticket"))
+		return false
+	}
+	if !etagMatches(values, ticketETag(t), false) {
+		w.Header().Set("ETag", ticketETag(t))
+		app.preconditionFailedResponse(w, r, errTicketChanged, dto.FromEntity(t))
+		return false
 	}
+	return true
+}
 
-	limit, _ := strconv.Atoi(limitParam)
-	if limit <= 0 {
-		limit = 50
+// ticketChangedResponse answers 412 with the current ticket when a write
+// lost the race against another one after checkIfMatch.
+func (app *application) ticketChangedResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	t, err := app.store.Tickets.GetByID(r.Context(), id)
+	if err != nil {
+		app.errorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", ticketETag(t))
+	app.preconditionFailedResponse(w, r, errTicketChanged, dto.FromEntity(t))
+}
+
+// transitionAssetReplacementTicketHandler moves the ticket to another stage
+// if it is still the version named by If-Match, see checkIfMatch.
+func (app *application) transitionAssetReplacementTicketHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "ticketID")
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	offset := (page - 1) * limit
+	var payload TransitionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
 	ctx := r.Context()
-	tickets, total, err := app.store.Tickets.GetAll(ctx, stage, offset, limit)
+	t, err := app.store.Tickets.GetByID(ctx, id)
+	if err != nil {
+		app.errorResponse(w, r, err)
+		return
+	}
+	if !app.checkIfMatch(w, r, t) {
+		return
+	}
+
+	ticket, err := app.ticketService.TransitionStage(ctx, id, t.Version, payload.To)
+	if err != nil {
+		if errors.Is(err, store.ErrVersionMismatch) {
+			app.ticketChangedResponse(w, r, id)
+			return
+		}
+		app.errorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", ticketETag(ticket))
+	_ = app.jsonResponse(w, http.StatusOK, dto.FromEntity(ticket))
+}
+
+func (app *application) getAssetReplacementTicketHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "ticketID")
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	entries, err := app.store.Audit.GetByTicketID(r.Context(), id)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	if len(entries) == 0 {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
 
-	totalPages := 1
-	if limit > 0 {
-		totalPages = int(math.Ceil(float64(total) / float64(limit)))
+	_ = app.jsonResponse(w, http.StatusOK, entries)
+}
+
+// ticketFilter reads the list filters from the query string:
+//
+//	stage           comma separated stage names, or "all"; the pending stages by default
+//	center_dist_id  category_id
+//	center_dist     capex  no_serial    exact values
+//	supplier        part of the supplier, ignoring case
+//	has_invoice     true or false
+//	created_from    created_to  updated_from  updated_to
+//	                RFC 3339 times or YYYY-MM-DD dates; a _to date includes that day
+//	q               part of the ID, serial, order, CAPEX, invoice, supplier or center
+func ticketFilter(r *http.Request) (store.TicketFilter, error) {
+	query := r.URL.Query()
+	f := store.TicketFilter{
+		CenterDist: strings.TrimSpace(query.Get("center_dist")),
+		Supplier:   strings.TrimSpace(query.Get("supplier")),
+		Capex:      strings.TrimSpace(query.Get("capex")),
+		NoSerial:   strings.TrimSpace(query.Get("no_serial")),
+		Q:          strings.TrimSpace(query.Get("q")),
+	}
+
+	switch v := query.Get("stage"); {
+	case v == "":
+	case isInteger(v):
+		// Clients still send the numeric stage the list used to take and ignore.
+	case strings.EqualFold(v, "all"):
+		f.Stages = workflow.Stages()
+	default:
+		for _, name := range strings.Split(v, ",") {
+			stage, err := workflow.Parse(name)
+			if err != nil {
+				return f, err
+			}
+			f.Stages = append(f.Stages, stage)
+		}
 	}
 
-	response := map[string]interface{}{
-		"page":       page,
-		"limit":      limit,
-		"total":      total,
-		"totalPages": totalPages,
-		"tickets":    dto.FromEntities(tickets),
+	var err error
+	if f.CenterDistID, err = int64Param(query, "center_dist_id"); err != nil {
+		return f, err
+	}
+	if f.CategoryID, err = int64Param(query, "category_id"); err != nil {
+		return f, err
+	}
+	if v := query.Get("has_invoice"); v != "" {
+		hasInvoice, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, apierror.Newf(apierror.CodeInvalidRequest, "%s inválido: %q", "has_invoice", v)
+		}
+		f.HasInvoice = &hasInvoice
 	}
 
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+		end  bool
+	}{
+		{"created_from", &f.CreatedFrom, false},
+		{"created_to", &f.CreatedTo, true},
+		{"updated_from", &f.UpdatedFrom, false},
+		{"updated_to", &f.UpdatedTo, true},
+	} {
+		if *p.dest, err = timeParam(query, p.name, p.end); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+func isInteger(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+func int64Param(query url.Values, name string) (*int64, error) {
+	v := query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "%s inválido: %q", name, v)
+	}
+	return &n, nil
+}
+
+// timeParam parses an RFC 3339 time or a date. With end set a date stands for
+// the end of that day, as the upper bounds of a filter are exclusive.
+func timeParam(query url.Values, name string, end bool) (*time.Time, error) {
+	v := query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+	if err != nil {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "%s inválido: %q, use YYYY-MM-DD o RFC 3339", name, v)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// TicketListResponse is a page of the ticket listing. Page and TotalPages
+// are only set when paging with ?page=.
+type TicketListResponse struct {
+	Limit      int            `json:"limit"`
+	Total      int            `json:"total"`
+	Sort       string         `json:"sort"`
+	Tickets    dto.TicketList `json:"tickets"`
+	Page       int            `json:"page,omitempty"`
+	TotalPages *int           `json:"totalPages,omitempty"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+}
+
+// getAllAssetReplacementTicketsHandler lists the tickets matching
+// ticketFilter, ordered by ?sort= (see store.ParseSort). Pages are walked
+// with the opaque ?cursor= of next_cursor and prev_cursor, also sent as
+// RFC 8288 Link headers, or with the older ?page=. ?limit= is capped at
+// maxPageLimit.
+func (app *application) getAllAssetReplacementTicketsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := ticketFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	sort, err := store.ParseSort(query.Get("sort"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	// One ticket more than the limit tells whether there is another page.
+	pg := store.TicketPage{Sort: sort, Limit: limit + 1}
+	page := 0
+	if v := query.Get("cursor"); v != "" {
+		if pg.Cursor, err = store.DecodeCursor(v, sort); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	} else {
+		page, _ = strconv.Atoi(query.Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		pg.Offset = (page - 1) * limit
+	}
+
+	ctx := r.Context()
+	tickets, total, err := app.store.Tickets.GetByFilters(ctx, filter, pg)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	backward := pg.Cursor != nil && pg.Cursor.Backward
+	more := len(tickets) > limit
+	if more && backward {
+		tickets = tickets[1:]
+	} else if more {
+		tickets = tickets[:limit]
+	}
+
+	response := TicketListResponse{
+		Limit:   limit,
+		Total:   total,
+		Sort:    store.FormatSort(sort),
+		Tickets: dto.FromEntities(tickets),
+	}
+	if page > 0 {
+		totalPages := int(math.Ceil(float64(total) / float64(limit)))
+		response.Page = page
+		response.TotalPages = &totalPages
+	}
+
+	var links []string
+	if len(tickets) > 0 {
+		// Going forward there is a next page when more were found; going
+		// backward we came from it.
+		if backward || more {
+			next := pg.CursorFor(&tickets[len(tickets)-1], false).Encode()
+			response.NextCursor = next
+			links = append(links, pageLink(r, next, "next"))
+		}
+		if (backward && more) || (!backward && (pg.Cursor != nil || pg.Offset > 0)) {
+			prev := pg.CursorFor(&tickets[0], true).Encode()
+			response.PrevCursor = prev
+			links = append(links, pageLink(r, prev, "prev"))
+		}
+	}
+	links = append(links, pageLink(r, "", "first"))
+	w.Header().Set("Link", strings.Join(links, ", "))
+
 	_ = app.jsonResponse(w, http.StatusOK, response)
 }
 
+// pageLink returns an RFC 8288 link to the listing of r at cursor, the first
+// page when cursor is empty.
+func pageLink(r *http.Request, cursor, rel string) string {
+	query := r.URL.Query()
+	query.Del("page")
+	query.Del("cursor")
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+}
+
+// upsertOptions reads the query parameters shared by the batch endpoints.
+// With ?dry_run=true the batch is validated and previewed but not written,
+// with ?atomic=true it is written all or nothing and with ?format=csv the
+// result is returned as an annotated CSV.
+func upsertOptions(r *http.Request) (services.UpsertOptions, string, error) {
+	var opts services.UpsertOptions
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, "", apierror.Newf(apierror.CodeInvalidRequest, "%s inválido: %q", "dry_run", v)
+		}
+		opts.DryRun = dryRun
+	}
+	if v := r.URL.Query().Get("atomic"); v != "" {
+		atomic, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, "", apierror.Newf(apierror.CodeInvalidRequest, "%s inválido: %q", "atomic", v)
+		}
+		opts.Atomic = atomic
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+		format = "json"
+	case "csv":
+	default:
+		return opts, "", apierror.Newf(apierror.CodeInvalidRequest, "%s inválido: %q", "format", format)
+	}
+	return opts, format, nil
+}
+
+// writeUpsertResponse writes resp as JSON or, for format csv, as a CSV
+// attachment with the status of every row next to the data that was sent.
+// A rolled back atomic batch is answered with 422.
+func (app *application) writeUpsertResponse(w http.ResponseWriter, r *http.Request, format string, resp *internalDTO.TicketUpsertResponse) {
+	status := http.StatusOK
+	if resp.RolledBack {
+		status = http.StatusUnprocessableEntity
+	}
+
+	if format != "csv" {
+		_ = app.jsonResponse(w, status, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="upsert-report.csv"`)
+	w.WriteHeader(status)
+	if err := services.WriteUpsertReport(w, resp); err != nil {
+		app.logger.Errorw("error writing upsert report", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+}
+
 func (app *application) upsertBatchHandler(w http.ResponseWriter, r *http.Request) {
+	opts, format, err := upsertOptions(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	var dtos []internalDTO.TicketUpsertDTO
 
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
 	if err := json.NewDecoder(r.Body).Decode(&dtos); err != nil {
 		app.badRequestResponse(w, r, fmt.Errorf("error decodificando JSON: %w", err))
 		return
 	}
 
-	resp, err := app.ticketService.UpsertBatch(r.Context(), dtos)
+	if !app.authorizeFields(w, r, upsertFields(dtos)) {
+		return
+	}
+
+	ctx := audit.WithSource(r.Context(), audit.SourceBatchJSON)
+	resp, err := app.ticketService.UpsertBatch(ctx, dtos, opts)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	_ = app.jsonResponse(w, http.StatusOK, resp)
+	app.writeUpsertResponse(w, r, format, &resp)
 }
 
 func (app *application) upsertBatchCSVHandler(w http.ResponseWriter, r *http.Request) {
-	file, header, err := r.FormFile("file")
+	opts, format, err := upsertOptions(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, ok := app.importColumns(w, r, &opts); !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	file, _, err := r.FormFile("file")
 	if err != nil {
 		app.badRequestResponse(w, r, fmt.Errorf("error leyendo archivo CSV: %w", err))
 		return
 	}
 	defer file.Close()
 
-	tempDir := os.TempDir()
-	tempPath := filepath.Join(tempDir, header.Filename)
+	if !app.authorizeImport(w, r, file, false, opts) {
+		return
+	}
 
-	out, err := os.Create(tempPath)
+	ctx := audit.WithSource(r.Context(), audit.SourceCSVImport)
+	resp, err := app.ticketService.UpsertBatchCSV(ctx, file, opts)
 	if err != nil {
-		app.internalServerError(w, r, fmt.Errorf("error creando archivo temporal: %w", err))
+		app.errorResponse(w, r, err)
 		return
 	}
-	defer out.Close()
 
-	_, err = out.ReadFrom(file)
+	app.writeUpsertResponse(w, r, format, resp)
+}
+
+// upsertBatchXLSXHandler imports the sheet picked with ?sheet=, by name or
+// 1-based position, of the uploaded workbook. The first sheet by default.
+func (app *application) upsertBatchXLSXHandler(w http.ResponseWriter, r *http.Request) {
+	opts, format, err := upsertOptions(r)
 	if err != nil {
-		app.internalServerError(w, r, fmt.Errorf("error guardando CSV: %w", err))
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	resp, err := app.ticketService.UpsertBatchCSV(r.Context(), tempPath)
+	if _, ok := app.importColumns(w, r, &opts); !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	file, _, err := r.FormFile("file")
 	if err != nil {
-		app.internalServerError(w, r, err)
+		app.badRequestResponse(w, r, fmt.Errorf("error leyendo archivo XLSX: %w", err))
+		return
+	}
+	defer file.Close()
+
+	if !app.authorizeImport(w, r, file, true, opts) {
 		return
 	}
 
-	_ = os.Remove(tempPath)
+	ctx := audit.WithSource(r.Context(), audit.SourceXLSXImport)
+	resp, err := app.ticketService.UpsertBatchXLSX(ctx, file, r.URL.Query().Get("sheet"), opts)
+	if err != nil {
+		app.errorResponse(w, r, err)
+		return
+	}
 
-	_ = app.jsonResponse(w, http.StatusOK, resp)
+	app.writeUpsertResponse(w, r, format, resp)
+}
+
+type BasicTicket struct {
+	TicketID      int64   `json:"ticket_id"`
+	OrderNumber   *string `json:"order_number,omitempty"`
+	Capex         *string `json:"capex,omitempty"`
+	InvoiceNumber *string `json:"invoice_number,omitempty"`
+	Supplier      *string `json:"supplier,omitempty"`
 }
 
 func (app *application) getBasicTicketsHandler(w http.ResponseWriter, r *http.Request) {
@@ -269,14 +763,6 @@ func (app *application) getBasicTicketsHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	type BasicTicket struct {
-		TicketID      int64   `json:"ticket_id"`
-		OrderNumber   *string `json:"order_number,omitempty"`
-		Capex         *string `json:"capex,omitempty"`
-		InvoiceNumber *string `json:"invoice_number,omitempty"`
-		Supplier      *string `json:"supplier,omitempty"`
-	}
-
 	var response []BasicTicket
 	for _, t := range tickets {
 		response = append(response, BasicTicket{
@@ -296,3 +782,61 @@ func StringOrNil(ns sql.NullString) *string {
 	}
 	return nil
 }
+
+var exportContentTypes = map[string]string{
+	services.ExportCSV:    "text/csv; charset=utf-8",
+	services.ExportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	services.ExportNDJSON: "application/x-ndjson",
+}
+
+// exportAssetReplacementTicketsHandler streams the tickets matching the
+// filters of getAllAssetReplacementTicketsHandler as ?format=csv (default),
+// xlsx or ndjson. CSV and XLSX use the import column names, so the file can be edited
+// and uploaded back to /upsert-csv or /upsert-xlsx.
+func (app *application) exportAssetReplacementTicketsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := ticketFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = services.ExportCSV
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		app.badRequestResponse(w, r, apierror.Wrapf(services.ErrInvalidExportFormat, "%q, se esperaba csv, xlsx o ndjson", format))
+		return
+	}
+
+	filename := fmt.Sprintf("tickets-%s.%s", time.Now().Format("20060102-150405"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	out := &trackingWriter{ResponseWriter: w}
+	n, err := app.ticketService.Export(r.Context(), out, format, filter)
+	if err != nil {
+		if !out.wrote {
+			w.Header().Del("Content-Disposition")
+			app.internalServerError(w, r, err)
+			return
+		}
+		// The status is already sent, the client gets a truncated file.
+		app.logger.Errorw("export interrupted", "format", format, "tickets", n, "error", err)
+		return
+	}
+
+	app.logger.Infow("tickets exported", "format", format, "tickets", n)
+}
+
+// trackingWriter records whether anything was written to the response.
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (tw *trackingWriter) Write(b []byte) (int, error) {
+	tw.wrote = true
+	return tw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	apidto "github.com/MislavaGuzman/AssetsReplacementManagementAPI/cmd/api/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+)
+
+// newTestApplication serves the ticket routes from memory, without
+// authentication or rate limits.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+	logger := zap.NewNop().Sugar()
+	storage := store.NewMemoryStorage()
+	return &application{
+		logger:        logger,
+		store:         storage,
+		ticketService: services.NewTicketService(storage.Tickets, storage.Tx, logger),
+	}
+}
+
+func serve(app *application, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	app.mount().ServeHTTP(w, r)
+	return w
+}
+
+func TestUpsertBatchAtomicHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ctx := context.Background()
+	if err := app.store.Tickets.Create(ctx, &store.AssetReplacementTicket{TicketID: 1, NoSerial: sql.NullString{String: "SN-1", Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `[{"ticket_id": 2, "supplier": "ACME"}, {"ticket_id": 3, "no_serial": "SN-1"}]`
+	w := serve(app, http.MethodPost, "/v1/asset-replacement-tickets/upsert-batch?atomic=true", strings.NewReader(body), nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Data dto.TicketUpsertResponse `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Data.RolledBack || len(resp.Data.Rows) != 2 {
+		t.Errorf("response = %+v, want rolled back with 2 rows", resp.Data)
+	}
+	if _, err := app.store.Tickets.GetByID(ctx, 2); err == nil {
+		t.Error("ticket 2 was saved")
+	}
+
+	// Without atomic the good row is saved.
+	w = serve(app, http.MethodPost, "/v1/asset-replacement-tickets/upsert-batch", strings.NewReader(body), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if _, err := app.store.Tickets.GetByID(ctx, 2); err != nil {
+		t.Errorf("ticket 2: %v", err)
+	}
+}
+
+// problemCurrent decodes the ticket a 412 problem carries in current.
+func problemCurrent(t *testing.T, w *httptest.ResponseRecorder) apidto.TicketResponse {
+	t.Helper()
+	var problem struct {
+		Current apidto.TicketResponse `json:"current"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	return problem.Current
+}
+
+func TestTicketPreconditions(t *testing.T) {
+	app := newTestApplication(t)
+	ctx := context.Background()
+	if err := app.store.Tickets.Create(ctx, &store.AssetReplacementTicket{TicketID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	const ticket = "/v1/asset-replacement-tickets/1"
+
+	w := serve(app, http.MethodGet, ticket, nil, nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"1"` {
+		t.Fatalf("GET = %d with ETag %q, want 200 and \"1\"", w.Code, w.Header().Get("ETag"))
+	}
+	if w := serve(app, http.MethodGet, ticket, nil, map[string]string{"If-None-Match": `W/"1"`}); w.Code != http.StatusNotModified {
+		t.Errorf("GET with If-None-Match = %d, want 304", w.Code)
+	}
+
+	writes := []struct {
+		name, method, target, body string
+	}{
+		{"patch", http.MethodPatch, ticket, `{"supplier": "ACME"}`},
+		{"transition", http.MethodPost, ticket + "/transitions", `{"to": "Procurement Phase"}`},
+		{"delete", http.MethodDelete, ticket, ""},
+	}
+	for _, tt := range writes {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(app, tt.method, tt.target, strings.NewReader(tt.body), nil); w.Code != http.StatusPreconditionRequired {
+				t.Errorf("without If-Match = %d, want 428: %s", w.Code, w.Body)
+			}
+
+			current, err := app.store.Tickets.GetByID(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := serve(app, tt.method, tt.target, strings.NewReader(tt.body), map[string]string{"If-Match": `"99"`})
+			if w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != ticketETag(current) {
+				t.Fatalf("stale If-Match = %d with ETag %q, want 412 and %s", w.Code, w.Header().Get("ETag"), ticketETag(current))
+			}
+			if got := problemCurrent(t, w); got.TicketID != 1 || got.Version != current.Version {
+				t.Errorf("412 current = %+v, want ticket 1 at version %d", got, current.Version)
+			}
+			if after, _ := app.store.Tickets.GetByID(ctx, 1); after.Version != current.Version {
+				t.Errorf("the ticket was written, version %d", after.Version)
+			}
+
+			w = serve(app, tt.method, tt.target, strings.NewReader(tt.body), map[string]string{"If-Match": ticketETag(current)})
+			if w.Code >= 300 {
+				t.Errorf("current If-Match = %d: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+// Listed tickets carry the version their ETag is made of.
+func TestTicketListVersion(t *testing.T) {
+	app := newTestApplication(t)
+	ctx := context.Background()
+	if err := app.store.Tickets.Create(ctx, &store.AssetReplacementTicket{TicketID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	tk, _ := app.store.Tickets.GetByID(ctx, 1)
+	if err := app.store.Tickets.Update(ctx, tk); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(app, http.MethodGet, "/v1/asset-replacement-tickets", nil, nil)
+	var resp struct {
+		Data TicketListResponse `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if tickets := resp.Data.Tickets.Tickets; len(tickets) != 1 || tickets[0].Version != 2 {
+		t.Errorf("listed tickets = %+v, want ticket 1 at version 2", tickets)
+	}
+}
+
+// multipartFile builds an upload of the import endpoints.
+func multipartFile(t *testing.T, name, content string) (io.Reader, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(part, content)
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, mw.FormDataContentType()
+}
+
+func TestUpsertCSVReportDownload(t *testing.T) {
+	app := newTestApplication(t)
+	body, contentType := multipartFile(t, "tickets.csv", "ticket_id,supplier\n1,ACME\nabc,ACME\n")
+
+	w := serve(app, http.MethodPost, "/v1/asset-replacement-tickets/upsert-csv?format=csv", body, map[string]string{"Content-Type": contentType})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "attachment") {
+		t.Errorf("Content-Disposition = %q, want an attachment", cd)
+	}
+
+	report := strings.TrimPrefix(w.Body.String(), "\uFEFF")
+	if report == w.Body.String() {
+		t.Error("the report does not start with a BOM")
+	}
+	lines := strings.Split(strings.TrimSpace(report), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], ",status,error_code,error_message") ||
+		!strings.Contains(lines[1], ",inserted,") || !strings.Contains(lines[2], ",skipped,invalid_ticket_id,") {
+		t.Errorf("report = %q", report)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+)
+
+// ImportProfilePayload maps ticket fields to the headers that hold them in a
+// source file, e.g. {"no_serial": ["N° Serie"], "supplier": ["Proveedor"]}.
+type ImportProfilePayload struct {
+	Description string              `json:"description,omitempty" validate:"max=400"`
+	Columns     map[string][]string `json:"columns" validate:"required,min=1"`
+}
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,99}$`)
+
+func (app *application) listImportProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	profiles, err := app.store.ImportProfiles.List(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	_ = app.jsonResponse(w, http.StatusOK, profiles)
+}
+
+func (app *application) getImportProfileHandler(w http.ResponseWriter, r *http.Request) {
+	profile, err := app.store.ImportProfiles.GetByName(r.Context(), chi.URLParam(r, "profileName"))
+	if err != nil {
+		app.errorResponse(w, r, err)
+		return
+	}
+
+	_ = app.jsonResponse(w, http.StatusOK, profile)
+}
+
+// saveImportProfileHandler creates the profile named in the path or replaces
+// its mapping.
+func (app *application) saveImportProfileHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "profileName")
+	if !profileNamePattern.MatchString(name) {
+		app.badRequestResponse(w, r, apierror.Newf(apierror.CodeInvalidRequest, "nombre de perfil inválido: %q, use letras, números, '.', '_' o '-'", name))
+		return
+	}
+
+	var payload ImportProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := services.ValidateMapping(payload.Columns); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	profile := &store.ImportProfile{
+		Name:        name,
+		Description: payload.Description,
+		Columns:     payload.Columns,
+	}
+	if err := app.store.ImportProfiles.Save(r.Context(), profile); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("import profile saved", "profile", name)
+	_ = app.jsonResponse(w, http.StatusOK, profile)
+}
+
+func (app *application) deleteImportProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.ImportProfiles.Delete(r.Context(), chi.URLParam(r, "profileName")); err != nil {
+		app.errorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// importColumns sets opts.Columns to the mapping of the profile picked with
+// ?profile= and returns its name. Without it only the field names are
+// accepted as headers. It writes the error response and returns false when
+// the profile cannot be loaded.
+func (app *application) importColumns(w http.ResponseWriter, r *http.Request, opts *services.UpsertOptions) (string, bool) {
+	name := r.URL.Query().Get("profile")
+	if name == "" {
+		return "", true
+	}
+
+	profile, err := app.store.ImportProfiles.GetByName(r.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, apierror.Newf(apierror.CodeInvalidRequest, "perfil de importación no encontrado: %q", name))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return "", false
+	}
+
+	opts.Columns = profile.Columns
+	return name, true
+}
+
+// authorizeImport reads the header of file, an XLSX workbook when xlsx is
+// set, and checks the caller may change every field it has a column for.
+// file is rewound so it can be imported afterwards. It writes the error
+// response and returns false otherwise.
+func (app *application) authorizeImport(w http.ResponseWriter, r *http.Request, file multipart.File, xlsx bool, opts services.UpsertOptions) bool {
+	var fields []string
+	if xlsx {
+		reader, err := services.NewTicketXLSXReader(file, r.URL.Query().Get("sheet"), opts.Columns)
+		if err != nil {
+			app.importHeaderError(w, r, err)
+			return false
+		}
+		fields = reader.Fields()
+		reader.Close()
+	} else {
+		reader, err := services.NewTicketCSVReader(file, opts.Columns)
+		if err != nil {
+			app.importHeaderError(w, r, err)
+			return false
+		}
+		fields = reader.Fields()
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+	return app.authorizeFields(w, r, fields)
+}
+
+func (app *application) importHeaderError(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, err)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/audit"
+	internalDTO "github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/imports"
+)
+
+// createImportHandler queues a CSV import, or an XLSX one when the file name
+// ends in .xlsx, read with the import profile picked with ?profile=, and
+// answers 202 right away. The progress is read from
+// GET /v1/imports/{importID}.
+func (app *application) createImportHandler(w http.ResponseWriter, r *http.Request) {
+	opts, _, err := upsertOptions(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	profile, ok := app.importColumns(w, r, &opts)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("error leyendo archivo: %w", err))
+		return
+	}
+	defer file.Close()
+
+	upload := imports.Upload{
+		File:     file,
+		FileName: header.Filename,
+		Format:   imports.FormatCSV,
+		Sheet:    r.URL.Query().Get("sheet"),
+		Profile:  profile,
+	}
+	source := audit.SourceCSVImport
+	if strings.EqualFold(filepath.Ext(header.Filename), ".xlsx") {
+		upload.Format = imports.FormatXLSX
+		source = audit.SourceXLSXImport
+	}
+
+	if !app.authorizeImport(w, r, file, upload.Format == imports.FormatXLSX, opts) {
+		return
+	}
+
+	ctx := audit.WithSource(r.Context(), source)
+	job, err := app.imports.Submit(ctx, upload, audit.FromContext(ctx).Actor, opts)
+	if err != nil {
+		if errors.Is(err, imports.ErrQueueFull) || errors.Is(err, imports.ErrShuttingDown) {
+			w.Header().Set("Retry-After", "30")
+		}
+		app.errorResponse(w, r, err)
+		return
+	}
+
+	app.logger.Infow("import queued", "job_id", job.ID, "file", job.FileName, "actor", job.Actor)
+	w.Header().Set("Location", "/v1/imports/"+job.ID)
+	_ = app.jsonResponse(w, http.StatusAccepted, job)
+}
+
+func (app *application) listImportsHandler(w http.ResponseWriter, r *http.Request) {
+	_ = app.jsonResponse(w, http.StatusOK, app.imports.List())
+}
+
+// getImportHandler returns the job with the result of every row processed so
+// far, as JSON or, with ?format=csv, as the annotated CSV report.
+func (app *application) getImportHandler(w http.ResponseWriter, r *http.Request) {
+	_, format, err := upsertOptions(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	job, err := app.imports.Get(chi.URLParam(r, "importID"))
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	if format == "csv" {
+		app.writeUpsertResponse(w, r, format, &internalDTO.TicketUpsertResponse{Rows: job.Rows})
+		return
+	}
+	_ = app.jsonResponse(w, http.StatusOK, job)
+}
+
+func (app *application) cancelImportHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := app.imports.Cancel(chi.URLParam(r, "importID"))
+	if err != nil {
+		app.errorResponse(w, r, err)
+		return
+	}
+
+	app.logger.Infow("import cancelled", "job_id", job.ID)
+	_ = app.jsonResponse(w, http.StatusOK, job)
+}
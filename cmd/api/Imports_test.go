@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/imports"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
+)
+
+// stalledImporter reports the first row of an import and then waits for the
+// import to be cancelled.
+type stalledImporter struct {
+	started chan struct{}
+}
+
+func (s *stalledImporter) UpsertBatchCSV(ctx context.Context, src io.Reader, opts services.UpsertOptions) (*dto.TicketUpsertResponse, error) {
+	opts.Progress(dto.TicketRowResult{Row: 1, TicketID: 1, Status: dto.RowStatusInserted}, dto.TicketUpsertSummary{Inserted: 1})
+	close(s.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *stalledImporter) UpsertBatchXLSX(ctx context.Context, src io.Reader, sheet string, opts services.UpsertOptions) (*dto.TicketUpsertResponse, error) {
+	return s.UpsertBatchCSV(ctx, src, opts)
+}
+
+func newImportsTestApplication(t *testing.T, importer imports.Importer) *application {
+	t.Helper()
+	app := newTestApplication(t)
+	app.imports = imports.NewManager(importer, imports.Config{Workers: 1, QueueSize: 1, Dir: t.TempDir()}, zap.NewNop().Sugar())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = app.imports.Shutdown(ctx)
+	})
+	return app
+}
+
+// decodeData decodes the data member of a JSON response into v.
+func decodeData(t *testing.T, body io.Reader, v any) {
+	t.Helper()
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(resp.Data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportUnknownJob(t *testing.T) {
+	app := newImportsTestApplication(t, &stalledImporter{started: make(chan struct{})})
+
+	for _, tt := range []struct{ method, target string }{
+		{http.MethodGet, "/v1/imports/missing"},
+		{http.MethodPost, "/v1/imports/missing/cancel"},
+	} {
+		w := serve(app, tt.method, tt.target, nil, nil)
+		var problem struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(w.Body).Decode(&problem)
+		if w.Code != http.StatusNotFound || problem.Code != "not_found" {
+			t.Errorf("%s %s = %d %q, want 404 not_found", tt.method, tt.target, w.Code, problem.Code)
+		}
+	}
+}
+
+func TestImportStatusAndCancel(t *testing.T) {
+	importer := &stalledImporter{started: make(chan struct{})}
+	app := newImportsTestApplication(t, importer)
+
+	body, contentType := multipartFile(t, "tickets.csv", "ticket_id\n1\n2\n")
+	w := serve(app, http.MethodPost, "/v1/imports", body, map[string]string{"Content-Type": contentType})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST = %d: %s", w.Code, w.Body)
+	}
+	var queued imports.Job
+	decodeData(t, w.Body, &queued)
+	location := w.Header().Get("Location")
+	if location != "/v1/imports/"+queued.ID {
+		t.Errorf("Location = %q, want the job %s", location, queued.ID)
+	}
+
+	select {
+	case <-importer.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the import did not start")
+	}
+
+	// The progress of the running job, with the rows processed so far.
+	w = serve(app, http.MethodGet, location, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET = %d: %s", w.Code, w.Body)
+	}
+	var status map[string]any
+	decodeData(t, w.Body, &status)
+	for _, key := range []string{"id", "state", "file_name", "format", "total", "processed", "summary", "created_at", "started_at", "rows"} {
+		if _, ok := status[key]; !ok {
+			t.Errorf("status has no %q: %v", key, status)
+		}
+	}
+	if _, ok := status["finished_at"]; ok {
+		t.Error("a running job has finished_at")
+	}
+	summary, _ := status["summary"].(map[string]any)
+	rows, _ := status["rows"].([]any)
+	if status["state"] != "running" || status["format"] != "csv" || status["total"] != 2.0 || status["processed"] != 1.0 ||
+		summary["inserted"] != 1.0 || len(rows) != 1 {
+		t.Errorf("status = %v", status)
+	}
+	if row, _ := rows[0].(map[string]any); row["ticket_id"] != 1.0 || row["status"] != dto.RowStatusInserted {
+		t.Errorf("row = %v", rows[0])
+	}
+
+	// The list leaves the rows out.
+	w = serve(app, http.MethodGet, "/v1/imports", nil, nil)
+	var list []map[string]any
+	decodeData(t, w.Body, &list)
+	if len(list) != 1 || list[0]["id"] != queued.ID || list[0]["rows"] != nil {
+		t.Errorf("list = %v", list)
+	}
+
+	w = serve(app, http.MethodPost, location+"/cancel", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel = %d: %s", w.Code, w.Body)
+	}
+
+	var job imports.Job
+	for deadline := time.Now().Add(5 * time.Second); job.State != imports.StateCancelled; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("job is %s, want cancelled", job.State)
+		}
+		decodeData(t, serve(app, http.MethodGet, location, nil, nil).Body, &job)
+	}
+	if job.Processed != 1 || len(job.Rows) != 1 || job.FinishedAt == nil {
+		t.Errorf("cancelled job = %+v, want the row processed before", job)
+	}
+
+	if w := serve(app, http.MethodPost, location+"/cancel", nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("second cancel = %d, want 409", w.Code)
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"expvar"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,18 +18,32 @@ import (
 	"github.com/go-chi/cors"
 	"go.uber.org/zap"
 
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/auth"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/authz"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/idempotency"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/imports"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/openapi"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/ratelimiter"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
 )
 
 type application struct {
-	config        appConfig
-	store         store.Storage
-	logger        *zap.SugaredLogger
-	db            *sql.DB
-	rateLimiter   ratelimiter.Limiter
-	ticketService *services.TicketService
+	config            appConfig
+	store             store.Storage
+	logger            *zap.SugaredLogger
+	db                *sql.DB
+	rateLimiter       ratelimiter.Limiter
+	importRateLimiter ratelimiter.Limiter
+	// ipRateLimiter is applied per client IP before authentication.
+	ipRateLimiter  ratelimiter.Limiter
+	trustedProxies []netip.Prefix
+	ticketService  *services.TicketService
+	imports        *imports.Manager
+	idempotency    idempotency.Store
+	authenticator  *auth.Authenticator
+	policy         *authz.Policy
 }
 
 // ROUTER
@@ -35,40 +51,132 @@ func (app *application) mount() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(app.realIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(app.localize)
 
 	// CORS configuration
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-None-Match", "X-CSRF-Token", "X-API-Key"},
+		ExposedHeaders:   []string{"ETag", "Idempotent-Replayed", "Link", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		app.problemResponse(w, r, apierror.New(apierror.CodeNotFound, "ruta no encontrada"))
+	})
+	// allowed is the route table without subrouters, to answer Allow: chi only
+	// fills it in its own handler, and Find does not see through subrouters.
+	var allowed *chi.Mux
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if len(path) > 1 {
+			path = strings.TrimSuffix(path, "/")
+		}
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			if allowed.Match(chi.NewRouteContext(), method, path) {
+				w.Header().Add("Allow", method)
+			}
+		}
+		app.problemResponse(w, r, apierror.Newf(apierror.CodeMethodNotAllowed, "método %s no permitido en %s", r.Method, r.URL.Path))
+	})
+
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
-		r.Handle("/debug/vars", expvar.Handler())
+		r.Method(http.MethodGet, "/debug/vars", expvar.Handler())
+		r.Get("/openapi.json", app.openAPIHandler)
+		r.Method(http.MethodGet, "/docs", openapi.Docs(openAPIPath))
 	})
 	r.Route("/v1/asset-replacement-tickets", func(r chi.Router) {
-		r.Get("/", app.getAllAssetReplacementTicketsHandler)
-		r.Post("/", app.createAssetReplacementTicketHandler)
-		r.Get("/by-ticket", app.getAssetReplacementTicketHandler)
-		r.Patch("/by-ticket", app.updateAssetReplacementTicketHandler)
-		r.Delete("/", app.deleteAssetReplacementTicketHandler)
-		r.Get("/basic", app.getBasicTicketsHandler)
-		r.Post("/upsert-batch", app.upsertBatchHandler)
-		r.Post("/upsert-csv", app.upsertBatchCSVHandler)
+		r.Use(app.rateLimitIP(app.ipRateLimiter))
+		r.Use(app.authenticate)
+		r.Use(app.auditContext)
+
+		read := app.requirePermission(authz.PermTicketsRead)
+		create := app.requirePermission(authz.PermTicketsCreate)
+		update := app.requirePermission(authz.PermTicketsUpdate)
+		remove := app.requirePermission(authz.PermTicketsDelete)
+		transition := app.requirePermission(authz.PermTicketsTransition)
+		importBatch := app.requirePermission(authz.PermTicketsImport)
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.rateLimit(app.rateLimiter))
+
+			r.With(read).Get("/", app.getAllAssetReplacementTicketsHandler)
+			r.With(create, app.idempotent).Post("/", app.createAssetReplacementTicketHandler)
+			r.With(read).Get("/basic", app.getBasicTicketsHandler)
+			r.With(read).Get("/export", app.exportAssetReplacementTicketsHandler)
+
+			r.Route("/{ticketID}", func(r chi.Router) {
+				r.With(read).Get("/", app.getAssetReplacementTicketHandler)
+				r.With(update).Patch("/", app.updateAssetReplacementTicketHandler)
+				r.With(remove).Delete("/", app.deleteAssetReplacementTicketHandler)
+				r.With(transition).Post("/transitions", app.transitionAssetReplacementTicketHandler)
+				r.With(read).Get("/history", app.getAssetReplacementTicketHistoryHandler)
+			})
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.rateLimit(app.importRateLimiter))
+
+			r.With(importBatch, app.idempotent).Post("/upsert-batch", app.upsertBatchHandler)
+			r.With(importBatch, app.idempotent).Post("/upsert-csv", app.upsertBatchCSVHandler)
+			r.With(importBatch, app.idempotent).Post("/upsert-xlsx", app.upsertBatchXLSXHandler)
+		})
+	})
+	r.Route("/v1/imports", func(r chi.Router) {
+		r.Use(app.rateLimitIP(app.ipRateLimiter))
+		r.Use(app.authenticate)
+		r.Use(app.auditContext)
+		r.Use(app.requirePermission(authz.PermTicketsImport))
+
+		r.With(app.rateLimit(app.importRateLimiter), app.idempotent).Post("/", app.createImportHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.rateLimit(app.rateLimiter))
+
+			r.Get("/", app.listImportsHandler)
+			r.Get("/{importID}", app.getImportHandler)
+			r.Post("/{importID}/cancel", app.cancelImportHandler)
+		})
+	})
+	r.Route("/v1/import-profiles", func(r chi.Router) {
+		r.Use(app.rateLimitIP(app.ipRateLimiter))
+		r.Use(app.authenticate)
+		r.Use(app.auditContext)
+		r.Use(app.requirePermission(authz.PermTicketsImport))
+		r.Use(app.rateLimit(app.rateLimiter))
+
+		r.Get("/", app.listImportProfilesHandler)
+		r.Get("/{profileName}", app.getImportProfileHandler)
+		r.Put("/{profileName}", app.saveImportProfileHandler)
+		r.Delete("/{profileName}", app.deleteImportProfileHandler)
 	})
 
+	allowed = flatten(r)
 	return r
 }
 
+// flatten registers every route of r, with a no-op handler, on a router
+// without subrouters.
+func flatten(r chi.Routes) *chi.Mux {
+	flat := chi.NewRouter()
+	chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		flat.MethodFunc(method, route, func(http.ResponseWriter, *http.Request) {})
+		return nil
+	})
+	return flat
+}
+
 func (app *application) run(mux http.Handler) error {
 	srv := &http.Server{
 		Addr:         app.config.addr,
@@ -89,7 +197,13 @@ func (app *application) run(mux http.Handler) error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		shutdown <- srv.Shutdown(ctx)
+		if err := srv.Shutdown(ctx); err != nil {
+			shutdown <- err
+			return
+		}
+
+		// Running imports are cancelled, rows already written stay written.
+		shutdown <- app.imports.Shutdown(ctx)
 	}()
 
 	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
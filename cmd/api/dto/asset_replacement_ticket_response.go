@@ -14,6 +14,10 @@ type TicketResponse struct {
 	Capex         *string `json:"capex,omitempty"`
 	InvoiceNumber *string `json:"invoice_number,omitempty"`
 	Supplier      *string `json:"supplier,omitempty"`
+	StageProcess  *string `json:"stage_process,omitempty"`
+	// Version is the value of the ticket's ETag, so If-Match can be built
+	// from a list item too.
+	Version int64 `json:"version"`
 }
 
 func FromEntity(t *store.AssetReplacementTicket) TicketResponse {
@@ -25,6 +29,7 @@ func FromEntity(t *store.AssetReplacementTicket) TicketResponse {
 		capex         *string
 		invoiceNumber *string
 		supplier      *string
+		stageProcess  *string
 	)
 
 	if t.CategoryID.Valid {
@@ -45,6 +50,9 @@ func FromEntity(t *store.AssetReplacementTicket) TicketResponse {
 	if t.Supplier.Valid {
 		supplier = &t.Supplier.String
 	}
+	if t.StageProcess.Valid {
+		stageProcess = &t.StageProcess.String
+	}
 
 	return TicketResponse{
 		ID:            t.ID,
@@ -56,17 +64,25 @@ func FromEntity(t *store.AssetReplacementTicket) TicketResponse {
 		Capex:         capex,
 		InvoiceNumber: invoiceNumber,
 		Supplier:      supplier,
+		StageProcess:  stageProcess,
+		Version:       t.Version,
 	}
 }
 
-func FromEntities(tickets []store.AssetReplacementTicket) map[string]interface{} {
+// TicketList is a list of tickets with its length.
+type TicketList struct {
+	Tickets []TicketResponse `json:"tickets"`
+	Count   int              `json:"count"`
+}
+
+func FromEntities(tickets []store.AssetReplacementTicket) TicketList {
 	result := make([]TicketResponse, len(tickets))
 	for i, ticket := range tickets {
 		result[i] = FromEntity(&ticket)
 	}
 
-	return map[string]interface{}{
-		"tickets": result,
-		"count":   len(tickets),
+	return TicketList{
+		Tickets: result,
+		Count:   len(tickets),
 	}
 }
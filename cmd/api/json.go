@@ -2,15 +2,29 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/authz"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/i18n"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
 )
 
 var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+	apierror.RegisterJSONNames(Validate)
+	if err := i18n.RegisterValidator(Validate); err != nil {
+		panic(err)
+	}
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -19,6 +33,10 @@ func writeJSON(w http.ResponseWriter, status int, data any) error {
 	return json.NewEncoder(w).Encode(data)
 }
 
+// maxUploadBytes is the largest body accepted by the import endpoints, CSV
+// and XLSX files included.
+const maxUploadBytes = services.MaxUploadBytes
+
 func readJSON(w http.ResponseWriter, r *http.Request, data any) error {
 	maxBytes := 1_048_576 // 1 MB
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
@@ -27,13 +45,6 @@ func readJSON(w http.ResponseWriter, r *http.Request, data any) error {
 	return decoder.Decode(data)
 }
 
-func writeJSONError(w http.ResponseWriter, status int, message string) error {
-	type envelope struct {
-		Error string `json:"error"`
-	}
-	return writeJSON(w, status, &envelope{Error: message})
-}
-
 func (app *application) jsonResponse(w http.ResponseWriter, status int, data any) error {
 	type envelope struct {
 		Data any `json:"data"`
@@ -41,16 +52,88 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 	return writeJSON(w, status, &envelope{Data: data})
 }
 
+// errorResponse answers with the problem describing err, see apierror.From.
+// Errors without a code are internal errors whose message is only logged.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.problemResponse(w, r, apierror.From(err))
+}
+
+// problemResponse writes e as application/problem+json in the language of
+// the request, identified by the request ID.
+func (app *application) problemResponse(w http.ResponseWriter, r *http.Request, e *apierror.Error) {
+	status := e.Code.Status()
+	if status >= http.StatusInternalServerError {
+		var cause error = e
+		if e.Err != nil {
+			cause = e.Err
+		}
+		app.logger.Errorw("server error", "method", r.Method, "path", r.URL.Path, "code", e.Code, "error", cause)
+	} else {
+		app.logger.Warnw("client error", "method", r.Method, "path", r.URL.Path, "code", e.Code, "error", e)
+	}
+
+	w.Header().Set("Content-Type", apierror.ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(e.Problem(i18n.FromContext(r.Context()), r.URL.Path, middleware.GetReqID(r.Context())))
+}
+
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Error(err)
-	_ = writeJSONError(w, http.StatusInternalServerError, "internal server error")
+	app.problemResponse(w, r, &apierror.Error{Code: apierror.CodeInternal, Err: err})
 }
 
+// badRequestResponse describes an error found reading or validating the
+// request, see apierror.Invalid.
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warn(err)
-	_ = writeJSONError(w, http.StatusBadRequest, err.Error())
+	app.problemResponse(w, r, apierror.Invalid(err))
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
-	_ = writeJSONError(w, http.StatusNotFound, "resource not found")
+	app.problemResponse(w, r, apierror.Default(err, apierror.CodeNotFound))
+}
+
+// preconditionFailedResponse answers 412 with the current state of the
+// resource, so the client can merge its change without another request.
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request, err error, current any) {
+	app.problemResponse(w, r, apierror.Default(err, apierror.CodePreconditionFailed).With("current", current))
+}
+
+func (app *application) unauthorizedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="assets-replacement"`)
+	app.problemResponse(w, r, &apierror.Error{Code: apierror.CodeUnauthorized, Err: err})
+}
+
+// forbiddenResponse lists the permissions and fields the caller lacks when
+// err is an *authz.Error.
+func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request, err error) {
+	e := apierror.Wrap(apierror.CodeForbidden, err)
+	var authzErr *authz.Error
+	if errors.As(err, &authzErr) {
+		perms := make([]string, len(authzErr.Permissions))
+		for i, p := range authzErr.Permissions {
+			perms[i] = string(p)
+		}
+		if len(authzErr.Fields) > 0 {
+			e = apierror.Newf(apierror.CodeForbidden, "se requiere %s para modificar %s", strings.Join(perms, ", "), strings.Join(authzErr.Fields, ", "))
+		} else {
+			e = apierror.Newf(apierror.CodeForbidden, "se requiere %s", strings.Join(perms, ", "))
+		}
+		e.Err = err
+
+		if len(authzErr.Permissions) > 0 {
+			e = e.With("permissions", authzErr.Permissions)
+		}
+		if len(authzErr.Fields) > 0 {
+			e = e.With("fields", authzErr.Fields)
+		}
+	}
+	app.problemResponse(w, r, e)
+}
+
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	e := apierror.Newf(apierror.CodeRateLimited, "rate limit exceeded, retry in %s", retryAfter.Round(time.Second).String())
+	app.problemResponse(w, r, e.With("retry_after", int(math.Ceil(retryAfter.Seconds()))))
+}
+
+func (app *application) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.problemResponse(w, r, apierror.Default(err, apierror.CodeUnavailable))
 }
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
 	"expvar"
+	"fmt"
+	"os"
 	"runtime"
 	"strconv"
+	"time"
 
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/audit"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/auth"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/authz"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/db"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/env"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/idempotency"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/imports"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/ratelimiter"
@@ -18,9 +29,50 @@ import (
 const version = "0.1.0"
 
 type appConfig struct {
-	addr string
-	env  string
-	db   dbConfig
+	addr        string
+	env         string
+	storeDriver string
+	// trustedProxies lists the IPs and CIDRs whose X-Forwarded-For and
+	// X-Real-IP headers are believed.
+	trustedProxies string
+	db             dbConfig
+	auth           authConfig
+	rateLimiter    rateLimiterConfig
+	imports        imports.Config
+	idempotency    idempotencyConfig
+}
+
+type idempotencyConfig struct {
+	// ttl is how long the response to an Idempotency-Key is replayed.
+	ttl time.Duration
+	// store is memory (per replica) or redis (shared by every replica,
+	// through the rate limiter server).
+	store string
+}
+
+type rateLimiterConfig struct {
+	// standard applies to every ticket route except the batch imports.
+	standard ratelimiter.Config
+	// imports applies to /upsert-batch and /upsert-csv.
+	imports ratelimiter.Config
+	// ip applies per client IP to every authenticated route, before the
+	// credentials are checked.
+	ip ratelimiter.Config
+	// store is memory (per replica) or redis (shared by every replica).
+	store         string
+	redisAddr     string
+	redisPassword string
+	redisDB       int
+}
+
+type authConfig struct {
+	enabled          bool
+	jwtSecret        string
+	jwtSecretFile    string
+	jwtPublicKeyFile string
+	jwtIssuer        string
+	jwtAudience      string
+	apiKeysFile      string
 }
 
 type dbConfig struct {
@@ -39,8 +91,10 @@ func main() {
 	_ = godotenv.Load()
 
 	cfg := appConfig{
-		addr: env.GetString("APP_HOST", ":4000") + ":" + env.GetString("APP_PORT", "8080"),
-		env:  env.GetString("APP_ENV", "development"),
+		addr:           env.GetString("APP_HOST", ":4000") + ":" + env.GetString("APP_PORT", "8080"),
+		env:            env.GetString("APP_ENV", "development"),
+		storeDriver:    env.GetString("STORE_DRIVER", "oracle"),
+		trustedProxies: env.GetString("TRUSTED_PROXIES", ""),
 		db: dbConfig{
 			user:         env.GetString("ORACLE_USER", ""),
 			password:     env.GetString("ORACLE_PASSWORD", ""),
@@ -51,38 +105,132 @@ func main() {
 			maxIdleConns: env.GetInt("ORACLE_MAX_IDLE_CONNS", 10),
 			maxIdleTime:  env.GetString("ORACLE_MAX_IDLE_TIME", "15m"),
 		},
+		auth: authConfig{
+			enabled:          env.GetBool("AUTH_ENABLED", true),
+			jwtSecret:        env.GetString("AUTH_JWT_SECRET", ""),
+			jwtSecretFile:    env.GetString("AUTH_JWT_SECRET_FILE", ""),
+			jwtPublicKeyFile: env.GetString("AUTH_JWT_PUBLIC_KEY_FILE", ""),
+			jwtIssuer:        env.GetString("AUTH_JWT_ISSUER", ""),
+			jwtAudience:      env.GetString("AUTH_JWT_AUDIENCE", ""),
+			apiKeysFile:      env.GetString("AUTH_API_KEYS_FILE", ""),
+		},
+		rateLimiter: rateLimiterConfig{
+			standard: ratelimiter.Config{
+				RequestsPerTimeFrame: env.GetInt("RATELIMITER_REQUESTS_COUNT", 100),
+				TimeFrame:            env.GetDuration("RATELIMITER_TIME_FRAME", time.Minute),
+				Enabled:              env.GetBool("RATELIMITER_ENABLED", true),
+				Algorithm:            env.GetString("RATELIMITER_ALGORITHM", ratelimiter.AlgorithmFixedWindow),
+				Burst:                env.GetInt("RATELIMITER_BURST", 0),
+				Name:                 "standard",
+			},
+			imports: ratelimiter.Config{
+				RequestsPerTimeFrame: env.GetInt("RATELIMITER_IMPORT_REQUESTS_COUNT", 5),
+				TimeFrame:            env.GetDuration("RATELIMITER_IMPORT_TIME_FRAME", time.Minute),
+				Enabled:              env.GetBool("RATELIMITER_ENABLED", true),
+				Algorithm:            env.GetString("RATELIMITER_ALGORITHM", ratelimiter.AlgorithmFixedWindow),
+				Name:                 "import",
+			},
+			ip: ratelimiter.Config{
+				RequestsPerTimeFrame: env.GetInt("RATELIMITER_IP_REQUESTS_COUNT", 300),
+				TimeFrame:            env.GetDuration("RATELIMITER_IP_TIME_FRAME", time.Minute),
+				Enabled:              env.GetBool("RATELIMITER_ENABLED", true),
+				Algorithm:            env.GetString("RATELIMITER_ALGORITHM", ratelimiter.AlgorithmFixedWindow),
+				Name:                 "ip",
+			},
+			store:         env.GetString("RATELIMITER_STORE", "memory"),
+			redisAddr:     env.GetString("RATELIMITER_REDIS_ADDR", "localhost:6379"),
+			redisPassword: env.GetString("RATELIMITER_REDIS_PASSWORD", ""),
+			redisDB:       env.GetInt("RATELIMITER_REDIS_DB", 0),
+		},
+		imports: imports.Config{
+			Workers:   env.GetInt("IMPORTS_WORKERS", 2),
+			QueueSize: env.GetInt("IMPORTS_QUEUE_SIZE", 16),
+			Retention: env.GetDuration("IMPORTS_RETENTION", 24*time.Hour),
+			Dir:       env.GetString("IMPORTS_DIR", ""),
+		},
+		idempotency: idempotencyConfig{
+			ttl:   env.GetDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+			store: env.GetString("IDEMPOTENCY_STORE", "memory"),
+		},
 	}
 
 	logger := zap.Must(zap.NewProduction()).Sugar()
 	defer logger.Sync()
 
-	rateLimiter := ratelimiter.New(100, 10)
+	rateLimiter, importRateLimiter, ipRateLimiter, err := newRateLimiters(cfg.rateLimiter, logger)
+	if err != nil {
+		logger.Fatalf("Error configuring rate limiter: %v", err)
+	}
 
-	dsn := "oracle://" + cfg.db.user + ":" + cfg.db.password + "@" + cfg.db.host + ":" + strconv.Itoa(cfg.db.port) + "/" + cfg.db.serviceName
+	trustedProxies, err := parseTrustedProxies(cfg.trustedProxies)
+	if err != nil {
+		logger.Fatalf("Error configuring TRUSTED_PROXIES: %v", err)
+	}
+
+	idempotencyStore, err := newIdempotencyStore(cfg.idempotency, cfg.rateLimiter)
+	if err != nil {
+		logger.Fatalf("Error configuring idempotency: %v", err)
+	}
 
-	conn, err := db.New(
-		dsn,
-		cfg.db.maxOpenConns,
-		cfg.db.maxIdleConns,
-		cfg.db.maxIdleTime,
+	var (
+		conn    *sql.DB
+		storage store.Storage
 	)
 
-	if err != nil {
-		logger.Fatalf("Error connecting to the database: %v", err)
+	switch cfg.storeDriver {
+	case "memory":
+		storage = store.NewMemoryStorage()
+		logger.Warnw("Using in-memory store, data will be lost on shutdown")
+	case "oracle":
+		dsn := "oracle://" + cfg.db.user + ":" + cfg.db.password + "@" + cfg.db.host + ":" + strconv.Itoa(cfg.db.port) + "/" + cfg.db.serviceName
+
+		conn, err = db.New(
+			dsn,
+			cfg.db.maxOpenConns,
+			cfg.db.maxIdleConns,
+			cfg.db.maxIdleTime,
+		)
+
+		if err != nil {
+			logger.Fatalf("Error connecting to the database: %v", err)
+		}
+		defer conn.Close()
+		logger.Infow("Connected to the database successfully")
+
+		storage = store.NewStorage(conn)
+	default:
+		logger.Fatalf("Unknown STORE_DRIVER %q, expected oracle or memory", cfg.storeDriver)
 	}
-	defer conn.Close()
-	logger.Infow("Connected to the database successfully")
 
-	storage := store.NewStorage(conn)
-	ticketService := services.NewTicketService(storage.Tickets, logger)
+	storage.Tickets = audit.NewTicketRepository(storage.Tickets, storage.Audit, storage.Tx)
+	ticketService := services.NewTicketService(storage.Tickets, storage.Tx, logger)
+	importManager := imports.NewManager(ticketService, cfg.imports, logger)
+
+	var authenticator *auth.Authenticator
+	if cfg.auth.enabled {
+		a, err := newAuthenticator(cfg.auth)
+		if err != nil {
+			logger.Fatalf("Error configuring authentication: %v", err)
+		}
+		authenticator = a
+	} else {
+		logger.Warnw("Authentication is disabled")
+	}
 
 	app := &application{
-		config:        cfg,
-		logger:        logger,
-		db:            conn,
-		rateLimiter:   rateLimiter,
-		store:         storage,
-		ticketService: ticketService,
+		config:            cfg,
+		logger:            logger,
+		db:                conn,
+		rateLimiter:       rateLimiter,
+		importRateLimiter: importRateLimiter,
+		ipRateLimiter:     ipRateLimiter,
+		trustedProxies:    trustedProxies,
+		store:             storage,
+		ticketService:     ticketService,
+		imports:           importManager,
+		idempotency:       idempotencyStore,
+		authenticator:     authenticator,
+		policy:            authz.Default,
 	}
 
 	expvar.NewString("version").Set(version)
@@ -96,3 +244,87 @@ func main() {
 
 	logger.Fatal(app.run(mux))
 }
+
+func newRateLimiters(cfg rateLimiterConfig, logger *zap.SugaredLogger) (standard, imports, ip ratelimiter.Limiter, err error) {
+	var newLimiter func(ratelimiter.Config) (ratelimiter.Limiter, error)
+
+	switch cfg.store {
+	case "memory":
+		newLimiter = ratelimiter.NewWithConfig
+	case "redis":
+		store := ratelimiter.NewRedisStore(newRedisClient(cfg))
+		onError := func(err error) {
+			logger.Errorw("rate limit store unavailable, request allowed", "error", err)
+		}
+		newLimiter = func(c ratelimiter.Config) (ratelimiter.Limiter, error) {
+			return ratelimiter.NewWithStore(c, store, onError)
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown RATELIMITER_STORE %q, expected memory or redis", cfg.store)
+	}
+
+	if standard, err = newLimiter(cfg.standard); err != nil {
+		return nil, nil, nil, err
+	}
+	if imports, err = newLimiter(cfg.imports); err != nil {
+		return nil, nil, nil, err
+	}
+	if ip, err = newLimiter(cfg.ip); err != nil {
+		return nil, nil, nil, err
+	}
+	return standard, imports, ip, nil
+}
+
+func newRedisClient(cfg rateLimiterConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.redisAddr,
+		Password: cfg.redisPassword,
+		DB:       cfg.redisDB,
+	})
+}
+
+func newIdempotencyStore(cfg idempotencyConfig, rl rateLimiterConfig) (idempotency.Store, error) {
+	switch cfg.store {
+	case "memory":
+		return idempotency.NewMemoryStore(time.Minute), nil
+	case "redis":
+		return idempotency.NewRedisStore(newRedisClient(rl)), nil
+	default:
+		return nil, fmt.Errorf("unknown IDEMPOTENCY_STORE %q, expected memory or redis", cfg.store)
+	}
+}
+
+func newAuthenticator(cfg authConfig) (*auth.Authenticator, error) {
+	authCfg := auth.Config{
+		HMACSecret: []byte(cfg.jwtSecret),
+		Issuer:     cfg.jwtIssuer,
+		Audience:   cfg.jwtAudience,
+		Leeway:     30 * time.Second,
+	}
+
+	if cfg.jwtSecretFile != "" {
+		secret, err := os.ReadFile(cfg.jwtSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading JWT secret file: %w", err)
+		}
+		authCfg.HMACSecret = bytes.TrimSpace(secret)
+	}
+
+	if cfg.jwtPublicKeyFile != "" {
+		key, err := auth.LoadRSAPublicKey(cfg.jwtPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		authCfg.RSAPublicKey = key
+	}
+
+	if cfg.apiKeysFile != "" {
+		keys, err := auth.LoadAPIKeys(cfg.apiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		authCfg.APIKeys = keys
+	}
+
+	return auth.New(authCfg)
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/audit"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/auth"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/authz"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/i18n"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/idempotency"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/ratelimiter"
+)
+
+// localize picks the language of the messages from Accept-Language and
+// stores it in the request context, see i18n.FromContext.
+func (app *application) localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tag := i18n.Match(r.Header.Get("Accept-Language"))
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Set("Content-Language", tag.String())
+		next.ServeHTTP(w, r.WithContext(i18n.NewContext(r.Context(), tag)))
+	})
+}
+
+// authenticate rejects requests without valid credentials and stores the
+// caller in the request context. It is a no-op when authentication is
+// disabled.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.authenticator == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := app.authenticator.Authenticate(r)
+		if err != nil {
+			app.unauthorizedResponse(w, r, err)
+			return
+		}
+
+		ctx := auth.WithPrincipal(r.Context(), principal)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// auditContext stores the caller and request ID in the context so that every
+// ticket change made while serving the request can be traced back to them.
+func (app *application) auditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := audit.Metadata{
+			RequestID: middleware.GetReqID(r.Context()),
+			Source:    audit.SourceREST,
+		}
+		if p := auth.PrincipalFromContext(r.Context()); p != nil {
+			m.Actor = p.Subject
+		}
+
+		ctx := audit.WithMetadata(r.Context(), m)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requirePermission rejects callers whose roles do not grant perm. Requests
+// pass through when authentication is disabled.
+func (app *application) requirePermission(perm authz.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.authenticator == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := app.policy.Authorize(principalRoles(r), perm); err != nil {
+				app.forbiddenResponse(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authorizeFields writes a 403 response and returns false when the caller may
+// not change one of fields.
+func (app *application) authorizeFields(w http.ResponseWriter, r *http.Request, fields []string) bool {
+	if app.authenticator == nil {
+		return true
+	}
+
+	if err := app.policy.AuthorizeFields(principalRoles(r), fields); err != nil {
+		app.forbiddenResponse(w, r, err)
+		return false
+	}
+	return true
+}
+
+func principalRoles(r *http.Request) []string {
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		return p.Roles
+	}
+	return nil
+}
+
+// realIP replaces RemoteAddr with the client address forwarded by one of the
+// trusted proxies, see forwardedIP. Headers sent by any other peer are
+// ignored, otherwise clients could pick the IP their rate limit is keyed by.
+func (app *application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := forwardedIP(r, app.trustedProxies); ok {
+			r.RemoteAddr = ip
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedIP returns the client address when the peer is a trusted proxy.
+// X-Forwarded-For is read from the right: the first address that is not a
+// trusted proxy is the client, anything to its left was written by the
+// client itself. X-Real-IP is used when X-Forwarded-For is absent.
+func forwardedIP(r *http.Request, trusted []netip.Prefix) (string, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !isTrustedProxy(peer.Addr(), trusted) {
+		return "", false
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+		if err != nil {
+			return "", false
+		}
+		return addr.Unmap().String(), true
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return "", false
+		}
+		if addr = addr.Unmap(); !isTrustedProxy(addr, trusted) {
+			return addr.String(), true
+		}
+	}
+	return "", false
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a comma separated list of IPs and CIDRs.
+func parseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", field, err)
+			}
+			addr = addr.Unmap()
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", field, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// rateLimit applies limiter per caller, see rateLimitKey.
+func (app *application) rateLimit(limiter ratelimiter.Limiter) func(http.Handler) http.Handler {
+	return app.limit(limiter, rateLimitKey)
+}
+
+// rateLimitIP applies limiter per client IP. It goes before authenticate so
+// that requests with bad credentials are limited too.
+func (app *application) rateLimitIP(limiter ratelimiter.Limiter) func(http.Handler) http.Handler {
+	return app.limit(limiter, ipKey)
+}
+
+// limit applies limiter per key(r). A nil limiter lets every request pass.
+func (app *application) limit(limiter ratelimiter.Limiter, key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res := limiter.Allow(key(r))
+			ratelimiter.SetHeaders(w.Header(), res)
+
+			if !res.Allowed {
+				app.rateLimitExceededResponse(w, r, res.RetryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey identifies the caller by authenticated principal or, for
+// anonymous requests, by client IP.
+func rateLimitKey(r *http.Request) string {
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		return "principal:" + p.Subject
+	}
+	return ipKey(r)
+}
+
+// ipKey identifies the caller by client IP. realIP has already replaced
+// RemoteAddr with the forwarded address when the peer is a trusted proxy.
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// idempotent lets clients retry a request safely by sending an
+// Idempotency-Key header. The first request with a key is served and its
+// response stored for the configured TTL; a retry with the same key and
+// payload gets that response replayed, marked with Idempotent-Replayed.
+// Keys are scoped to the caller, see rateLimitKey. Reusing a key with
+// another payload is rejected with 422, and a retry arriving while the first
+// request is still being served with 409. Server errors and responses over
+// idempotency.MaxRecordBody are not stored, so the request can be retried.
+// Requests without the header pass through.
+func (app *application) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.Header)
+		if key == "" || app.idempotency == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !idempotency.ValidKey(key) {
+			app.badRequestResponse(w, r, apierror.Newf(apierror.CodeInvalidRequest, "%s inválida: se esperaban entre 1 y %d caracteres ASCII visibles", idempotency.Header, idempotency.MaxKeyLength))
+			return
+		}
+
+		// Bodies are read whole before the handler gets to limit them.
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+		body, fingerprint, err := idempotency.ReadBody(r)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("error leyendo el cuerpo de la solicitud: %w", err))
+			return
+		}
+		defer body.Close()
+		r.Body = body
+
+		// The response is stored even if the client went away meanwhile:
+		// that is precisely when it will retry.
+		ctx := context.WithoutCancel(r.Context())
+		storeKey := rateLimitKey(r) + ":" + key
+
+		rec, err := app.idempotency.Begin(ctx, storeKey, fingerprint, idempotency.PendingTTL)
+		if err != nil {
+			e := apierror.Newf(apierror.CodeUnavailable, "no se pudo verificar la %s", idempotency.Header)
+			e.Err = err
+			app.serviceUnavailableResponse(w, r, e)
+			return
+		}
+		if rec != nil {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				app.problemResponse(w, r, apierror.Newf(apierror.CodeIdempotencyKeyReused, "la %s ya se usó con otra solicitud", idempotency.Header))
+			case rec.Pending():
+				app.problemResponse(w, r, apierror.Newf(apierror.CodeIdempotencyKeyInUse, "una solicitud con la misma %s está en curso", idempotency.Header))
+			default:
+				app.logger.Infow("idempotent response replayed", "method", r.Method, "path", r.URL.Path, "status", rec.Status)
+				_ = idempotency.Replay(w, rec)
+			}
+			return
+		}
+
+		rw := idempotency.NewRecorder(w)
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			if err := app.idempotency.Abort(ctx, storeKey); err != nil {
+				app.logger.Errorw("error liberando la clave de idempotencia", "error", err)
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+
+		if rw.Status() >= http.StatusInternalServerError {
+			return
+		}
+		if rw.Status() == 0 {
+			rw.WriteHeader(http.StatusOK)
+		}
+		if rw.Truncated() {
+			app.logger.Warnw("respuesta idempotente demasiado grande, no se guarda", "method", r.Method, "path", r.URL.Path, "limit", idempotency.MaxRecordBody)
+			return
+		}
+		if err := app.idempotency.Finish(ctx, storeKey, rw.Record(fingerprint), app.config.idempotency.ttl); err != nil {
+			app.logger.Errorw("error guardando la respuesta idempotente", "error", err)
+			return
+		}
+		finished = true
+	})
+}
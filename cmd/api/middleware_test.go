@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/auth"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/idempotency"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/ratelimiter"
+)
+
+func TestForwardedIP(t *testing.T) {
+	trusted, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1,::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{"untrusted peer", "203.0.113.7:1234", []string{"198.51.100.1"}, "", "203.0.113.7:1234"},
+		{"untrusted peer real ip", "203.0.113.7:1234", nil, "198.51.100.1", "203.0.113.7:1234"},
+		{"trusted proxy", "10.1.2.3:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"spoofed left entries", "10.1.2.3:1234", []string{"1.1.1.1, 198.51.100.1"}, "", "198.51.100.1"},
+		{"proxy chain", "192.168.1.1:80", []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"}, "", "198.51.100.1"},
+		{"ipv6 proxy", "[::1]:80", []string{"2001:db8::1"}, "", "2001:db8::1"},
+		{"real ip", "10.1.2.3:1234", nil, "198.51.100.1", "198.51.100.1"},
+		{"malformed hop", "10.1.2.3:1234", []string{"198.51.100.1, nope"}, "", "10.1.2.3:1234"},
+		{"only proxies", "10.1.2.3:1234", []string{"10.0.0.2"}, "", "10.1.2.3:1234"},
+		{"no headers", "10.1.2.3:1234", nil, "", "10.1.2.3:1234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			var got string
+			app := &application{trustedProxies: trusted}
+			app.realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	got, err := parseTrustedProxies(" 10.1.2.3/8 ,,::ffff:192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].String() != "10.0.0.0/8" || got[1].String() != "192.168.1.1/32" {
+		t.Errorf("parseTrustedProxies = %v", got)
+	}
+
+	for _, s := range []string{"10.0.0.0/33", "proxy.local"} {
+		if _, err := parseTrustedProxies(s); err == nil {
+			t.Errorf("parseTrustedProxies(%q) succeeded", s)
+		}
+	}
+}
+
+// Requests with bad credentials count against the client IP.
+func TestRateLimitBeforeAuthentication(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{HMACSecret: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := ratelimiter.NewFixedWindowLimiter(2, time.Minute)
+	defer limiter.Stop()
+
+	app := &application{authenticator: authenticator, ipRateLimiter: limiter, logger: zap.NewNop().Sugar()}
+	mux := app.mount()
+
+	request := func(remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/v1/asset-replacement-tickets", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Authorization", "Bearer nope")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for range 2 {
+		if code := request("203.0.113.7:1234"); code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", code)
+		}
+	}
+	if code := request("203.0.113.7:4321"); code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", code)
+	}
+	if code := request("203.0.113.8:1234"); code != http.StatusUnauthorized {
+		t.Errorf("another IP got %d, want 401", code)
+	}
+}
+
+// Bodies over the upload limit are refused before they are spooled.
+func TestIdempotentBodyLimit(t *testing.T) {
+	store := idempotency.NewMemoryStore(time.Minute)
+	defer store.Stop()
+	app := &application{idempotency: store, logger: zap.NewNop().Sugar()}
+
+	var served int
+	h := app.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	request := func(key string, size int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/asset-replacement-tickets/upsert-csv", bytes.NewReader(make([]byte, size)))
+		r.Header.Set(idempotency.Header, key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request("too-large", maxUploadBytes+1); w.Code != http.StatusRequestEntityTooLarge || served != 0 {
+		t.Errorf("oversized body: status %d, served %d times, want 413 and not served", w.Code, served)
+	}
+	if w := request("at-limit", maxUploadBytes); w.Code != http.StatusOK || served != 1 {
+		t.Errorf("body at the limit: status %d, served %d times, want 200 and served", w.Code, served)
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	store := idempotency.NewMemoryStore(time.Minute)
+	defer store.Stop()
+	app := &application{idempotency: store, logger: zap.NewNop().Sugar()}
+	app.config.idempotency.ttl = time.Hour
+
+	var served int
+	started, release := make(chan struct{}), make(chan struct{})
+	h := app.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		if r.URL.Query().Has("wait") {
+			close(started)
+			<-release
+		}
+		size := 10
+		if r.URL.Query().Has("large") {
+			size = idempotency.MaxRecordBody + 1
+		}
+		w.Header().Set("Location", "/v1/asset-replacement-tickets/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(bytes.Repeat([]byte("x"), size))
+	}))
+
+	request := func(target, key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		r.Header.Set(idempotency.Header, key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		first := request("/", "k1", `{"ticket_id": 1}`)
+		retry := request("/", "k1", `{"ticket_id": 1}`)
+		if served != 1 {
+			t.Fatalf("served %d times, want 1", served)
+		}
+		if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() ||
+			retry.Header().Get("Location") != first.Header().Get("Location") || retry.Header().Get(idempotency.ReplayedHeader) != "true" {
+			t.Errorf("retry = %d %v %q, want the first response replayed", retry.Code, retry.Header(), retry.Body)
+		}
+		if first.Header().Get(idempotency.ReplayedHeader) != "" {
+			t.Error("the first response is marked as replayed")
+		}
+	})
+
+	t.Run("reused with another payload", func(t *testing.T) {
+		served = 0
+		request("/", "k2", `{"ticket_id": 1}`)
+		if w := request("/", "k2", `{"ticket_id": 2}`); w.Code != http.StatusUnprocessableEntity || served != 1 {
+			t.Errorf("status = %d, served %d times, want 422 and 1", w.Code, served)
+		}
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		served = 0
+		done := make(chan int)
+		go func() { done <- request("/?wait", "k3", "").Code }()
+		<-started
+
+		if w := request("/?wait", "k3", ""); w.Code != http.StatusConflict {
+			t.Errorf("retry while pending = %d, want 409", w.Code)
+		}
+		close(release)
+		if code := <-done; code != http.StatusCreated {
+			t.Errorf("first request = %d, want 201", code)
+		}
+		if served != 1 {
+			t.Errorf("served %d times, want 1", served)
+		}
+	})
+
+	// Responses too large to keep are not replayed: the retry is served again.
+	t.Run("large response", func(t *testing.T) {
+		served = 0
+		for range 2 {
+			if w := request("/?large", "k4", ""); w.Code != http.StatusCreated || w.Body.Len() != idempotency.MaxRecordBody+1 {
+				t.Fatalf("status = %d with %d bytes", w.Code, w.Body.Len())
+			}
+		}
+		if served != 2 {
+			t.Errorf("served %d times, want 2", served)
+		}
+	})
+}
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/cmd/api/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/auth"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/authz"
+	internalDTO "github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/idempotency"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/imports"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/openapi"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
+)
+
+const openAPIPath = "/v1/openapi.json"
+
+// openAPISpec is the encoded apiDocument, built on first use.
+var openAPISpec = sync.OnceValues(func() ([]byte, error) {
+	return json.MarshalIndent(apiDocument(), "", "  ")
+})
+
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	spec, err := openAPISpec()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// apiDocument describes every route of mount. The schemas are generated from
+// the types the handlers read and write; TestOpenAPIRoutes fails when a
+// route is added to mount and not here, or the other way round.
+func apiDocument() *openapi.Document {
+	c := openapi.NewComponents()
+	s := specBuilder{c: c}
+
+	c.SecuritySchemes["bearerAuth"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	c.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{Type: "apiKey", In: "header", Name: auth.APIKeyHeader}
+
+	// Enums first, so the structs using their types reference them.
+	var codes []any
+	for _, code := range apierror.Codes() {
+		codes = append(codes, code)
+	}
+	c.Enum(codes...)
+	var stages []any
+	for _, stage := range workflow.Stages() {
+		stages = append(stages, stage)
+	}
+	c.Enum(stages...)
+	c.Enum(imports.StateQueued, imports.StateRunning, imports.StateSucceeded, imports.StateFailed, imports.StateCancelled)
+
+	problem := c.Schema(apierror.Problem{})
+	c.Schemas["Problem"].Description = "Error RFC 7807. Algunos problemas agregan miembros, como current, el ticket vigente, en un 412."
+	c.Schema(CreateTicketPayload{})
+	c.Schema(UpdateTicketPayload{})
+	c.Schema(TransitionPayload{})
+	c.Schemas["TransitionPayload"].Properties["to"] = openapi.Ref("Stage")
+	c.Schema(ImportProfilePayload{})
+	c.Schema(dto.TicketResponse{})
+	c.Schema(TicketListResponse{})
+	c.Schema(BasicTicket{})
+	c.Schema(store.AuditEntry{})
+	c.Schema(store.ImportProfile{})
+	c.Schema(internalDTO.TicketUpsertDTO{})
+	c.Schema(internalDTO.TicketUpsertResponse{})
+	rows := c.Schemas["TicketRowResult"].Properties
+	rows["status"].Enum = []any{internalDTO.RowStatusInserted, internalDTO.RowStatusUpdated, internalDTO.RowStatusUnchanged, internalDTO.RowStatusSkipped, internalDTO.RowStatusFailed}
+	rows["code"].Enum = []any{internalDTO.RowCodeInvalidRow, internalDTO.RowCodeInvalidTicketID, internalDTO.RowCodeSerialInUse, internalDTO.RowCodeTicketDeleted, internalDTO.RowCodeStoreError}
+	c.Schema(imports.Job{})
+	c.Schemas["Job"].Properties["format"].Enum = []any{imports.FormatCSV, imports.FormatXLSX}
+
+	s.parameters()
+	s.headers()
+	s.responses(problem)
+
+	tickets := "/v1/asset-replacement-tickets"
+	ticket := tickets + "/{ticketID}"
+	s.add("get", "/v1/health", s.public(&openapi.Operation{
+		Tags:        []string{"ops"},
+		Summary:     "Healthcheck",
+		Description: "Prueba de conexión y entorno.",
+		OperationID: "healthCheck",
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Servicio disponible.", &openapi.Schema{
+				Type:     "object",
+				Required: []string{"env", "status", "version"},
+				Properties: map[string]*openapi.Schema{
+					"status":  {Type: "string", Enum: []any{"ok"}},
+					"env":     {Type: "string"},
+					"version": {Type: "string"},
+				},
+			}),
+		},
+	}))
+	s.add("get", "/v1/debug/vars", s.public(&openapi.Operation{
+		Tags:        []string{"ops"},
+		Summary:     "Métricas expvar",
+		Description: "Variables publicadas con expvar: memstats, cmdline, version.",
+		OperationID: "debugVars",
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Variables del proceso.", &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{}}),
+		},
+	}))
+	s.add("get", openAPIPath, s.public(&openapi.Operation{
+		Tags:        []string{"ops"},
+		Summary:     "Especificación OpenAPI",
+		Description: "Este documento.",
+		OperationID: "openAPI",
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Documento OpenAPI 3.", &openapi.Schema{Type: "object"}),
+		},
+	}))
+	s.add("get", "/v1/docs", s.public(&openapi.Operation{
+		Tags:        []string{"ops"},
+		Summary:     "Documentación interactiva",
+		Description: "Página que lista las operaciones de " + openAPIPath + " y permite probarlas.",
+		OperationID: "docs",
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Página HTML.", Content: map[string]openapi.MediaType{"text/html": {Schema: &openapi.Schema{Type: "string"}}}},
+		},
+	}))
+
+	s.add("get", tickets, s.protected(authz.PermTicketsRead, &openapi.Operation{
+		Tags:    []string{"tickets"},
+		Summary: "Listar tickets",
+		Description: "Tickets que cumplen los filtros, ordenados por sort. Las páginas se recorren con el cursor de next_cursor y prev_cursor, " +
+			"también enviados como encabezados Link (RFC 8288), o con page. Sin stage se listan las etapas pendientes.",
+		OperationID: "listTickets",
+		Parameters:  append(s.refs(ticketFilterParams...), s.refs("sort", "cursor", "page", "limit")...),
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Página de tickets.", s.data(openapi.Ref("TicketListResponse")), "Link"),
+			"400": openapi.ResponseRef("BadRequest"),
+		},
+	}))
+	s.add("post", tickets, s.idempotent(s.protected(authz.PermTicketsCreate, &openapi.Operation{
+		Tags:        []string{"tickets"},
+		Summary:     "Crear ticket",
+		Description: "Crea el ticket en la etapa inicial. Los campos de compras y finanzas requieren además sus permisos.",
+		OperationID: "createTicket",
+		RequestBody: s.body(openapi.Ref("CreateTicketPayload")),
+		Responses: map[string]*openapi.Response{
+			"201": s.json("Ticket creado.", s.data(openapi.Ref("TicketResponse")), "ETag"),
+			"400": openapi.ResponseRef("BadRequest"),
+			"409": openapi.ResponseRef("Conflict"),
+			"413": openapi.ResponseRef("PayloadTooLarge"),
+		},
+	})))
+	s.add("get", tickets+"/basic", s.protected(authz.PermTicketsRead, &openapi.Operation{
+		Tags:        []string{"tickets"},
+		Summary:     "Listar tickets (resumen)",
+		Description: "Todos los tickets con sus datos de compra.",
+		OperationID: "listBasicTickets",
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Tickets.", s.data(&openapi.Schema{Type: "array", Items: openapi.Ref("BasicTicket")})),
+		},
+	}))
+	s.add("get", tickets+"/export", s.protected(authz.PermTicketsRead, &openapi.Operation{
+		Tags:    []string{"tickets"},
+		Summary: "Exportar tickets",
+		Description: "Descarga los tickets que cumplen los filtros del listado. CSV y XLSX usan los nombres de columna de la importación, " +
+			"así el archivo puede editarse y subirse a /upsert-csv o /upsert-xlsx. NDJSON escribe un objeto por línea.",
+		OperationID: "exportTickets",
+		Parameters:  append(s.refs(ticketFilterParams...), s.refs("exportFormat")...),
+		Responses: map[string]*openapi.Response{
+			"200": {
+				Description: "Archivo adjunto.",
+				Headers:     map[string]*openapi.Header{"Content-Disposition": openapi.HeaderRef("Content-Disposition")},
+				Content: map[string]openapi.MediaType{
+					exportContentTypes["csv"]:    {Schema: &openapi.Schema{Type: "string"}},
+					exportContentTypes["xlsx"]:   {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+					exportContentTypes["ndjson"]: {Schema: &openapi.Schema{Type: "string"}},
+				},
+			},
+			"400": openapi.ResponseRef("BadRequest"),
+		},
+	}))
+
+	s.add("get", ticket, s.protected(authz.PermTicketsRead, &openapi.Operation{
+		Tags:        []string{"tickets"},
+		Summary:     "Obtener ticket",
+		Description: "El ETag es la versión del ticket; con If-None-Match se responde 304 si no cambió.",
+		OperationID: "getTicket",
+		Parameters:  s.refs("ticketID", "If-None-Match"),
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Ticket.", s.data(openapi.Ref("TicketResponse")), "ETag"),
+			"304": {Description: "El ticket no cambió.", Headers: map[string]*openapi.Header{"ETag": openapi.HeaderRef("ETag")}},
+			"400": openapi.ResponseRef("BadRequest"),
+			"404": openapi.ResponseRef("NotFound"),
+		},
+	}))
+	s.add("patch", ticket, s.protected(authz.PermTicketsUpdate, &openapi.Operation{
+		Tags:        []string{"tickets"},
+		Summary:     "Modificar ticket",
+		Description: "Aplica los campos enviados si el ticket sigue en la versión de If-Match. Los campos de compras y finanzas requieren además sus permisos.",
+		OperationID: "updateTicket",
+		Parameters:  s.refs("ticketID", "If-Match"),
+		RequestBody: s.body(openapi.Ref("UpdateTicketPayload")),
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Ticket modificado.", s.data(openapi.Ref("TicketResponse")), "ETag"),
+			"400": openapi.ResponseRef("BadRequest"),
+			"404": openapi.ResponseRef("NotFound"),
+			"409": openapi.ResponseRef("Conflict"),
+			"412": openapi.ResponseRef("PreconditionFailed"),
+			"413": openapi.ResponseRef("PayloadTooLarge"),
+			"428": openapi.ResponseRef("PreconditionRequired"),
+		},
+	}))
+	s.add("delete", ticket, s.protected(authz.PermTicketsDelete, &openapi.Operation{
+		Tags:        []string{"tickets"},
+		Summary:     "Eliminar ticket",
+		Description: "Elimina el ticket si sigue en la versión de If-Match.",
+		OperationID: "deleteTicket",
+		Parameters:  s.refs("ticketID", "If-Match"),
+		Responses: map[string]*openapi.Response{
+			"204": {Description: "Ticket eliminado."},
+			"400": openapi.ResponseRef("BadRequest"),
+			"404": openapi.ResponseRef("NotFound"),
+			"412": openapi.ResponseRef("PreconditionFailed"),
+			"428": openapi.ResponseRef("PreconditionRequired"),
+		},
+	}))
+	s.add("post", ticket+"/transitions", s.protected(authz.PermTicketsTransition, &openapi.Operation{
+		Tags:        []string{"tickets"},
+		Summary:     "Cambiar de etapa",
+		Description: "Mueve el ticket a la etapa to si sigue en la versión de If-Match, la transición está permitida y sus requisitos se cumplen.",
+		OperationID: "transitionTicket",
+		Parameters:  s.refs("ticketID", "If-Match"),
+		RequestBody: s.body(openapi.Ref("TransitionPayload")),
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Ticket en la nueva etapa.", s.data(openapi.Ref("TicketResponse")), "ETag"),
+			"400": openapi.ResponseRef("BadRequest"),
+			"404": openapi.ResponseRef("NotFound"),
+			"409": openapi.ResponseRef("Conflict"),
+			"412": openapi.ResponseRef("PreconditionFailed"),
+			"428": openapi.ResponseRef("PreconditionRequired"),
+		},
+	}))
+	s.add("get", ticket+"/history", s.protected(authz.PermTicketsRead, &openapi.Operation{
+		Tags:        []string{"tickets"},
+		Summary:     "Historial del ticket",
+		Description: "Cambios registrados del ticket, con su autor y origen.",
+		OperationID: "getTicketHistory",
+		Parameters:  s.refs("ticketID"),
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Historial.", s.data(&openapi.Schema{Type: "array", Items: openapi.Ref("AuditEntry")})),
+			"400": openapi.ResponseRef("BadRequest"),
+			"404": openapi.ResponseRef("NotFound"),
+		},
+	}))
+
+	s.add("post", tickets+"/upsert-batch", s.idempotent(s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"imports"},
+		Summary:     "Actualizar tickets en lote (JSON)",
+		Description: "Inserta o actualiza cada ticket del lote y responde el resultado de cada fila. Un lote atómico revertido responde 422.",
+		OperationID: "upsertBatch",
+		Parameters:  s.refs("dry_run", "atomic", "upsertFormat"),
+		RequestBody: s.body(&openapi.Schema{Type: "array", Items: openapi.Ref("TicketUpsertDTO")}),
+		Responses:   s.upsertResponses(),
+	})))
+	for _, f := range []struct{ path, name, id string }{
+		{"/upsert-csv", "CSV", "upsertCSV"},
+		{"/upsert-xlsx", "XLSX", "upsertXLSX"},
+	} {
+		params := s.refs("dry_run", "atomic", "upsertFormat", "profile")
+		description := "Importa el archivo " + f.name + " de forma síncrona. Los encabezados son los nombres de los campos o los del perfil de importación profile."
+		if f.name == "XLSX" {
+			params = append(params, s.refs("sheet")...)
+			description += " Se lee la hoja sheet, la primera por defecto."
+		}
+		s.add("post", tickets+f.path, s.idempotent(s.protected(authz.PermTicketsImport, &openapi.Operation{
+			Tags:        []string{"imports"},
+			Summary:     "Actualizar tickets desde " + f.name,
+			Description: description,
+			OperationID: f.id,
+			Parameters:  params,
+			RequestBody: s.upload(),
+			Responses:   s.upsertResponses(),
+		})))
+	}
+
+	s.add("post", "/v1/imports", s.idempotent(s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"imports"},
+		Summary:     "Encolar importación",
+		Description: "Encola la importación de un CSV, o de un XLSX si el nombre termina en .xlsx, y responde 202 de inmediato. El avance se consulta en Location.",
+		OperationID: "createImport",
+		Parameters:  s.refs("dry_run", "atomic", "profile", "sheet"),
+		RequestBody: s.upload(),
+		Responses: map[string]*openapi.Response{
+			"202": s.json("Importación encolada.", s.data(openapi.Ref("Job")), "Location"),
+			"400": openapi.ResponseRef("BadRequest"),
+			"413": openapi.ResponseRef("PayloadTooLarge"),
+			"503": openapi.ResponseRef("ServiceUnavailable"),
+		},
+	})))
+	s.add("get", "/v1/imports", s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"imports"},
+		Summary:     "Listar importaciones",
+		OperationID: "listImports",
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Importaciones, sin sus filas.", s.data(&openapi.Schema{Type: "array", Items: openapi.Ref("Job")})),
+		},
+	}))
+	s.add("get", "/v1/imports/{importID}", s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"imports"},
+		Summary:     "Obtener importación",
+		Description: "La importación con el resultado de cada fila procesada hasta ahora, en JSON o, con format=csv, como el CSV anotado.",
+		OperationID: "getImport",
+		Parameters:  s.refs("importID", "upsertFormat"),
+		Responses: map[string]*openapi.Response{
+			"200": {
+				Description: "Importación.",
+				Content: map[string]openapi.MediaType{
+					"application/json": {Schema: s.data(openapi.Ref("Job"))},
+					"text/csv":         {Schema: &openapi.Schema{Type: "string"}},
+				},
+			},
+			"400": openapi.ResponseRef("BadRequest"),
+			"404": openapi.ResponseRef("NotFound"),
+		},
+	}))
+	s.add("post", "/v1/imports/{importID}/cancel", s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"imports"},
+		Summary:     "Cancelar importación",
+		Description: "Detiene la importación; las filas ya escritas se conservan.",
+		OperationID: "cancelImport",
+		Parameters:  s.refs("importID"),
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Importación cancelada.", s.data(openapi.Ref("Job"))),
+			"404": openapi.ResponseRef("NotFound"),
+			"409": openapi.ResponseRef("Conflict"),
+		},
+	}))
+
+	profiles := "/v1/import-profiles"
+	s.add("get", profiles, s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"import-profiles"},
+		Summary:     "Listar perfiles de importación",
+		OperationID: "listImportProfiles",
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Perfiles.", s.data(&openapi.Schema{Type: "array", Items: openapi.Ref("ImportProfile")})),
+		},
+	}))
+	s.add("get", profiles+"/{profileName}", s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"import-profiles"},
+		Summary:     "Obtener perfil de importación",
+		OperationID: "getImportProfile",
+		Parameters:  s.refs("profileName"),
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Perfil.", s.data(openapi.Ref("ImportProfile"))),
+			"404": openapi.ResponseRef("NotFound"),
+		},
+	}))
+	s.add("put", profiles+"/{profileName}", s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"import-profiles"},
+		Summary:     "Guardar perfil de importación",
+		Description: `Crea el perfil o reemplaza su mapeo de campos a encabezados, p. ej. {"no_serial": ["N° Serie"]}.`,
+		OperationID: "saveImportProfile",
+		Parameters:  s.refs("profileName"),
+		RequestBody: s.body(openapi.Ref("ImportProfilePayload")),
+		Responses: map[string]*openapi.Response{
+			"200": s.json("Perfil guardado.", s.data(openapi.Ref("ImportProfile"))),
+			"400": openapi.ResponseRef("BadRequest"),
+			"413": openapi.ResponseRef("PayloadTooLarge"),
+		},
+	}))
+	s.add("delete", profiles+"/{profileName}", s.protected(authz.PermTicketsImport, &openapi.Operation{
+		Tags:        []string{"import-profiles"},
+		Summary:     "Eliminar perfil de importación",
+		OperationID: "deleteImportProfile",
+		Parameters:  s.refs("profileName"),
+		Responses: map[string]*openapi.Response{
+			"204": {Description: "Perfil eliminado."},
+			"404": openapi.ResponseRef("NotFound"),
+		},
+	}))
+
+	return &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title: "Assets Replacement Management API",
+			Description: "Los errores son application/problem+json (RFC 7807) con un code estable, ver Problem. " +
+				"Los mensajes se devuelven en el idioma de Accept-Language, español por defecto.",
+			Version: version,
+		},
+		Tags: []openapi.Tag{
+			{Name: "tickets", Description: "Tickets de reemplazo de activos"},
+			{Name: "imports", Description: "Actualización de tickets en lote"},
+			{Name: "import-profiles", Description: "Mapeos de encabezados de archivos"},
+			{Name: "ops", Description: "Operación del servicio"},
+		},
+		Paths:      s.paths,
+		Components: c,
+		Security:   []openapi.SecurityRequirement{{"bearerAuth": {}}, {"apiKey": {}}},
+	}
+}
+
+// ticketFilterParams are the query parameters read by ticketFilter.
+var ticketFilterParams = []string{"stage", "center_dist_id", "category_id", "center_dist", "supplier", "capex", "no_serial", "has_invoice", "created_from", "created_to", "updated_from", "updated_to", "q"}
+
+// specBuilder holds the paths of apiDocument as they are added.
+type specBuilder struct {
+	c     *openapi.Components
+	paths map[string]openapi.PathItem
+}
+
+func (s *specBuilder) add(method, path string, op *openapi.Operation) {
+	if s.paths == nil {
+		s.paths = make(map[string]openapi.PathItem)
+	}
+	if s.paths[path] == nil {
+		s.paths[path] = make(openapi.PathItem)
+	}
+	op.Parameters = append(op.Parameters, openapi.ParameterRef("Accept-Language"))
+	op.Responses["500"] = openapi.ResponseRef("InternalError")
+	s.paths[path][method] = op
+}
+
+// public marks op as open to anonymous callers.
+func (s *specBuilder) public(op *openapi.Operation) *openapi.Operation {
+	op.Security = &[]openapi.SecurityRequirement{}
+	return op
+}
+
+// protected adds the answers of authenticate, requirePermission and
+// rateLimit to op.
+func (s *specBuilder) protected(perm authz.Permission, op *openapi.Operation) *openapi.Operation {
+	note := fmt.Sprintf("Requiere el permiso %s.", perm)
+	op.Description = strings.TrimSpace(op.Description + "\n\n" + note)
+	op.Responses["401"] = openapi.ResponseRef("Unauthorized")
+	op.Responses["403"] = openapi.ResponseRef("Forbidden")
+	op.Responses["429"] = openapi.ResponseRef("TooManyRequests")
+	return op
+}
+
+// idempotent adds the Idempotency-Key header and the answers of the
+// idempotent middleware to op.
+func (s *specBuilder) idempotent(op *openapi.Operation) *openapi.Operation {
+	op.Parameters = append(op.Parameters, openapi.ParameterRef(idempotency.Header))
+	for status, name := range map[string]string{"409": "Conflict", "422": "IdempotencyKeyReused", "503": "ServiceUnavailable"} {
+		if op.Responses[status] == nil {
+			op.Responses[status] = openapi.ResponseRef(name)
+		}
+	}
+	for _, r := range op.Responses {
+		if r.Ref == "" && r.Content != nil {
+			if r.Headers == nil {
+				r.Headers = make(map[string]*openapi.Header)
+			}
+			r.Headers[idempotency.ReplayedHeader] = openapi.HeaderRef(idempotency.ReplayedHeader)
+		}
+	}
+	return op
+}
+
+func (s *specBuilder) refs(names ...string) []*openapi.Parameter {
+	params := make([]*openapi.Parameter, len(names))
+	for i, name := range names {
+		if s.c.Parameters[name] == nil {
+			panic("openapi: parámetro no definido: " + name)
+		}
+		params[i] = openapi.ParameterRef(name)
+	}
+	return params
+}
+
+// data wraps schema in the envelope of jsonResponse.
+func (s *specBuilder) data(schema *openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{
+		Type:       "object",
+		Required:   []string{"data"},
+		Properties: map[string]*openapi.Schema{"data": schema},
+	}
+}
+
+func (s *specBuilder) json(description string, schema *openapi.Schema, headers ...string) *openapi.Response {
+	r := &openapi.Response{
+		Description: description,
+		Content:     map[string]openapi.MediaType{"application/json": {Schema: schema}},
+	}
+	for _, h := range headers {
+		if r.Headers == nil {
+			r.Headers = make(map[string]*openapi.Header)
+		}
+		r.Headers[h] = openapi.HeaderRef(h)
+	}
+	return r
+}
+
+func (s *specBuilder) body(schema *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content:  map[string]openapi.MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// upload is the multipart form of the import endpoints.
+func (s *specBuilder) upload() *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content: map[string]openapi.MediaType{"multipart/form-data": {Schema: &openapi.Schema{
+			Type:       "object",
+			Required:   []string{"file"},
+			Properties: map[string]*openapi.Schema{"file": {Type: "string", Format: "binary"}},
+		}}},
+	}
+}
+
+// upsertResponses are the answers of writeUpsertResponse.
+func (s *specBuilder) upsertResponses() map[string]*openapi.Response {
+	result := func(description string) *openapi.Response {
+		return &openapi.Response{
+			Description: description,
+			Content: map[string]openapi.MediaType{
+				"application/json": {Schema: s.data(openapi.Ref("TicketUpsertResponse"))},
+				"text/csv":         {Schema: &openapi.Schema{Type: "string", Description: "Con format=csv, las filas enviadas con su estado."}},
+			},
+		}
+	}
+	return map[string]*openapi.Response{
+		"200": result("Resultado de cada fila."),
+		"400": openapi.ResponseRef("BadRequest"),
+		"413": openapi.ResponseRef("PayloadTooLarge"),
+		"422": result("Lote atómico revertido en la primera fila fallida, o Idempotency-Key reutilizada (application/problem+json)."),
+	}
+}
+
+func (s *specBuilder) parameters() {
+	query := func(name, description string, schema *openapi.Schema) {
+		s.c.Parameters[name] = &openapi.Parameter{Name: name, In: "query", Description: description, Schema: schema}
+	}
+	str := func() *openapi.Schema { return &openapi.Schema{Type: "string"} }
+	integer := func() *openapi.Schema { return &openapi.Schema{Type: "integer", Format: "int64"} }
+	boolean := func() *openapi.Schema { return &openapi.Schema{Type: "boolean"} }
+	date := func() *openapi.Schema { return &openapi.Schema{Type: "string", Example: "2024-01-31"} }
+
+	query("stage", `Etapas separadas por coma, o "all". Las etapas pendientes por defecto.`, str())
+	query("center_dist_id", "", integer())
+	query("category_id", "", integer())
+	query("center_dist", "Valor exacto.", str())
+	query("supplier", "Parte del proveedor, sin distinguir mayúsculas.", str())
+	query("capex", "Valor exacto.", str())
+	query("no_serial", "Valor exacto.", str())
+	query("has_invoice", "", boolean())
+	query("created_from", "Fecha YYYY-MM-DD o instante RFC 3339.", date())
+	query("created_to", "Fecha YYYY-MM-DD, incluida, o instante RFC 3339, excluido.", date())
+	query("updated_from", "Fecha YYYY-MM-DD o instante RFC 3339.", date())
+	query("updated_to", "Fecha YYYY-MM-DD, incluida, o instante RFC 3339, excluido.", date())
+	query("q", "Parte del ID, serie, orden, CAPEX, factura, proveedor o centro.", str())
+
+	query("sort", "Campos separados por coma, con - para orden descendente. Uno de: "+strings.Join(store.SortFields(), ", ")+".",
+		&openapi.Schema{Type: "string", Default: "-created_at", Example: "-created_at,supplier"})
+	query("cursor", "next_cursor o prev_cursor de una página anterior. Reemplaza a page.", str())
+	query("page", "Página, desde 1, si no se usa cursor.", &openapi.Schema{Type: "integer", Default: 1})
+	limit := &openapi.Schema{Type: "integer", Default: defaultPageLimit}
+	maxLimit := float64(maxPageLimit)
+	limit.Maximum = &maxLimit
+	query("limit", "Tickets por página.", limit)
+
+	query("dry_run", "Valida y simula el lote sin guardar cambios.", boolean())
+	query("atomic", "Guarda todo el lote o nada.", boolean())
+	query("profile", "Perfil de importación con el mapeo de encabezados.", str())
+	query("sheet", "Hoja del XLSX, por nombre o posición desde 1.", str())
+	s.c.Parameters["upsertFormat"] = &openapi.Parameter{Name: "format", In: "query", Description: "Formato de la respuesta.",
+		Schema: &openapi.Schema{Type: "string", Enum: []any{"json", "csv"}, Default: "json"}}
+	s.c.Parameters["exportFormat"] = &openapi.Parameter{Name: "format", In: "query", Description: "Formato del archivo.",
+		Schema: &openapi.Schema{Type: "string", Enum: []any{"csv", "xlsx", "ndjson"}, Default: "csv"}}
+
+	s.c.Parameters["ticketID"] = &openapi.Parameter{Name: "ticketID", In: "path", Required: true, Schema: integer()}
+	s.c.Parameters["importID"] = &openapi.Parameter{Name: "importID", In: "path", Required: true, Schema: str()}
+	s.c.Parameters["profileName"] = &openapi.Parameter{Name: "profileName", In: "path", Required: true,
+		Schema: &openapi.Schema{Type: "string", Pattern: profileNamePattern.String()}}
+
+	maxKey := idempotency.MaxKeyLength
+	s.c.Parameters[idempotency.Header] = &openapi.Parameter{Name: idempotency.Header, In: "header",
+		Description: "Permite reintentar la solicitud: un reintento con la misma clave y cuerpo recibe la respuesta original.",
+		Schema:      &openapi.Schema{Type: "string", MaxLength: &maxKey}}
+	s.c.Parameters["If-Match"] = &openapi.Parameter{Name: "If-Match", In: "header", Required: true,
+		Description: "ETag del ticket leído.", Schema: str()}
+	s.c.Parameters["If-None-Match"] = &openapi.Parameter{Name: "If-None-Match", In: "header",
+		Description: "ETag del ticket en caché.", Schema: str()}
+	s.c.Parameters["Accept-Language"] = &openapi.Parameter{Name: "Accept-Language", In: "header",
+		Description: "Idioma de los mensajes.", Schema: &openapi.Schema{Type: "string", Enum: []any{"es", "en"}, Default: "es"}}
+}
+
+func (s *specBuilder) headers() {
+	header := func(name, description string, schema *openapi.Schema) {
+		s.c.Headers[name] = &openapi.Header{Description: description, Schema: schema}
+	}
+	str := &openapi.Schema{Type: "string"}
+	integer := &openapi.Schema{Type: "integer"}
+
+	header("ETag", "Versión del ticket, para If-Match e If-None-Match.", str)
+	header("Link", "Enlaces RFC 8288 a las páginas next, prev y first.", str)
+	header("Location", "URL de la importación.", str)
+	header("Content-Disposition", "Nombre del archivo adjunto.", str)
+	header(idempotency.ReplayedHeader, "true si la respuesta es la guardada para la Idempotency-Key.", str)
+	header("RateLimit-Limit", "Solicitudes permitidas en la ventana.", integer)
+	header("RateLimit-Remaining", "Solicitudes restantes en la ventana.", integer)
+	header("RateLimit-Reset", "Segundos hasta que la ventana se renueve.", integer)
+	header("Retry-After", "Segundos a esperar antes de reintentar.", integer)
+}
+
+// responses adds a problem response for each error status, listing the codes
+// it can carry.
+func (s *specBuilder) responses(problem *openapi.Schema) {
+	byStatus := make(map[int][]string)
+	for _, code := range apierror.Codes() {
+		byStatus[code.Status()] = append(byStatus[code.Status()], string(code))
+	}
+
+	add := func(name string, status int, headers ...string) {
+		r := &openapi.Response{
+			Description: http.StatusText(status) + ". Códigos: " + strings.Join(byStatus[status], ", ") + ".",
+			Content:     map[string]openapi.MediaType{apierror.ContentType: {Schema: problem}},
+		}
+		for _, h := range headers {
+			if r.Headers == nil {
+				r.Headers = make(map[string]*openapi.Header)
+			}
+			r.Headers[h] = openapi.HeaderRef(h)
+		}
+		s.c.Responses[name] = r
+	}
+	add("BadRequest", http.StatusBadRequest)
+	add("Unauthorized", http.StatusUnauthorized)
+	add("Forbidden", http.StatusForbidden)
+	add("NotFound", http.StatusNotFound)
+	add("Conflict", http.StatusConflict)
+	add("PreconditionFailed", http.StatusPreconditionFailed, "ETag")
+	add("PayloadTooLarge", http.StatusRequestEntityTooLarge)
+	add("IdempotencyKeyReused", http.StatusUnprocessableEntity)
+	add("PreconditionRequired", http.StatusPreconditionRequired)
+	add("TooManyRequests", http.StatusTooManyRequests, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After")
+	add("InternalError", http.StatusInternalServerError)
+	add("ServiceUnavailable", http.StatusServiceUnavailable, "Retry-After")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/openapi"
+)
+
+// TestOpenAPIRoutes fails when mount and apiDocument disagree on the routes.
+func TestOpenAPIRoutes(t *testing.T) {
+	app := &application{}
+	var routes []string
+	err := chi.Walk(app.mount().(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		routes = append(routes, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var documented []string
+	for path, item := range apiDocument().Paths {
+		for method := range item {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	for _, route := range routes {
+		if !slices.Contains(documented, route) {
+			t.Errorf("%s is served but not documented in apiDocument", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(routes, route) {
+			t.Errorf("%s is documented but not served by mount", route)
+		}
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+func TestOpenAPIParameters(t *testing.T) {
+	doc := apiDocument()
+	resolve := func(p *openapi.Parameter) *openapi.Parameter {
+		if name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/"); ok {
+			return doc.Components.Parameters[name]
+		}
+		return p
+	}
+
+	for path, item := range doc.Paths {
+		var want []string
+		for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+			want = append(want, m[1])
+		}
+		for method, op := range item {
+			var got []string
+			for _, p := range op.Parameters {
+				if p = resolve(p); p == nil {
+					t.Errorf("%s %s: undefined parameter", method, path)
+				} else if p.In == "path" {
+					got = append(got, p.Name)
+				}
+			}
+			slices.Sort(want)
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s %s: path parameters = %v, want %v", method, path, got, want)
+			}
+		}
+	}
+}
+
+// TestOpenAPIRefs checks every $ref of the document points to a component.
+func TestOpenAPIRefs(t *testing.T) {
+	spec, err := openAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				var target any = doc
+				for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]any)
+					target = m[key]
+				}
+				if target == nil {
+					t.Errorf("unresolved $ref %s", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	srv := httptest.NewServer((&application{}).mount())
+	defer srv.Close()
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{openAPIPath, "application/json", `"openapi": "3.0.3"`},
+		{"/v1/docs", "text/html; charset=utf-8", `const specURL = "\/v1\/openapi.json"`},
+	}
+	for _, tt := range tests {
+		res, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != tt.contentType {
+			t.Errorf("GET %s = %d %s, want 200 %s", tt.path, res.StatusCode, res.Header.Get("Content-Type"), tt.contentType)
+		}
+		if !strings.Contains(string(body), tt.contains) {
+			t.Errorf("GET %s does not contain %s", tt.path, tt.contains)
+		}
+	}
+}
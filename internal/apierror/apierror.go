@@ -0,0 +1,339 @@
+// Package apierror describes the errors of the API as RFC 7807 problem
+// details identified by a stable code. Domain packages declare their
+// sentinel errors with New, so handlers can turn any error, however wrapped,
+// into a problem with From.
+//
+// Details, titles and field messages are catalog keys of package i18n: they
+// are written in the code in one language and translated when the problem is
+// built for a client.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/i18n"
+)
+
+// ContentType is the media type of a Problem.
+const ContentType = "application/problem+json"
+
+// typePrefix makes a Code the type URI of its problems.
+const typePrefix = "urn:assets-replacement:problem:"
+
+// Code identifies a kind of error. Codes are part of the API contract:
+// clients branch on them, so they are never renamed.
+type Code string
+
+const (
+	CodeInvalidRequest        Code = "invalid_request"
+	CodeInvalidJSON           Code = "invalid_json"
+	CodeValidationFailed      Code = "validation_failed"
+	CodePayloadTooLarge       Code = "payload_too_large"
+	CodeInvalidFile           Code = "invalid_file"
+	CodeInvalidRow            Code = "invalid_row"
+	CodeInvalidTicketID       Code = "invalid_ticket_id"
+	CodeInvalidMapping        Code = "invalid_mapping"
+	CodeInvalidCursor         Code = "invalid_cursor"
+	CodeInvalidExportFormat   Code = "invalid_export_format"
+	CodeUnknownStage          Code = "unknown_stage"
+	CodeUnauthorized          Code = "unauthorized"
+	CodeForbidden             Code = "forbidden"
+	CodeNotFound              Code = "not_found"
+	CodeMethodNotAllowed      Code = "method_not_allowed"
+	CodeAlreadyExists         Code = "already_exists"
+	CodeSerialInUse           Code = "serial_in_use"
+	CodeIllegalTransition     Code = "illegal_transition"
+	CodeTransitionGuardFailed Code = "transition_guard_failed"
+	CodeVersionMismatch       Code = "version_mismatch"
+	CodeImportFinished        Code = "import_finished"
+	CodeIdempotencyKeyInUse   Code = "idempotency_key_in_use"
+	CodePreconditionFailed    Code = "precondition_failed"
+	CodeIdempotencyKeyReused  Code = "idempotency_key_reused"
+	CodePreconditionRequired  Code = "precondition_required"
+	CodeRateLimited           Code = "rate_limited"
+	CodeInternal              Code = "internal_error"
+	CodeUnavailable           Code = "service_unavailable"
+	CodeQueueFull             Code = "queue_full"
+)
+
+type codeInfo struct {
+	status int
+	title  string
+}
+
+var codes = map[Code]codeInfo{
+	CodeInvalidRequest:        {http.StatusBadRequest, "Invalid request"},
+	CodeInvalidJSON:           {http.StatusBadRequest, "Invalid JSON body"},
+	CodeValidationFailed:      {http.StatusBadRequest, "Validation failed"},
+	CodePayloadTooLarge:       {http.StatusRequestEntityTooLarge, "Request body too large"},
+	CodeInvalidFile:           {http.StatusBadRequest, "Invalid file"},
+	CodeInvalidRow:            {http.StatusBadRequest, "Invalid row"},
+	CodeInvalidTicketID:       {http.StatusBadRequest, "Invalid ticket ID"},
+	CodeInvalidMapping:        {http.StatusBadRequest, "Invalid column mapping"},
+	CodeInvalidCursor:         {http.StatusBadRequest, "Invalid cursor"},
+	CodeInvalidExportFormat:   {http.StatusBadRequest, "Invalid export format"},
+	CodeUnknownStage:          {http.StatusBadRequest, "Unknown stage"},
+	CodeUnauthorized:          {http.StatusUnauthorized, "Unauthorized"},
+	CodeForbidden:             {http.StatusForbidden, "Forbidden"},
+	CodeNotFound:              {http.StatusNotFound, "Resource not found"},
+	CodeMethodNotAllowed:      {http.StatusMethodNotAllowed, "Method not allowed"},
+	CodeAlreadyExists:         {http.StatusConflict, "Resource already exists"},
+	CodeSerialInUse:           {http.StatusConflict, "Serial in use"},
+	CodeIllegalTransition:     {http.StatusConflict, "Illegal stage transition"},
+	CodeTransitionGuardFailed: {http.StatusConflict, "Transition guard failed"},
+	CodeVersionMismatch:       {http.StatusConflict, "Resource was modified"},
+	CodeImportFinished:        {http.StatusConflict, "Import already finished"},
+	CodeIdempotencyKeyInUse:   {http.StatusConflict, "Idempotency key in use"},
+	CodePreconditionFailed:    {http.StatusPreconditionFailed, "Precondition failed"},
+	CodeIdempotencyKeyReused:  {http.StatusUnprocessableEntity, "Idempotency key reused"},
+	CodePreconditionRequired:  {http.StatusPreconditionRequired, "Precondition required"},
+	CodeRateLimited:           {http.StatusTooManyRequests, "Rate limit exceeded"},
+	CodeInternal:              {http.StatusInternalServerError, "Internal server error"},
+	CodeUnavailable:           {http.StatusServiceUnavailable, "Service unavailable"},
+	CodeQueueFull:             {http.StatusServiceUnavailable, "Import queue full"},
+}
+
+// Status is the HTTP status of the problems with code c.
+func (c Code) Status() int {
+	if info, ok := codes[c]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Title is the summary shared by every problem with code c, untranslated.
+func (c Code) Title() string {
+	if info, ok := codes[c]; ok {
+		return info.title
+	}
+	return http.StatusText(c.Status())
+}
+
+// Codes lists every code, sorted.
+func Codes() []Code {
+	list := make([]Code, 0, len(codes))
+	for c := range codes {
+		list = append(list, c)
+	}
+	slices.Sort(list)
+	return list
+}
+
+// FieldError is a problem with one field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// translate tells Message in a language, nil when it has no translation.
+	translate func(tag language.Tag) string
+}
+
+// localize returns f with its message in tag.
+func (f FieldError) localize(tag language.Tag) FieldError {
+	if f.translate != nil {
+		f.Message = f.translate(tag)
+	}
+	return f
+}
+
+// Error is an error with a Code. Sentinel errors made with New can be
+// wrapped with fmt.Errorf and %w like any other, or with Wrapf to have the
+// added context translated too.
+type Error struct {
+	Code   Code
+	Detail string
+	Fields []FieldError
+	// Extensions are added to the problem as extra members.
+	Extensions map[string]any
+	Err        error
+
+	// format and args are the catalog key and arguments of Detail. For
+	// errors made by Wrapf they only hold the context added to Err. Errors
+	// among args are translated with Message.
+	format string
+	args   []any
+}
+
+// New returns an error whose detail is also its catalog key.
+func New(code Code, detail string) *Error {
+	return &Error{Code: code, Detail: detail, format: detail}
+}
+
+// Newf is New with a detail formatted from the catalog key format.
+func Newf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Detail: fmt.Sprintf(format, args...), format: format, args: args}
+}
+
+// Wrapf adds the formatted context to base, like fmt.Errorf("%w: "+format),
+// keeping both translatable. errors.Is(err, base) holds.
+func Wrapf(base *Error, format string, args ...any) *Error {
+	return &Error{Code: base.Code, Detail: base.Detail + ": " + fmt.Sprintf(format, args...), Err: base, format: format, args: args}
+}
+
+// Wrap gives err a code, keeping its message as the detail.
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Detail: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return string(e.Code)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With returns a copy of e with an extension member.
+func (e *Error) With(name string, value any) *Error {
+	c := *e
+	c.Extensions = make(map[string]any, len(e.Extensions)+1)
+	for k, v := range e.Extensions {
+		c.Extensions[k] = v
+	}
+	c.Extensions[name] = value
+	return &c
+}
+
+// From returns the Error carried by err, with the message of the whole chain
+// as the detail: fmt.Errorf("%w: %s", ErrSerialInUse, serial) keeps the
+// serial. An error without a code is an internal error whose message is not
+// shown to the client.
+func From(err error) *Error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return &Error{Code: CodeInternal, Err: err}
+	}
+	if e == err {
+		return e
+	}
+	c := *e
+	c.Detail = err.Error()
+	c.Err = err
+	c.format, c.args = "", nil
+	return &c
+}
+
+// Message is the message of err in tag: the parts that come from an *Error
+// are translated and the rest is kept as is.
+func Message(tag language.Tag, err error) string {
+	return translate(i18n.Printer(tag), err)
+}
+
+func translate(p *message.Printer, err error) string {
+	var e *Error
+	if !errors.As(err, &e) {
+		return err.Error()
+	}
+	if e == err {
+		return e.localize(p)
+	}
+	return strings.Replace(err.Error(), e.Error(), e.localize(p), 1)
+}
+
+// localize returns the detail of e in the language of p.
+func (e *Error) localize(p *message.Printer) string {
+	if e.format == "" {
+		if e.Err == nil {
+			return e.Detail
+		}
+		return translate(p, e.Err)
+	}
+
+	args := make([]any, len(e.args))
+	for i, arg := range e.args {
+		if err, ok := arg.(error); ok {
+			arg = translate(p, err)
+		}
+		args[i] = arg
+	}
+	msg := p.Sprintf(e.format, args...)
+	if base, ok := e.Err.(*Error); ok {
+		return base.localize(p) + ": " + msg
+	}
+	return msg
+}
+
+// Default is From for errors that carry a code and Wrap(code, err) for the
+// others.
+func Default(err error, code Code) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return From(err)
+	}
+	return Wrap(code, err)
+}
+
+// Problem is the application/problem+json representation of an Error.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
+	Extensions map[string]any `json:"-"`
+}
+
+// Problem describes e in tag for the request to instance, identified by
+// requestID. An error without detail, such as an internal one, keeps it
+// hidden.
+func (e *Error) Problem(tag language.Tag, instance, requestID string) *Problem {
+	p := i18n.Printer(tag)
+	problem := &Problem{
+		Type:       typePrefix + string(e.Code),
+		Title:      p.Sprintf(e.Code.Title()),
+		Status:     e.Code.Status(),
+		Instance:   instance,
+		Code:       e.Code,
+		RequestID:  requestID,
+		Extensions: e.Extensions,
+	}
+	if e.Detail != "" {
+		problem.Detail = e.localize(p)
+	}
+	if len(e.Fields) > 0 {
+		problem.Errors = make([]FieldError, len(e.Fields))
+		for i, f := range e.Fields {
+			problem.Errors[i] = f.localize(tag)
+		}
+	}
+	return problem
+}
+
+// MarshalJSON adds the extensions to the standard members, which they
+// cannot replace.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	b, err := json.Marshal((*problem)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+
+	members := make(map[string]any, len(p.Extensions))
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	var standard map[string]json.RawMessage
+	if err := json.Unmarshal(b, &standard); err != nil {
+		return nil, err
+	}
+	for k, v := range standard {
+		members[k] = v
+	}
+	return json.Marshal(members)
+}
@@ -0,0 +1,190 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/i18n"
+)
+
+var errSentinel = New(CodeSerialInUse, "no_serial ya asignado a una orden activa")
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   Code
+		wantDetail string
+	}{
+		{"sentinel", errSentinel, CodeSerialInUse, "no_serial ya asignado a una orden activa"},
+		{"wrapped sentinel", fmt.Errorf("%w: SN-1", errSentinel), CodeSerialInUse, "no_serial ya asignado a una orden activa: SN-1"},
+		{"twice wrapped", fmt.Errorf("fila 3: %w", fmt.Errorf("%w: SN-1", errSentinel)), CodeSerialInUse, "fila 3: no_serial ya asignado a una orden activa: SN-1"},
+		{"wrapf", fmt.Errorf("fila 3: %w", Wrapf(errSentinel, "%s", "SN-1")), CodeSerialInUse, "fila 3: no_serial ya asignado a una orden activa: SN-1"},
+		{"no code", errors.New("ORA-12541: TNS:no listener"), CodeInternal, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := From(tt.err)
+			if got.Code != tt.wantCode || got.Detail != tt.wantDetail {
+				t.Errorf("From = %s %q, want %s %q", got.Code, got.Detail, tt.wantCode, tt.wantDetail)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Error("From does not wrap the error")
+			}
+			if tt.wantCode != CodeInternal && !errors.Is(got, errSentinel) {
+				t.Error("From lost the sentinel")
+			}
+		})
+	}
+
+	if errSentinel.Detail != "no_serial ya asignado a una orden activa" || errSentinel.Err != nil {
+		t.Errorf("From modified the sentinel: %+v", errSentinel)
+	}
+}
+
+func TestInvalidValidation(t *testing.T) {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	RegisterJSONNames(v)
+	if err := i18n.RegisterValidator(v); err != nil {
+		t.Fatal(err)
+	}
+
+	type payload struct {
+		TicketID int64   `json:"ticket_id" validate:"required"`
+		Supplier *string `json:"supplier,omitempty" validate:"omitempty,max=3"`
+	}
+	long := "ACME"
+	err := v.Struct(payload{Supplier: &long})
+
+	got := Invalid(err)
+	if got.Code != CodeValidationFailed {
+		t.Fatalf("Invalid code = %s, want %s", got.Code, CodeValidationFailed)
+	}
+
+	tests := []struct {
+		tag  language.Tag
+		want []string
+	}{
+		{language.Spanish, []string{"ticket_id: required: ticket_id es un campo requerido", "supplier: max: supplier debe tener un máximo de 3 caracteres de longitud"}},
+		{language.English, []string{"ticket_id: required: ticket_id is a required field", "supplier: max: supplier must be a maximum of 3 characters in length"}},
+	}
+	for _, tt := range tests {
+		var fields []string
+		for _, f := range got.Problem(tt.tag, "", "").Errors {
+			fields = append(fields, f.Field+": "+f.Code+": "+f.Message)
+		}
+		if !reflect.DeepEqual(fields, tt.want) {
+			t.Errorf("%s: errors = %q, want %q", tt.tag, fields, tt.want)
+		}
+	}
+}
+
+func TestProblemLocalized(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		es, en string
+	}{
+		{"sentinel", errSentinel, "no_serial ya asignado a una orden activa", "no_serial already assigned to an active order"},
+		{"wrapf", Wrapf(errSentinel, "%s", "SN-1"), "no_serial ya asignado a una orden activa: SN-1", "no_serial already assigned to an active order: SN-1"},
+		{"wrapf with error", Wrapf(errSentinel, "%v", Newf(CodeInvalidFile, "columna requerida: %s", "ticket_id")), "no_serial ya asignado a una orden activa: columna requerida: ticket_id", "no_serial already assigned to an active order: required column: ticket_id"},
+		{"untranslated wrapper", fmt.Errorf("lote 2: %w", Wrapf(errSentinel, "%s", "SN-1")), "lote 2: no_serial ya asignado a una orden activa: SN-1", "lote 2: no_serial already assigned to an active order: SN-1"},
+		{"newf", Newf(CodeInvalidRequest, "%s inválido: %q", "atomic", "x"), `atomic inválido: "x"`, `invalid atomic: "x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := From(tt.err)
+			if e.Error() != tt.es {
+				t.Errorf("Error() = %q, want %q", e.Error(), tt.es)
+			}
+			if got := e.Problem(language.Spanish, "", "").Detail; got != tt.es {
+				t.Errorf("es detail = %q, want %q", got, tt.es)
+			}
+			if got := e.Problem(language.English, "", "").Detail; got != tt.en {
+				t.Errorf("en detail = %q, want %q", got, tt.en)
+			}
+		})
+	}
+
+	p := New(CodeNotFound, "ruta no encontrada").Problem(language.English, "", "")
+	if p.Title != "Resource not found" || p.Detail != "route not found" {
+		t.Errorf("en problem = %q %q", p.Title, p.Detail)
+	}
+	p = New(CodeNotFound, "ruta no encontrada").Problem(language.Spanish, "", "")
+	if p.Title != "Recurso no encontrado" || p.Detail != "ruta no encontrada" {
+		t.Errorf("es problem = %q %q", p.Title, p.Detail)
+	}
+}
+
+func TestInvalidDecode(t *testing.T) {
+	type payload struct {
+		TicketID int64 `json:"ticket_id"`
+	}
+	decode := func(body string) error {
+		dec := json.NewDecoder(strings.NewReader(body))
+		dec.DisallowUnknownFields()
+		var p payload
+		return dec.Decode(&p)
+	}
+
+	tests := []struct {
+		body      string
+		wantField string
+	}{
+		{`{"ticket_id": "x"}`, "ticket_id"},
+		{`{"ticket_id": 1, "other": 2}`, "other"},
+		{`{"ticket_id": }`, ""},
+		{``, ""},
+	}
+	for _, tt := range tests {
+		got := Invalid(fmt.Errorf("error decodificando JSON: %w", decode(tt.body)))
+		if got.Code != CodeInvalidJSON {
+			t.Errorf("%q: code = %s, want %s", tt.body, got.Code, CodeInvalidJSON)
+			continue
+		}
+		if tt.wantField != "" && (len(got.Fields) != 1 || got.Fields[0].Field != tt.wantField || got.Fields[0].Message == "") {
+			t.Errorf("%q: fields = %+v, want %s", tt.body, got.Fields, tt.wantField)
+		}
+	}
+
+	if got := Invalid(errors.New("page inválido")); got.Code != CodeInvalidRequest || got.Detail != "page inválido" {
+		t.Errorf("Invalid(plain) = %s %q", got.Code, got.Detail)
+	}
+	if got := Invalid(fmt.Errorf("x: %w", errSentinel)); got.Code != CodeSerialInUse {
+		t.Errorf("Invalid(coded) = %s, want %s", got.Code, CodeSerialInUse)
+	}
+}
+
+func TestProblemJSON(t *testing.T) {
+	e := New(CodePreconditionFailed, "el ticket fue modificado").With("current", map[string]int{"id": 1}).With("status", 200)
+
+	b, err := json.Marshal(e.Problem(language.English, "/v1/x", "req-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["type"] != "urn:assets-replacement:problem:precondition_failed" || got["code"] != "precondition_failed" || got["request_id"] != "req-1" {
+		t.Errorf("problem = %s", b)
+	}
+	// Extensions cannot replace standard members.
+	if got["status"] != float64(http.StatusPreconditionFailed) {
+		t.Errorf("status = %v, want %d", got["status"], http.StatusPreconditionFailed)
+	}
+	if _, ok := got["current"].(map[string]any); !ok {
+		t.Errorf("current = %v", got["current"])
+	}
+}
@@ -0,0 +1,153 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/i18n"
+)
+
+// Invalid describes err, found reading or validating a request, as a client
+// error: validator.ValidationErrors list the fields that failed, JSON decoder
+// errors point at the offending field or byte, and errors that already
+// carry a code keep it. Anything else is an invalid request with the message
+// of err.
+func Invalid(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return From(err)
+	}
+
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		return Validation(ve)
+	}
+	if d := decodeError(err); d != nil {
+		return d
+	}
+	return Wrap(CodeInvalidRequest, err)
+}
+
+// Validation lists the fields that failed validation, named as tagged by
+// the validator (see RegisterJSONNames). Their messages are those registered
+// by i18n.RegisterValidator.
+func Validation(ve validator.ValidationErrors) *Error {
+	fields := make([]FieldError, len(ve))
+	names := make([]string, len(ve))
+	for i, fe := range ve {
+		fields[i] = FieldError{Field: fe.Field(), Code: fe.Tag(), translate: validationMessage(fe)}
+		fields[i].Message = fields[i].translate(i18n.Default)
+		names[i] = fe.Field()
+	}
+
+	e := Newf(CodeValidationFailed, "campos inválidos: %s", strings.Join(names, ", "))
+	e.Fields = fields
+	e.Err = ve
+	return e
+}
+
+// RegisterJSONNames makes v name fields by their JSON name, as clients know
+// them, instead of the Go one.
+func RegisterJSONNames(v *validator.Validate) {
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+}
+
+// validationMessage tells why fe failed, falling back to the name of the tag
+// when the validator has no translation for it.
+func validationMessage(fe validator.FieldError) func(tag language.Tag) string {
+	return func(tag language.Tag) string {
+		if msg := fe.Translate(i18n.Translator(tag)); msg != fe.Error() {
+			return msg
+		}
+		return i18n.Printer(tag).Sprintf("%s no cumple la regla %q", fe.Field(), fe.Tag())
+	}
+}
+
+// fieldError returns the problem with field, its message a catalog key.
+func fieldError(field, code, format string, args ...any) FieldError {
+	f := FieldError{Field: field, Code: code, translate: func(tag language.Tag) string {
+		return i18n.Printer(tag).Sprintf(format, args...)
+	}}
+	f.Message = fmt.Sprintf(format, args...)
+	return f
+}
+
+// decodeError describes the errors of encoding/json and http.MaxBytesReader,
+// nil for any other error.
+func decodeError(err error) *Error {
+	var (
+		syntax   *json.SyntaxError
+		typeErr  *json.UnmarshalTypeError
+		maxBytes *http.MaxBytesError
+		invalid  = func(format string, args ...any) *Error {
+			e := Newf(CodeInvalidJSON, format, args...)
+			e.Err = err
+			return e
+		}
+	)
+
+	switch {
+	case errors.As(err, &maxBytes):
+		e := Newf(CodePayloadTooLarge, "el cuerpo supera los %d bytes", maxBytes.Limit)
+		e.Err = err
+		return e
+	case errors.As(err, &syntax):
+		return invalid("JSON mal formado en el byte %d", syntax.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		return invalid("el cuerpo debe ser de tipo %s", jsonType(typeErr.Type))
+	case errors.As(err, &typeErr):
+		e := invalid("tipo inválido para %s", typeErr.Field)
+		e.Fields = []FieldError{fieldError(typeErr.Field, "type", "debe ser de tipo %s", jsonType(typeErr.Type))}
+		return e
+	case errors.Is(err, io.EOF):
+		return invalid("el cuerpo de la solicitud está vacío")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return invalid("JSON incompleto")
+	}
+
+	// encoding/json has no type for unknown fields, only this message.
+	if _, field, ok := strings.Cut(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		e := invalid("campo desconocido: %s", field)
+		e.Fields = []FieldError{fieldError(field, "unknown", "no es un campo aceptado")}
+		return e
+	}
+	return nil
+}
+
+// jsonType names t as a JSON type.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	default:
+		return "object"
+	}
+}
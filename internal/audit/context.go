@@ -0,0 +1,48 @@
+package audit
+
+import "context"
+
+// Source identifies the entry point that produced a change.
+type Source string
+
+const (
+	SourceREST       Source = "rest"
+	SourceBatchJSON  Source = "batch_json"
+	SourceCSVImport  Source = "csv_import"
+	SourceXLSXImport Source = "xlsx_import"
+)
+
+const anonymousActor = "anonymous"
+
+// Metadata describes who changed a ticket and through which request.
+type Metadata struct {
+	Actor     string
+	RequestID string
+	Source    Source
+}
+
+type metadataKey struct{}
+
+func WithMetadata(ctx context.Context, m Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, m)
+}
+
+// WithSource returns a copy of ctx whose metadata reports source.
+func WithSource(ctx context.Context, source Source) context.Context {
+	m := FromContext(ctx)
+	m.Source = source
+	return WithMetadata(ctx, m)
+}
+
+// FromContext returns the metadata stored in ctx, defaulting to an anonymous
+// REST caller.
+func FromContext(ctx context.Context) Metadata {
+	m, _ := ctx.Value(metadataKey{}).(Metadata)
+	if m.Actor == "" {
+		m.Actor = anonymousActor
+	}
+	if m.Source == "" {
+		m.Source = SourceREST
+	}
+	return m
+}
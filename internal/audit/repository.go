@@ -0,0 +1,236 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionUpsert = "upsert"
+	ActionDelete = "delete"
+)
+
+// TicketRepository records an audit entry for every mutation made through the
+// wrapped repository. The mutation, the reads of the ticket before and after
+// it and the entry run in one transaction, so a change is never left without
+// its entry. Reads are passed through untouched.
+type TicketRepository struct {
+	store.TicketRepository
+	entries store.AuditRepository
+	tx      store.Transactor
+}
+
+func NewTicketRepository(next store.TicketRepository, entries store.AuditRepository, tx store.Transactor) *TicketRepository {
+	return &TicketRepository{TicketRepository: next, entries: entries, tx: tx}
+}
+
+func (r *TicketRepository) Create(ctx context.Context, t *store.AssetReplacementTicket) error {
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := r.TicketRepository.Create(ctx, t); err != nil {
+			return err
+		}
+
+		after, err := r.snapshot(ctx, t.TicketID)
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, t.TicketID, ActionCreate, nil, after)
+	})
+}
+
+func (r *TicketRepository) Update(ctx context.Context, t *store.AssetReplacementTicket) error {
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := r.snapshot(ctx, t.TicketID)
+		if err != nil {
+			return err
+		}
+		if err := r.TicketRepository.Update(ctx, t); err != nil {
+			return err
+		}
+
+		after, err := r.snapshot(ctx, t.TicketID)
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, t.TicketID, ActionUpdate, before, after)
+	})
+}
+
+func (r *TicketRepository) Upsert(ctx context.Context, d dto.TicketUpsertDTO) error {
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := r.snapshot(ctx, d.TicketID)
+		if err != nil {
+			return err
+		}
+		if err := r.TicketRepository.Upsert(ctx, d); err != nil {
+			return err
+		}
+
+		after, err := r.snapshot(ctx, d.TicketID)
+		if err != nil {
+			return err
+		}
+		action := ActionUpsert
+		if before == nil {
+			action = ActionCreate
+		}
+		return r.record(ctx, d.TicketID, action, before, after)
+	})
+}
+
+// UpsertMany records one entry per ticket with the net change of the batch,
+// reading the tickets before and after in two set-wise queries.
+func (r *TicketRepository) UpsertMany(ctx context.Context, ds []dto.TicketUpsertDTO) error {
+	ids := make([]int64, 0, len(ds))
+	seen := make(map[int64]bool, len(ds))
+	for _, d := range ds {
+		if !seen[d.TicketID] {
+			seen[d.TicketID] = true
+			ids = append(ids, d.TicketID)
+		}
+	}
+
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := r.snapshotMany(ctx, ids)
+		if err != nil {
+			return err
+		}
+		if err := r.TicketRepository.UpsertMany(ctx, ds); err != nil {
+			return err
+		}
+		after, err := r.snapshotMany(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			action := ActionUpsert
+			if before[id] == nil {
+				action = ActionCreate
+			}
+			if err := r.record(ctx, id, action, before[id], after[id]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *TicketRepository) Delete(ctx context.Context, id, version int64) error {
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := r.TicketRepository.Delete(ctx, id, version); err != nil {
+			return err
+		}
+		return r.write(ctx, id, ActionDelete, []store.FieldChange{{Field: "deleted", Old: strPtr("false"), New: strPtr("true")}})
+	})
+}
+
+// snapshot returns the ticket, nil when it does not exist. Like snapshotMany
+// it reads deleted tickets too, since Upsert matches them.
+func (r *TicketRepository) snapshot(ctx context.Context, ticketID int64) (*store.AssetReplacementTicket, error) {
+	tickets, err := r.TicketRepository.GetManyByID(ctx, []int64{ticketID})
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo el ticket para auditoría: %w", err)
+	}
+	return tickets[ticketID], nil
+}
+
+func (r *TicketRepository) snapshotMany(ctx context.Context, ids []int64) (map[int64]*store.AssetReplacementTicket, error) {
+	tickets, err := r.TicketRepository.GetManyByID(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo los tickets para auditoría: %w", err)
+	}
+	return tickets, nil
+}
+
+func (r *TicketRepository) record(ctx context.Context, ticketID int64, action string, before, after *store.AssetReplacementTicket) error {
+	changes := Diff(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	return r.write(ctx, ticketID, action, changes)
+}
+
+func (r *TicketRepository) write(ctx context.Context, ticketID int64, action string, changes []store.FieldChange) error {
+	m := FromContext(ctx)
+	entry := &store.AuditEntry{
+		TicketID:  ticketID,
+		Action:    action,
+		Source:    string(m.Source),
+		Actor:     m.Actor,
+		RequestID: m.RequestID,
+		Changes:   changes,
+	}
+
+	if err := r.entries.Record(ctx, entry); err != nil {
+		return fmt.Errorf("error registrando auditoría del ticket %d: %w", ticketID, err)
+	}
+	return nil
+}
+
+// Diff returns the audited columns whose value differs between before and
+// after. A nil ticket has every column NULL.
+func Diff(before, after *store.AssetReplacementTicket) []store.FieldChange {
+	if before == nil {
+		before = &store.AssetReplacementTicket{}
+	}
+	if after == nil {
+		after = &store.AssetReplacementTicket{}
+	}
+
+	fields := []struct {
+		name     string
+		old, new *string
+	}{
+		{"category_id", int64Value(before.CategoryID), int64Value(after.CategoryID)},
+		{"no_serial", stringValue(before.NoSerial), stringValue(after.NoSerial)},
+		{"order_number", stringValue(before.OrderNumber), stringValue(after.OrderNumber)},
+		{"capex", stringValue(before.Capex), stringValue(after.Capex)},
+		{"invoice_number", stringValue(before.InvoiceNumber), stringValue(after.InvoiceNumber)},
+		{"supplier", stringValue(before.Supplier), stringValue(after.Supplier)},
+		{"center_dist_id", int64Value(before.CenterDistID), int64Value(after.CenterDistID)},
+		{"center_dist", stringValue(before.CenterDist), stringValue(after.CenterDist)},
+		{"stage_process", stringValue(before.StageProcess), stringValue(after.StageProcess)},
+	}
+
+	var changes []store.FieldChange
+	for _, f := range fields {
+		if equal(f.old, f.new) {
+			continue
+		}
+		changes = append(changes, store.FieldChange{Field: f.name, Old: f.old, New: f.new})
+	}
+	return changes
+}
+
+func stringValue(ns sql.NullString) *string {
+	if !ns.Valid {
+		return nil
+	}
+	return strPtr(ns.String)
+}
+
+func int64Value(ni sql.NullInt64) *string {
+	if !ni.Valid {
+		return nil
+	}
+	return strPtr(strconv.FormatInt(ni.Int64, 10))
+}
+
+func equal(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,272 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+)
+
+func TestDiff(t *testing.T) {
+	ticket := func(serial, supplier string, category int64) *store.AssetReplacementTicket {
+		tk := &store.AssetReplacementTicket{TicketID: 1}
+		if serial != "" {
+			tk.NoSerial = sql.NullString{String: serial, Valid: true}
+		}
+		if supplier != "" {
+			tk.Supplier = sql.NullString{String: supplier, Valid: true}
+		}
+		if category != 0 {
+			tk.CategoryID = sql.NullInt64{Int64: category, Valid: true}
+		}
+		return tk
+	}
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name          string
+		before, after *store.AssetReplacementTicket
+		want          []store.FieldChange
+	}{
+		{"both nil", nil, nil, nil},
+		{"equal", ticket("SN-1", "ACME", 7), ticket("SN-1", "ACME", 7), nil},
+		{"created", nil, ticket("SN-1", "", 7), []store.FieldChange{
+			{Field: "category_id", New: str("7")},
+			{Field: "no_serial", New: str("SN-1")},
+		}},
+		{"removed", ticket("SN-1", "", 0), nil, []store.FieldChange{
+			{Field: "no_serial", Old: str("SN-1")},
+		}},
+		{"changed and cleared", ticket("SN-1", "ACME", 7), ticket("SN-2", "", 7), []store.FieldChange{
+			{Field: "no_serial", Old: str("SN-1"), New: str("SN-2")},
+			{Field: "supplier", Old: str("ACME")},
+		}},
+		// Only audited columns count: versions and timestamps change on
+		// every write.
+		{"unaudited columns", &store.AssetReplacementTicket{Version: 1}, &store.AssetReplacementTicket{Version: 2}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %s, want %s", changesString(got), changesString(tt.want))
+			}
+		})
+	}
+}
+
+func changesString(changes []store.FieldChange) string {
+	value := func(v *string) string {
+		if v == nil {
+			return "NULL"
+		}
+		return *v
+	}
+	s := "["
+	for _, c := range changes {
+		s += " " + c.Field + ":" + value(c.Old) + "->" + value(c.New)
+	}
+	return s + " ]"
+}
+
+// failingAudit fails every Record.
+type failingAudit struct {
+	store.AuditRepository
+}
+
+var errRecord = errors.New("audit table unavailable")
+
+func (failingAudit) Record(context.Context, *store.AuditEntry) error {
+	return errRecord
+}
+
+func newRepository(t *testing.T) (*TicketRepository, store.Storage) {
+	t.Helper()
+	storage := store.NewMemoryStorage()
+	return NewTicketRepository(storage.Tickets, storage.Audit, storage.Tx), storage
+}
+
+func TestTicketRepositoryRecords(t *testing.T) {
+	ctx := WithMetadata(context.Background(), Metadata{Actor: "ana", RequestID: "req-1", Source: SourceBatchJSON})
+	repo, storage := newRepository(t)
+
+	if err := repo.Create(ctx, &store.AssetReplacementTicket{TicketID: 1, NoSerial: sql.NullString{String: "SN-1", Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	tk, _ := repo.GetByID(ctx, 1)
+	tk.Supplier = sql.NullString{String: "ACME", Valid: true}
+	if err := repo.Update(ctx, tk); err != nil {
+		t.Fatal(err)
+	}
+	// Unchanged values are not recorded.
+	supplier, serial := "ACME", "SN-2"
+	if err := repo.Upsert(ctx, dto.TicketUpsertDTO{TicketID: 1, Supplier: &supplier}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpsertMany(ctx, []dto.TicketUpsertDTO{{TicketID: 1, NoSerial: &serial}, {TicketID: 2, Supplier: &supplier}}); err != nil {
+		t.Fatal(err)
+	}
+	tk, _ = repo.GetByID(ctx, 1)
+	if err := repo.Delete(ctx, 1, tk.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := storage.Audit.GetByTicketID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+		if e.Actor != "ana" || e.RequestID != "req-1" || e.Source != string(SourceBatchJSON) {
+			t.Errorf("entry %s has metadata %s/%s/%s", e.Action, e.Actor, e.RequestID, e.Source)
+		}
+	}
+	want := []string{ActionCreate, ActionUpdate, ActionUpsert, ActionDelete}
+	if !sameActions(actions, want) {
+		t.Errorf("ticket 1 actions = %v, want %v", actions, want)
+	}
+
+	entries, _ = storage.Audit.GetByTicketID(ctx, 2)
+	if len(entries) != 1 || entries[0].Action != ActionCreate {
+		t.Errorf("ticket 2 entries = %+v, want one create", entries)
+	}
+}
+
+// Upserting a deleted ticket is recorded the same way by Upsert and UpsertMany.
+func TestTicketRepositoryUpsertDeleted(t *testing.T) {
+	ctx := context.Background()
+	repo, storage := newRepository(t)
+	for _, id := range []int64{1, 2} {
+		if err := repo.Create(ctx, &store.AssetReplacementTicket{TicketID: id}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Delete(ctx, id, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	supplier := "ACME"
+	if err := repo.Upsert(ctx, dto.TicketUpsertDTO{TicketID: 1, Supplier: &supplier}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpsertMany(ctx, []dto.TicketUpsertDTO{{TicketID: 2, Supplier: &supplier}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int64{1, 2} {
+		entries, err := storage.Audit.GetByTicketID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var upserts []store.AuditEntry
+		for _, e := range entries {
+			if e.Action == ActionUpsert {
+				upserts = append(upserts, e)
+			}
+		}
+		if len(upserts) != 1 || changesString(upserts[0].Changes) != "[ supplier:NULL->ACME ]" {
+			t.Errorf("ticket %d upsert entries = %+v, want one for the supplier", id, upserts)
+		}
+	}
+}
+
+// sameActions compares ignoring order, GetByTicketID may list newest first.
+func sameActions(got, want []string) bool {
+	count := make(map[string]int)
+	for _, a := range got {
+		count[a]++
+	}
+	for _, a := range want {
+		count[a]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return len(got) == len(want)
+}
+
+// A mutation whose entry cannot be recorded is rolled back and fails.
+func TestTicketRepositoryRecordFailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	if err := storage.Tickets.Create(ctx, &store.AssetReplacementTicket{TicketID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewTicketRepository(storage.Tickets, failingAudit{storage.Audit}, storage.Tx)
+	supplier := "ACME"
+
+	mutations := []struct {
+		name string
+		fn   func() error
+	}{
+		{"create", func() error {
+			return repo.Create(ctx, &store.AssetReplacementTicket{TicketID: 2, NoSerial: sql.NullString{String: "SN-2", Valid: true}})
+		}},
+		{"update", func() error {
+			tk, _ := repo.GetByID(ctx, 1)
+			tk.Supplier = sql.NullString{String: supplier, Valid: true}
+			return repo.Update(ctx, tk)
+		}},
+		{"upsert", func() error { return repo.Upsert(ctx, dto.TicketUpsertDTO{TicketID: 1, Supplier: &supplier}) }},
+		{"upsert many", func() error {
+			return repo.UpsertMany(ctx, []dto.TicketUpsertDTO{{TicketID: 1, Supplier: &supplier}, {TicketID: 3, Supplier: &supplier}})
+		}},
+		{"delete", func() error { return repo.Delete(ctx, 1, 1) }},
+	}
+
+	for _, m := range mutations {
+		t.Run(m.name, func(t *testing.T) {
+			if err := m.fn(); !errors.Is(err, errRecord) {
+				t.Fatalf("error = %v, want the Record error", err)
+			}
+
+			tk, err := storage.Tickets.GetByID(ctx, 1)
+			if err != nil {
+				t.Fatalf("ticket 1: %v", err)
+			}
+			if tk.Supplier.Valid || tk.Version != 1 {
+				t.Errorf("ticket 1 was changed: %+v", tk)
+			}
+			for _, id := range []int64{2, 3} {
+				if _, err := storage.Tickets.GetByID(ctx, id); !errors.Is(err, store.ErrNotFound) {
+					t.Errorf("ticket %d: %v, want ErrNotFound", id, err)
+				}
+			}
+		})
+	}
+}
+
+// Inside a caller's transaction the entry joins it instead of committing on
+// its own.
+func TestTicketRepositoryJoinsTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo, storage := newRepository(t)
+	errAbort := errors.New("abort")
+
+	err := storage.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.Create(ctx, &store.AssetReplacementTicket{TicketID: 1, NoSerial: sql.NullString{String: "SN-1", Valid: true}}); err != nil {
+			return err
+		}
+		if entries, _ := storage.Audit.GetByTicketID(ctx, 1); len(entries) != 1 {
+			t.Errorf("%d entries inside the transaction, want 1", len(entries))
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithinTx = %v", err)
+	}
+
+	if entries, _ := storage.Audit.GetByTicketID(ctx, 1); len(entries) != 0 {
+		t.Errorf("%d entries left after the rollback", len(entries))
+	}
+	if _, err := storage.Tickets.GetByID(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("ticket left after the rollback: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// APIKey is a static key for integration jobs. Only the SHA-256 hash of the
+// key is kept, never the key itself.
+type APIKey struct {
+	Name  string
+	Hash  [sha256.Size]byte
+	Roles []string
+}
+
+// HashAPIKey returns the hex encoded SHA-256 of key, the format expected in
+// the API keys file.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadAPIKeys reads one key per line in the form
+//
+//	name:sha256-hex:role1,role2
+//
+// Blank lines and lines starting with # are ignored.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening API keys file: %w", err)
+	}
+	defer f.Close()
+
+	var keys []APIKey
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		key, err := ParseAPIKey(text)
+		if err != nil {
+			return nil, fmt.Errorf("API keys file line %d: %w", line, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading API keys file: %w", err)
+	}
+
+	return keys, nil
+}
+
+// ParseAPIKey parses a single "name:sha256-hex:roles" definition.
+func ParseAPIKey(def string) (APIKey, error) {
+	parts := strings.SplitN(def, ":", 3)
+	if len(parts) < 2 {
+		return APIKey{}, fmt.Errorf("expected name:sha256-hex[:roles], got %q", def)
+	}
+
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		return APIKey{}, fmt.Errorf("API key name is empty")
+	}
+
+	raw, err := hex.DecodeString(strings.TrimSpace(parts[1]))
+	if err != nil || len(raw) != sha256.Size {
+		return APIKey{}, fmt.Errorf("API key %q: hash must be a hex encoded SHA-256", name)
+	}
+
+	key := APIKey{Name: name}
+	copy(key.Hash[:], raw)
+	if len(parts) == 3 {
+		for _, role := range strings.Split(parts[2], ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				key.Roles = append(key.Roles, role)
+			}
+		}
+	}
+	return key, nil
+}
+
+type apiKeySet []APIKey
+
+func newAPIKeySet(keys []APIKey) apiKeySet {
+	return append(apiKeySet(nil), keys...)
+}
+
+func (s apiKeySet) lookup(key string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+
+	var match *APIKey
+	for i := range s {
+		// Compare every key so timing does not reveal which one matched.
+		if subtle.ConstantTimeCompare(sum[:], s[i].Hash[:]) == 1 {
+			match = &s[i]
+		}
+	}
+	if match == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return &Principal{
+		Subject: "api-key:" + match.Name,
+		Name:    match.Name,
+		Roles:   match.Roles,
+		Method:  MethodAPIKey,
+	}, nil
+}
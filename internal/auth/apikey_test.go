@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParseAPIKey(t *testing.T, def string) APIKey {
+	t.Helper()
+	key, err := ParseAPIKey(def)
+	if err != nil {
+		t.Fatalf("ParseAPIKey(%q): %v", def, err)
+	}
+	return key
+}
+
+func TestParseAPIKey(t *testing.T) {
+	hash := HashAPIKey("secret")
+
+	tests := []struct {
+		name      string
+		def       string
+		wantName  string
+		wantRoles []string
+		wantErr   string
+	}{
+		{"roles", "job:" + hash + ":importer,viewer", "job", []string{"importer", "viewer"}, ""},
+		{"no roles", "job:" + hash, "job", nil, ""},
+		{"spaces and empty roles", " job : " + hash + " : importer, ,viewer ", "job", []string{"importer", "viewer"}, ""},
+		{"upper case hash", "job:" + strings.ToUpper(hash), "job", nil, ""},
+		{"missing hash", "job", "", nil, "expected name:sha256-hex"},
+		{"empty name", ":" + hash, "", nil, "name is empty"},
+		{"not hex", "job:zz" + hash[2:], "", nil, "hex encoded SHA-256"},
+		{"short hash", "job:" + hash[:62], "", nil, "hex encoded SHA-256"},
+		// A plain key pasted instead of its hash is rejected.
+		{"plain key", "job:secret:importer", "", nil, "hex encoded SHA-256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseAPIKey(tt.def)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseAPIKey = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAPIKey: %v", err)
+			}
+			if key.Name != tt.wantName || !reflect.DeepEqual(key.Roles, tt.wantRoles) {
+				t.Errorf("ParseAPIKey = %+v", key)
+			}
+			if _, err := newAPIKeySet([]APIKey{key}).lookup("secret"); err != nil {
+				t.Errorf("lookup of the parsed key: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "keys")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	path := write(t, "# integration jobs\n\njob:"+HashAPIKey("k1")+":importer\n  # indented comment\nreport:"+HashAPIKey("k2")+"\n")
+	keys, err := LoadAPIKeys(path)
+	if err != nil {
+		t.Fatalf("LoadAPIKeys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Name != "job" || keys[1].Name != "report" {
+		t.Fatalf("LoadAPIKeys = %+v", keys)
+	}
+
+	path = write(t, "job:"+HashAPIKey("k1")+"\n\nbroken\n")
+	if _, err := LoadAPIKeys(path); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("LoadAPIKeys = %v, want an error on line 3", err)
+	}
+
+	if _, err := LoadAPIKeys(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadAPIKeys of a missing file succeeded")
+	}
+}
+
+func TestAPIKeyLookup(t *testing.T) {
+	set := newAPIKeySet([]APIKey{
+		mustParseAPIKey(t, "job:"+HashAPIKey("k1")+":importer"),
+		mustParseAPIKey(t, "report:"+HashAPIKey("k2")+":viewer"),
+	})
+
+	tests := []struct {
+		key     string
+		subject string
+		roles   []string
+	}{
+		{"k1", "api-key:job", []string{"importer"}},
+		// Keys after the first are compared too.
+		{"k2", "api-key:report", []string{"viewer"}},
+		{"k3", "", nil},
+		{"", "", nil},
+		{"K1", "", nil},
+		// The hash itself is not a key.
+		{HashAPIKey("k1"), "", nil},
+	}
+
+	for _, tt := range tests {
+		p, err := set.lookup(tt.key)
+		if tt.subject == "" {
+			if !errors.Is(err, ErrInvalidAPIKey) {
+				t.Errorf("lookup(%q) = %+v, %v, want ErrInvalidAPIKey", tt.key, p, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("lookup(%q): %v", tt.key, err)
+			continue
+		}
+		if p.Subject != tt.subject || !reflect.DeepEqual(p.Roles, tt.roles) || p.Method != MethodAPIKey {
+			t.Errorf("lookup(%q) = %+v", tt.key, p)
+		}
+	}
+
+	if _, err := newAPIKeySet(nil).lookup("k1"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("lookup in an empty set = %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidToken       = errors.New("invalid bearer token")
+	ErrInvalidAPIKey      = errors.New("invalid api key")
+)
+
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+
+	APIKeyHeader = "X-API-Key"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name,omitempty"`
+	Roles   []string `json:"roles"`
+	Method  string   `json:"method"`
+}
+
+type Config struct {
+	// HMACSecret validates HS256 tokens when set.
+	HMACSecret []byte
+	// RSAPublicKey validates RS256 tokens when set.
+	RSAPublicKey *rsa.PublicKey
+	Issuer       string
+	Audience     string
+	Leeway       time.Duration
+	APIKeys      []APIKey
+}
+
+type Authenticator struct {
+	jwt     *jwtVerifier
+	apiKeys apiKeySet
+}
+
+func New(cfg Config) (*Authenticator, error) {
+	if len(cfg.HMACSecret) == 0 && cfg.RSAPublicKey == nil && len(cfg.APIKeys) == 0 {
+		return nil, errors.New("auth: no JWT key or API key configured")
+	}
+
+	a := &Authenticator{apiKeys: newAPIKeySet(cfg.APIKeys)}
+	if len(cfg.HMACSecret) > 0 || cfg.RSAPublicKey != nil {
+		a.jwt = newJWTVerifier(cfg)
+	}
+	return a, nil
+}
+
+// Authenticate identifies the caller of r from an "Authorization: Bearer"
+// JWT or an X-API-Key header, in that order.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return nil, ErrInvalidToken
+		}
+		if a.jwt == nil {
+			return nil, ErrInvalidToken
+		}
+		return a.jwt.verify(strings.TrimSpace(token))
+	}
+
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.apiKeys.lookup(key)
+	}
+
+	return nil, ErrMissingCredentials
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal, or nil.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
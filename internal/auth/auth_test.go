@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("test-secret")
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, c jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, c).SignedString(key)
+	if err != nil {
+		t.Fatalf("signing %s token: %v", method.Alg(), err)
+	}
+	return token
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	cfg := Config{
+		HMACSecret:   testSecret,
+		RSAPublicKey: &rsaKey.PublicKey,
+		Issuer:       "https://issuer.example",
+		Audience:     "assets-api",
+		Leeway:       30 * time.Second,
+	}
+	both, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.HMACSecret = nil
+	rsaOnly, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := func(edit func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":   "ana",
+			"name":  "Ana",
+			"roles": []string{"operator"},
+			"iss":   "https://issuer.example",
+			"aud":   "assets-api",
+			"exp":   now.Add(time.Hour).Unix(),
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		auth   *Authenticator
+		token  string
+		wantOK bool
+	}{
+		{"HS256", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(nil)), true},
+		{"RS256", both, sign(t, jwt.SigningMethodRS256, rsaKey, claims(nil)), true},
+		{"RS256 only", rsaOnly, sign(t, jwt.SigningMethodRS256, rsaKey, claims(nil)), true},
+
+		// Algorithm allow-list.
+		{"alg none", both, none, false},
+		{"HS384 not allowed", both, sign(t, jwt.SigningMethodHS384, testSecret, claims(nil)), false},
+		{"RS512 not allowed", both, sign(t, jwt.SigningMethodRS512, rsaKey, claims(nil)), false},
+		{"HS256 without a secret", rsaOnly, sign(t, jwt.SigningMethodHS256, testSecret, claims(nil)), false},
+		// HS/RS confusion: the public key used as an HMAC secret.
+		{"HS256 signed with the public key", rsaOnly, sign(t, jwt.SigningMethodHS256, publicPEM, claims(nil)), false},
+		{"HS256 signed with the public key and a secret set", both, sign(t, jwt.SigningMethodHS256, publicPEM, claims(nil)), false},
+		{"RS256 with another key", both, sign(t, jwt.SigningMethodRS256, otherKey, claims(nil)), false},
+		{"wrong secret", both, sign(t, jwt.SigningMethodHS256, []byte("other"), claims(nil)), false},
+
+		// Expiration.
+		{"missing exp", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { delete(c, "exp") })), false},
+		{"expired", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() })), false},
+		{"expired within leeway", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-10 * time.Second).Unix() })), true},
+		{"not yet valid", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { c["nbf"] = now.Add(time.Hour).Unix() })), false},
+
+		// Issuer, audience and subject.
+		{"wrong issuer", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" })), false},
+		{"missing issuer", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { delete(c, "iss") })), false},
+		{"wrong audience", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { c["aud"] = "other-api" })), false},
+		{"audience list", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { c["aud"] = []string{"other-api", "assets-api"} })), true},
+		{"missing audience", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { delete(c, "aud") })), false},
+		{"missing sub", both, sign(t, jwt.SigningMethodHS256, testSecret, claims(func(c jwt.MapClaims) { delete(c, "sub") })), false},
+
+		{"malformed", both, "not.a.jwt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.auth.Authenticate(bearer(tt.token))
+			if !tt.wantOK {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Authenticate = %+v, %v, want ErrInvalidToken", p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			want := &Principal{Subject: "ana", Name: "Ana", Roles: []string{"operator"}, Method: MethodJWT}
+			if !reflect.DeepEqual(p, want) {
+				t.Errorf("Principal = %+v, want %+v", p, want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateHeaders(t *testing.T) {
+	a, err := New(Config{HMACSecret: testSecret, APIKeys: []APIKey{mustParseAPIKey(t, "job:"+HashAPIKey("k1")+":importer")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    error
+	}{
+		{"none", nil, ErrMissingCredentials},
+		{"basic scheme", map[string]string{"Authorization": "Basic YTpi"}, ErrInvalidToken},
+		{"empty bearer", map[string]string{"Authorization": "Bearer  "}, ErrInvalidToken},
+		{"no scheme", map[string]string{"Authorization": "token"}, ErrInvalidToken},
+		// A bad bearer token does not fall back to the API key.
+		{"bearer wins", map[string]string{"Authorization": "Bearer x", APIKeyHeader: "k1"}, ErrInvalidToken},
+		{"api key", map[string]string{APIKeyHeader: "k1"}, nil},
+		{"bad api key", map[string]string{APIKeyHeader: "k2"}, ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if _, err := a.Authenticate(r); !errors.Is(err, tt.want) {
+				t.Errorf("Authenticate = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := New(Config{}); err == nil {
+		t.Error("New without keys succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type claims struct {
+	Name  string   `json:"name,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type jwtVerifier struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	parser     *jwt.Parser
+}
+
+func newJWTVerifier(cfg Config) *jwtVerifier {
+	var methods []string
+	if len(cfg.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.RSAPublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &jwtVerifier{
+		hmacSecret: cfg.HMACSecret,
+		rsaKey:     cfg.RSAPublicKey,
+		parser:     jwt.NewParser(opts...),
+	}
+}
+
+func (v *jwtVerifier) verify(raw string) (*Principal, error) {
+	var c claims
+	_, err := v.parser.ParseWithClaims(raw, &c, v.key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+
+	return &Principal{
+		Subject: c.Subject,
+		Name:    c.Name,
+		Roles:   c.Roles,
+		Method:  MethodJWT,
+	}, nil
+}
+
+func (v *jwtVerifier) key(t *jwt.Token) (any, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.hmacSecret, nil
+	case *jwt.SigningMethodRSA:
+		return v.rsaKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key or certificate.
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading RSA public key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing RSA public key: %w", err)
+	}
+	return key, nil
+}
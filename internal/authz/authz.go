@@ -0,0 +1,161 @@
+package authz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Role string
+
+const (
+	RoleViewer      Role = "viewer"
+	RoleRequester   Role = "requester"
+	RoleProcurement Role = "procurement"
+	RoleFinance     Role = "finance"
+	RoleAdmin       Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermTicketsRead       Permission = "tickets:read"
+	PermTicketsCreate     Permission = "tickets:create"
+	PermTicketsUpdate     Permission = "tickets:update"
+	PermTicketsDelete     Permission = "tickets:delete"
+	PermTicketsTransition Permission = "tickets:transition"
+	PermTicketsImport     Permission = "tickets:import"
+
+	// PermEditProcurementFields and PermEditFinanceFields guard single fields
+	// on top of the operation permission, see Policy.AuthorizeFields.
+	PermEditProcurementFields Permission = "tickets:edit-procurement-fields"
+	PermEditFinanceFields     Permission = "tickets:edit-finance-fields"
+)
+
+// Error reports a denied operation. Fields is set when the caller may run the
+// operation but not change some of the fields it sent.
+type Error struct {
+	Permissions []Permission
+	Fields      []string
+}
+
+func (e *Error) Error() string {
+	perms := make([]string, len(e.Permissions))
+	for i, p := range e.Permissions {
+		perms[i] = string(p)
+	}
+
+	if len(e.Fields) > 0 {
+		return fmt.Sprintf("forbidden: %s required to change %s", strings.Join(perms, ", "), strings.Join(e.Fields, ", "))
+	}
+	return fmt.Sprintf("forbidden: %s required", strings.Join(perms, ", "))
+}
+
+type Policy struct {
+	roles  map[Role]map[Permission]bool
+	fields map[string]Permission
+}
+
+func NewPolicy(roles map[Role][]Permission, fields map[string]Permission) *Policy {
+	p := &Policy{
+		roles:  make(map[Role]map[Permission]bool, len(roles)),
+		fields: fields,
+	}
+	for role, perms := range roles {
+		p.roles[role] = make(map[Permission]bool, len(perms))
+		for _, perm := range perms {
+			p.roles[role][perm] = true
+		}
+	}
+	return p
+}
+
+// Default is the permission table for ticket operations.
+var Default = NewPolicy(
+	map[Role][]Permission{
+		RoleViewer: {
+			PermTicketsRead,
+		},
+		// Requesters fill in the procurement fields of the tickets they
+		// open, CAPEX and invoice are left to finance.
+		RoleRequester: {
+			PermTicketsRead,
+			PermTicketsCreate,
+			PermEditProcurementFields,
+		},
+		RoleProcurement: {
+			PermTicketsRead,
+			PermTicketsCreate,
+			PermTicketsUpdate,
+			PermTicketsTransition,
+			PermTicketsImport,
+			PermEditProcurementFields,
+		},
+		RoleFinance: {
+			PermTicketsRead,
+			PermTicketsUpdate,
+			PermTicketsTransition,
+			PermTicketsImport,
+			PermEditFinanceFields,
+		},
+		RoleAdmin: {
+			PermTicketsRead,
+			PermTicketsCreate,
+			PermTicketsUpdate,
+			PermTicketsDelete,
+			PermTicketsTransition,
+			PermTicketsImport,
+			PermEditProcurementFields,
+			PermEditFinanceFields,
+		},
+	},
+	map[string]Permission{
+		"no_serial":      PermEditProcurementFields,
+		"order_number":   PermEditProcurementFields,
+		"supplier":       PermEditProcurementFields,
+		"capex":          PermEditFinanceFields,
+		"invoice_number": PermEditFinanceFields,
+	},
+)
+
+// Allowed reports whether any of roles grants perm. Unknown roles grant nothing.
+func (p *Policy) Allowed(roles []string, perm Permission) bool {
+	for _, r := range roles {
+		if p.roles[Role(r)][perm] {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize returns an *Error when roles do not grant perm.
+func (p *Policy) Authorize(roles []string, perm Permission) error {
+	if !p.Allowed(roles, perm) {
+		return &Error{Permissions: []Permission{perm}}
+	}
+	return nil
+}
+
+// AuthorizeFields returns an *Error listing the fields roles may not change.
+// Fields without a declared permission are allowed.
+func (p *Policy) AuthorizeFields(roles []string, fields []string) error {
+	var denied Error
+	seen := make(map[Permission]bool)
+	for _, f := range fields {
+		perm, ok := p.fields[f]
+		if !ok || p.Allowed(roles, perm) {
+			continue
+		}
+		denied.Fields = append(denied.Fields, f)
+		if !seen[perm] {
+			seen[perm] = true
+			denied.Permissions = append(denied.Permissions, perm)
+		}
+	}
+	if len(denied.Fields) == 0 {
+		return nil
+	}
+
+	sort.Slice(denied.Permissions, func(i, j int) bool { return denied.Permissions[i] < denied.Permissions[j] })
+	return &denied
+}
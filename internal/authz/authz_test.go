@@ -0,0 +1,110 @@
+package authz
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultPermissions(t *testing.T) {
+	all := []Permission{
+		PermTicketsRead,
+		PermTicketsCreate,
+		PermTicketsUpdate,
+		PermTicketsDelete,
+		PermTicketsTransition,
+		PermTicketsImport,
+		PermEditProcurementFields,
+		PermEditFinanceFields,
+	}
+
+	tests := []struct {
+		role Role
+		want []Permission
+	}{
+		{RoleViewer, []Permission{PermTicketsRead}},
+		{RoleRequester, []Permission{PermTicketsRead, PermTicketsCreate, PermEditProcurementFields}},
+		{RoleProcurement, []Permission{PermTicketsRead, PermTicketsCreate, PermTicketsUpdate, PermTicketsTransition, PermTicketsImport, PermEditProcurementFields}},
+		{RoleFinance, []Permission{PermTicketsRead, PermTicketsUpdate, PermTicketsTransition, PermTicketsImport, PermEditFinanceFields}},
+		{RoleAdmin, all},
+		{"unknown", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			var got []Permission
+			for _, perm := range all {
+				if Default.Allowed([]string{string(tt.role)}, perm) {
+					got = append(got, perm)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("permissions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	if err := Default.Authorize([]string{"viewer", "finance"}, PermTicketsUpdate); err != nil {
+		t.Errorf("roles are not combined: %v", err)
+	}
+	if err := Default.Authorize(nil, PermTicketsRead); err == nil {
+		t.Error("no roles granted tickets:read")
+	}
+
+	err := Default.Authorize([]string{"viewer"}, PermTicketsDelete)
+	var authzErr *Error
+	if !errors.As(err, &authzErr) || !reflect.DeepEqual(authzErr.Permissions, []Permission{PermTicketsDelete}) || authzErr.Fields != nil {
+		t.Fatalf("Authorize = %#v", err)
+	}
+	if got, want := err.Error(), "forbidden: tickets:delete required"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestAuthorizeFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []string
+		fields     []string
+		wantFields []string
+		wantPerms  []Permission
+	}{
+		{"requester sets procurement fields", []string{"requester"}, []string{"no_serial", "order_number", "supplier"}, nil, nil},
+		{"requester sets capex", []string{"requester"}, []string{"capex", "no_serial"}, []string{"capex"}, []Permission{PermEditFinanceFields}},
+		{"finance sets invoice", []string{"finance"}, []string{"capex", "invoice_number"}, nil, nil},
+		{"finance sets supplier", []string{"finance"}, []string{"invoice_number", "supplier"}, []string{"supplier"}, []Permission{PermEditProcurementFields}},
+		{"procurement sets both kinds", []string{"procurement"}, []string{"capex", "invoice_number", "supplier"}, []string{"capex", "invoice_number"}, []Permission{PermEditFinanceFields}},
+		{"viewer sets both kinds", []string{"viewer"}, []string{"supplier", "capex"}, []string{"supplier", "capex"}, []Permission{PermEditFinanceFields, PermEditProcurementFields}},
+		{"combined roles", []string{"procurement", "finance"}, []string{"capex", "supplier"}, nil, nil},
+		{"admin", []string{"admin"}, []string{"capex", "invoice_number", "no_serial", "order_number", "supplier"}, nil, nil},
+		{"undeclared fields", []string{"viewer"}, []string{"category_id"}, nil, nil},
+		{"no fields", nil, nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Default.AuthorizeFields(tt.roles, tt.fields)
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Fatalf("AuthorizeFields = %v, want nil", err)
+				}
+				return
+			}
+
+			var authzErr *Error
+			if !errors.As(err, &authzErr) {
+				t.Fatalf("AuthorizeFields = %v, want an *Error", err)
+			}
+			if !reflect.DeepEqual(authzErr.Fields, tt.wantFields) || !reflect.DeepEqual(authzErr.Permissions, tt.wantPerms) {
+				t.Errorf("AuthorizeFields = %+v, want fields %v and permissions %v", authzErr, tt.wantFields, tt.wantPerms)
+			}
+		})
+	}
+
+	err := Default.AuthorizeFields([]string{"finance"}, []string{"supplier", "order_number"})
+	if got, want := err.Error(), "forbidden: tickets:edit-procurement-fields required to change supplier, order_number"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -9,8 +9,65 @@ type TicketUpsertDTO struct {
 	Supplier      *string `json:"supplier,omitempty"`
 }
 
+// TicketUpsertResponse summarises a batch. In a dry run nothing is written
+// and the counts and statuses say what would have happened. RolledBack is set
+// when an atomic batch stopped at a failed row: Rows end at that row and their
+// statuses say what each row did before everything was rolled back.
 type TicketUpsertResponse struct {
-	UpdatedCount int     `json:"updated_count"`
-	SkippedIDs   []int64 `json:"skipped_ids,omitempty"`
-	Message      string  `json:"message"`
+	DryRun       bool                `json:"dry_run,omitempty"`
+	RolledBack   bool                `json:"rolled_back,omitempty"`
+	UpdatedCount int                 `json:"updated_count"`
+	SkippedIDs   []int64             `json:"skipped_ids,omitempty"`
+	Summary      TicketUpsertSummary `json:"summary"`
+	Rows         []TicketRowResult   `json:"rows"`
+	Message      string              `json:"message"`
+}
+
+type TicketUpsertSummary struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+}
+
+// Status of a row of a batch. Skipped rows were rejected by validation and
+// can be fixed by the user, failed rows could not be written.
+const (
+	RowStatusInserted  = "inserted"
+	RowStatusUpdated   = "updated"
+	RowStatusUnchanged = "unchanged"
+	RowStatusSkipped   = "skipped"
+	RowStatusFailed    = "failed"
+)
+
+// Machine readable reasons for skipped and failed rows.
+const (
+	RowCodeInvalidRow      = "invalid_row"
+	RowCodeInvalidTicketID = "invalid_ticket_id"
+	RowCodeSerialInUse     = "serial_in_use"
+	RowCodeTicketDeleted   = "ticket_deleted"
+	RowCodeStoreError      = "store_error"
+)
+
+// TicketRowResult is the outcome of one row of a batch. Row is the line of the
+// CSV file, or the 1-based position in a JSON batch. Changes holds the old and
+// new value of every column the row changed.
+type TicketRowResult struct {
+	Row      int           `json:"row"`
+	TicketID int64         `json:"ticket_id,omitempty"`
+	Status   string        `json:"status"`
+	Code     string        `json:"code,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	Changes  []FieldChange `json:"changes,omitempty"`
+
+	// Record is the row as sent, in import column order, used to build the
+	// annotated CSV report.
+	Record []string `json:"-"`
+}
+
+type FieldChange struct {
+	Field string  `json:"field"`
+	Old   *string `json:"old"`
+	New   *string `json:"new"`
 }
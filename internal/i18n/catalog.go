@@ -0,0 +1,36 @@
+package i18n
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message/catalog"
+)
+
+// messages holds the es and en catalogs. Entries are either a string or a
+// catalog.Message, such as a plural.Selectf for counts.
+var messages = newCatalog(map[language.Tag]map[string]any{
+	language.Spanish: esMessages,
+	language.English: enMessages,
+})
+
+func newCatalog(catalogs map[language.Tag]map[string]any) *catalog.Builder {
+	b := catalog.NewBuilder(catalog.Fallback(Default))
+	for tag, entries := range catalogs {
+		for key, msg := range entries {
+			var err error
+			switch msg := msg.(type) {
+			case string:
+				err = b.SetString(tag, key, msg)
+			case catalog.Message:
+				err = b.Set(tag, key, msg)
+			default:
+				err = fmt.Errorf("tipo de mensaje no soportado %T", msg)
+			}
+			if err != nil {
+				panic(fmt.Sprintf("i18n: mensaje %q (%s): %v", key, tag, err))
+			}
+		}
+	}
+	return b
+}
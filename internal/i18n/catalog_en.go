@@ -0,0 +1,116 @@
+package i18n
+
+import "golang.org/x/text/feature/plural"
+
+// enMessages is the English catalog.
+var enMessages = map[string]any{
+	// Problem titles.
+	"Invalid request":          "Invalid request",
+	"Invalid JSON body":        "Invalid JSON body",
+	"Validation failed":        "Validation failed",
+	"Request body too large":   "Request body too large",
+	"Invalid file":             "Invalid file",
+	"Invalid row":              "Invalid row",
+	"Invalid ticket ID":        "Invalid ticket ID",
+	"Invalid column mapping":   "Invalid column mapping",
+	"Invalid cursor":           "Invalid cursor",
+	"Invalid export format":    "Invalid export format",
+	"Unknown stage":            "Unknown stage",
+	"Unauthorized":             "Unauthorized",
+	"Forbidden":                "Forbidden",
+	"Resource not found":       "Resource not found",
+	"Method not allowed":       "Method not allowed",
+	"Resource already exists":  "Resource already exists",
+	"Serial in use":            "Serial in use",
+	"Illegal stage transition": "Illegal stage transition",
+	"Transition guard failed":  "Transition guard failed",
+	"Resource was modified":    "Resource was modified",
+	"Import already finished":  "Import already finished",
+	"Idempotency key in use":   "Idempotency key in use",
+	"Precondition failed":      "Precondition failed",
+	"Idempotency key reused":   "Idempotency key reused",
+	"Precondition required":    "Precondition required",
+	"Rate limit exceeded":      "Rate limit exceeded",
+	"Internal server error":    "Internal server error",
+	"Service unavailable":      "Service unavailable",
+	"Import queue full":        "Import queue full",
+
+	// Request errors.
+	"ruta no encontrada":                         "route not found",
+	"método %s no permitido en %s":               "method %s not allowed on %s",
+	"%s inválido: %q":                            "invalid %s: %q",
+	"%s inválido: %q, use YYYY-MM-DD o RFC 3339": "invalid %s: %q, use YYYY-MM-DD or RFC 3339",
+	"nombre de perfil inválido: %q, use letras, números, '.', '_' o '-'": "invalid profile name: %q, use letters, digits, '.', '_' or '-'",
+	"perfil de importación no encontrado: %q":                            "import profile not found: %q",
+	"el ticket fue modificado por otra solicitud":                        "the ticket was modified by another request",
+	"falta el encabezado If-Match con el ETag del ticket":                "missing If-Match header with the ETag of the ticket",
+	"%s inválida: se esperaban entre 1 y %d caracteres ASCII visibles":   "invalid %s: expected 1 to %d visible ASCII characters",
+	"la %s ya se usó con otra solicitud":                                 "the %s was already used with another request",
+	"una solicitud con la misma %s está en curso":                        "a request with the same %s is in progress",
+	"no se pudo verificar la %s":                                         "could not check the %s",
+	"se requiere %s":                                                     "%s required",
+	"se requiere %s para modificar %s":                                   "%s required to change %s",
+	"rate limit exceeded, retry in %s":                                   "rate limit exceeded, retry in %s",
+
+	// Body and validation errors.
+	"el cuerpo supera los %d bytes":        "the body exceeds %d bytes",
+	"JSON mal formado en el byte %d":       "malformed JSON at byte %d",
+	"el cuerpo debe ser de tipo %s":        "the body must be of type %s",
+	"tipo inválido para %s":                "invalid type for %s",
+	"debe ser de tipo %s":                  "must be of type %s",
+	"el cuerpo de la solicitud está vacío": "the request body is empty",
+	"JSON incompleto":                      "incomplete JSON",
+	"campo desconocido: %s":                "unknown field: %s",
+	"no es un campo aceptado":              "is not an accepted field",
+	"campos inválidos: %s":                 "invalid fields: %s",
+	"%s no cumple la regla %q":             "%s does not satisfy the %q rule",
+
+	// Domain errors.
+	"resource not found":                                 "resource not found",
+	"resource already exists":                            "resource already exists",
+	"resource was modified":                              "resource was modified",
+	"cursor inválido":                                    "invalid cursor",
+	"fue creado para sort=%s":                            "it was created for sort=%s",
+	"campo de orden inválido: %q, se esperaba uno de %s": "invalid sort field: %q, expected one of %s",
+	"campo de orden repetido: %q":                        "repeated sort field: %q",
+	"unknown stage":                                      "unknown stage",
+	"illegal stage transition":                           "illegal stage transition",
+	"transition guard failed":                            "transition guard failed",
+	"import no encontrado":                               "import not found",
+	"cola de imports llena, intente más tarde":           "import queue full, try again later",
+	"el import ya terminó":                               "the import already finished",
+	"error interno procesando el import":                 "internal error processing the import",
+	"el servidor se está deteniendo":                     "the server is shutting down",
+	"el ticket fue eliminado":                            "the ticket was deleted",
+	"no_serial ya asignado a una orden activa":           "no_serial already assigned to an active order",
+	"fila inválida":                                      "invalid row",
+	"ticket_id inválido":                                 "invalid ticket_id",
+
+	// Import files.
+	"CSV inválido":                                 "invalid CSV",
+	"XLSX inválido":                                "invalid XLSX",
+	"el archivo está vacío":                        "the file is empty",
+	"error leyendo encabezado: %v":                 "error reading header: %v",
+	"error leyendo hoja %q: %v":                    "error reading sheet %q: %v",
+	"la hoja %q está vacía":                        "sheet %q is empty",
+	"el libro no tiene hojas":                      "the workbook has no sheets",
+	"hoja no encontrada: %q":                       "sheet not found: %q",
+	"columna requerida: %s":                        "required column: %s",
+	"las columnas %q y %q corresponden ambas a %s": "columns %q and %q both map to %s",
+	"mapeo de columnas inválido":                   "invalid column mapping",
+	"campo desconocido %q, se esperaba uno de %s":  "unknown field %q, expected one of %s",
+	"encabezado vacío para %s":                     "empty header for %s",
+	"el encabezado %q está asignado a %s y %s":     "header %q is mapped to both %s and %s",
+	"formato de exportación inválido":              "invalid export format",
+	"%q, se esperaba csv, xlsx o ndjson":           "%q, expected csv, xlsx or ndjson",
+
+	// Batch summaries.
+	"%d tickets actualizados de forma exitosa": plural.Selectf(1, "%d",
+		"=1", "1 ticket updated successfully",
+		"other", "%[1]d tickets updated successfully"),
+	"simulación: %d tickets serían actualizados, no se guardó ningún cambio": plural.Selectf(1, "%d",
+		"=1", "dry run: 1 ticket would be updated, nothing was saved",
+		"other", "dry run: %[1]d tickets would be updated, nothing was saved"),
+	"simulación: la fila %d falla (%s), no se guardaría ningún cambio": "dry run: row %d fails (%s), nothing would be saved",
+	"fila %d: %s; transacción revertida, no se guardó ningún cambio":   "row %d: %s; transaction rolled back, nothing was saved",
+}
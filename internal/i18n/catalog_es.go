@@ -0,0 +1,117 @@
+package i18n
+
+import "golang.org/x/text/feature/plural"
+
+// esMessages is the Spanish catalog. Most keys are already Spanish, they are
+// listed anyway so both catalogs have the same keys.
+var esMessages = map[string]any{
+	// Problem titles.
+	"Invalid request":          "Solicitud inválida",
+	"Invalid JSON body":        "JSON inválido",
+	"Validation failed":        "Error de validación",
+	"Request body too large":   "Cuerpo de la solicitud demasiado grande",
+	"Invalid file":             "Archivo inválido",
+	"Invalid row":              "Fila inválida",
+	"Invalid ticket ID":        "ticket_id inválido",
+	"Invalid column mapping":   "Mapeo de columnas inválido",
+	"Invalid cursor":           "Cursor inválido",
+	"Invalid export format":    "Formato de exportación inválido",
+	"Unknown stage":            "Etapa desconocida",
+	"Unauthorized":             "No autenticado",
+	"Forbidden":                "Acceso denegado",
+	"Resource not found":       "Recurso no encontrado",
+	"Method not allowed":       "Método no permitido",
+	"Resource already exists":  "El recurso ya existe",
+	"Serial in use":            "Número de serie en uso",
+	"Illegal stage transition": "Transición de etapa no permitida",
+	"Transition guard failed":  "Requisitos de la transición no cumplidos",
+	"Resource was modified":    "El recurso fue modificado",
+	"Import already finished":  "Import ya terminado",
+	"Idempotency key in use":   "Idempotency-Key en uso",
+	"Precondition failed":      "Precondición fallida",
+	"Idempotency key reused":   "Idempotency-Key reutilizada",
+	"Precondition required":    "Precondición requerida",
+	"Rate limit exceeded":      "Límite de solicitudes excedido",
+	"Internal server error":    "Error interno del servidor",
+	"Service unavailable":      "Servicio no disponible",
+	"Import queue full":        "Cola de imports llena",
+
+	// Request errors.
+	"ruta no encontrada":                         "ruta no encontrada",
+	"método %s no permitido en %s":               "método %s no permitido en %s",
+	"%s inválido: %q":                            "%s inválido: %q",
+	"%s inválido: %q, use YYYY-MM-DD o RFC 3339": "%s inválido: %q, use YYYY-MM-DD o RFC 3339",
+	"nombre de perfil inválido: %q, use letras, números, '.', '_' o '-'": "nombre de perfil inválido: %q, use letras, números, '.', '_' o '-'",
+	"perfil de importación no encontrado: %q":                            "perfil de importación no encontrado: %q",
+	"el ticket fue modificado por otra solicitud":                        "el ticket fue modificado por otra solicitud",
+	"falta el encabezado If-Match con el ETag del ticket":                "falta el encabezado If-Match con el ETag del ticket",
+	"%s inválida: se esperaban entre 1 y %d caracteres ASCII visibles":   "%s inválida: se esperaban entre 1 y %d caracteres ASCII visibles",
+	"la %s ya se usó con otra solicitud":                                 "la %s ya se usó con otra solicitud",
+	"una solicitud con la misma %s está en curso":                        "una solicitud con la misma %s está en curso",
+	"no se pudo verificar la %s":                                         "no se pudo verificar la %s",
+	"se requiere %s":                                                     "se requiere %s",
+	"se requiere %s para modificar %s":                                   "se requiere %s para modificar %s",
+	"rate limit exceeded, retry in %s":                                   "límite de solicitudes excedido, reintente en %s",
+
+	// Body and validation errors.
+	"el cuerpo supera los %d bytes":        "el cuerpo supera los %d bytes",
+	"JSON mal formado en el byte %d":       "JSON mal formado en el byte %d",
+	"el cuerpo debe ser de tipo %s":        "el cuerpo debe ser de tipo %s",
+	"tipo inválido para %s":                "tipo inválido para %s",
+	"debe ser de tipo %s":                  "debe ser de tipo %s",
+	"el cuerpo de la solicitud está vacío": "el cuerpo de la solicitud está vacío",
+	"JSON incompleto":                      "JSON incompleto",
+	"campo desconocido: %s":                "campo desconocido: %s",
+	"no es un campo aceptado":              "no es un campo aceptado",
+	"campos inválidos: %s":                 "campos inválidos: %s",
+	"%s no cumple la regla %q":             "%s no cumple la regla %q",
+
+	// Domain errors.
+	"resource not found":                                 "recurso no encontrado",
+	"resource already exists":                            "el recurso ya existe",
+	"resource was modified":                              "el recurso fue modificado",
+	"cursor inválido":                                    "cursor inválido",
+	"fue creado para sort=%s":                            "fue creado para sort=%s",
+	"campo de orden inválido: %q, se esperaba uno de %s": "campo de orden inválido: %q, se esperaba uno de %s",
+	"campo de orden repetido: %q":                        "campo de orden repetido: %q",
+	"unknown stage":                                      "etapa desconocida",
+	"illegal stage transition":                           "transición de etapa no permitida",
+	"transition guard failed":                            "la transición no cumple sus requisitos",
+	"import no encontrado":                               "import no encontrado",
+	"cola de imports llena, intente más tarde":           "cola de imports llena, intente más tarde",
+	"el import ya terminó":                               "el import ya terminó",
+	"error interno procesando el import":                 "error interno procesando el import",
+	"el servidor se está deteniendo":                     "el servidor se está deteniendo",
+	"el ticket fue eliminado":                            "el ticket fue eliminado",
+	"no_serial ya asignado a una orden activa":           "no_serial ya asignado a una orden activa",
+	"fila inválida":                                      "fila inválida",
+	"ticket_id inválido":                                 "ticket_id inválido",
+
+	// Import files.
+	"CSV inválido":                                 "CSV inválido",
+	"XLSX inválido":                                "XLSX inválido",
+	"el archivo está vacío":                        "el archivo está vacío",
+	"error leyendo encabezado: %v":                 "error leyendo encabezado: %v",
+	"error leyendo hoja %q: %v":                    "error leyendo hoja %q: %v",
+	"la hoja %q está vacía":                        "la hoja %q está vacía",
+	"el libro no tiene hojas":                      "el libro no tiene hojas",
+	"hoja no encontrada: %q":                       "hoja no encontrada: %q",
+	"columna requerida: %s":                        "columna requerida: %s",
+	"las columnas %q y %q corresponden ambas a %s": "las columnas %q y %q corresponden ambas a %s",
+	"mapeo de columnas inválido":                   "mapeo de columnas inválido",
+	"campo desconocido %q, se esperaba uno de %s":  "campo desconocido %q, se esperaba uno de %s",
+	"encabezado vacío para %s":                     "encabezado vacío para %s",
+	"el encabezado %q está asignado a %s y %s":     "el encabezado %q está asignado a %s y %s",
+	"formato de exportación inválido":              "formato de exportación inválido",
+	"%q, se esperaba csv, xlsx o ndjson":           "%q, se esperaba csv, xlsx o ndjson",
+
+	// Batch summaries.
+	"%d tickets actualizados de forma exitosa": plural.Selectf(1, "%d",
+		"=1", "1 ticket actualizado de forma exitosa",
+		"other", "%[1]d tickets actualizados de forma exitosa"),
+	"simulación: %d tickets serían actualizados, no se guardó ningún cambio": plural.Selectf(1, "%d",
+		"=1", "simulación: 1 ticket sería actualizado, no se guardó ningún cambio",
+		"other", "simulación: %[1]d tickets serían actualizados, no se guardó ningún cambio"),
+	"simulación: la fila %d falla (%s), no se guardaría ningún cambio": "simulación: la fila %d falla (%s), no se guardaría ningún cambio",
+	"fila %d: %s; transacción revertida, no se guardó ningún cambio":   "fila %d: %s; transacción revertida, no se guardó ningún cambio",
+}
@@ -0,0 +1,74 @@
+// Package i18n selects the language of the messages sent to clients from
+// their Accept-Language header and formats them from the es and en
+// catalogs.
+//
+// Messages are keyed by the format string written in the code, so a message
+// missing from a catalog is still printed, in the language it was written in.
+package i18n
+
+import (
+	"context"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Supported lists the languages with a catalog. The first one is used when
+// the client accepts none of them.
+var Supported = []language.Tag{language.Spanish, language.English}
+
+// Default is the language of callers that do not send Accept-Language.
+var Default = Supported[0]
+
+var matcher = language.NewMatcher(Supported)
+
+// Match returns the supported language that best fits an Accept-Language
+// header, Default if none does.
+func Match(acceptLanguage string) language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+	_, i, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return Supported[i]
+}
+
+type languageKey struct{}
+
+func NewContext(ctx context.Context, tag language.Tag) context.Context {
+	return context.WithValue(ctx, languageKey{}, tag)
+}
+
+// FromContext returns the language stored by NewContext, or Default.
+func FromContext(ctx context.Context) language.Tag {
+	if tag, ok := ctx.Value(languageKey{}).(language.Tag); ok {
+		return tag
+	}
+	return Default
+}
+
+var printers = make(map[language.Tag]*message.Printer, len(Supported))
+
+func init() {
+	for _, tag := range Supported {
+		printers[tag] = message.NewPrinter(tag, message.Catalog(messages))
+	}
+}
+
+// Printer formats messages in tag, which must be one of Supported; other
+// languages get the Default printer. Numbers are formatted for the
+// language, so identifiers should be passed as strings.
+func Printer(tag language.Tag) *message.Printer {
+	if p, ok := printers[tag]; ok {
+		return p
+	}
+	return printers[Default]
+}
+
+// Sprintf formats the message key in the language of ctx.
+func Sprintf(ctx context.Context, key string, args ...any) string {
+	return Printer(FromContext(ctx)).Sprintf(key, args...)
+}
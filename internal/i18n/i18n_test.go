@@ -0,0 +1,97 @@
+package i18n
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"sort"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   language.Tag
+	}{
+		{"", language.Spanish},
+		{"en", language.English},
+		{"en-US,en;q=0.9", language.English},
+		{"es-CL,es;q=0.9,en;q=0.8", language.Spanish},
+		{"fr-FR, en;q=0.5", language.English},
+		{"fr", language.Spanish},
+		{"*", language.Spanish},
+		{"not a language;;", language.Spanish},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.header); got != tt.want {
+			t.Errorf("Match(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != Default {
+		t.Errorf("FromContext(empty) = %s, want %s", got, Default)
+	}
+	ctx := NewContext(context.Background(), language.English)
+	if got := Sprintf(ctx, "ruta no encontrada"); got != "route not found" {
+		t.Errorf("Sprintf = %q", got)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		tag  language.Tag
+		n    int
+		want string
+	}{
+		{language.Spanish, 1, "1 ticket actualizado de forma exitosa"},
+		{language.Spanish, 3, "3 tickets actualizados de forma exitosa"},
+		{language.English, 1, "1 ticket updated successfully"},
+		{language.English, 0, "0 tickets updated successfully"},
+	}
+	for _, tt := range tests {
+		if got := Printer(tt.tag).Sprintf("%d tickets actualizados de forma exitosa", tt.n); got != tt.want {
+			t.Errorf("%s %d: %q, want %q", tt.tag, tt.n, got, tt.want)
+		}
+	}
+}
+
+var verb = regexp.MustCompile(`%(\[\d+\])?[a-z]`)
+
+// TestCatalogs checks that both catalogs have the same keys and that every
+// translation uses the verbs of its key, in any order.
+func TestCatalogs(t *testing.T) {
+	for key := range esMessages {
+		if _, ok := enMessages[key]; !ok {
+			t.Errorf("%q is missing from the en catalog", key)
+		}
+	}
+	for key := range enMessages {
+		if _, ok := esMessages[key]; !ok {
+			t.Errorf("%q is missing from the es catalog", key)
+		}
+	}
+
+	verbs := func(s string) []string {
+		v := verb.FindAllString(s, -1)
+		for i := range v {
+			v[i] = v[i][len(v[i])-1:]
+		}
+		sort.Strings(v)
+		return v
+	}
+	for tag, catalog := range map[string]map[string]any{"es": esMessages, "en": enMessages} {
+		for key, msg := range catalog {
+			s, ok := msg.(string)
+			if !ok {
+				continue
+			}
+			if got, want := verbs(s), verbs(key); !slices.Equal(got, want) {
+				t.Errorf("%s: %q uses %v, its key %v", tag, s, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package i18n
+
+import (
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	"golang.org/x/text/language"
+)
+
+var translators = func() map[language.Tag]ut.Translator {
+	uni := ut.New(es.New(), es.New(), en.New())
+	esTrans, _ := uni.GetTranslator("es")
+	enTrans, _ := uni.GetTranslator("en")
+	return map[language.Tag]ut.Translator{
+		language.Spanish: esTrans,
+		language.English: enTrans,
+	}
+}()
+
+// RegisterValidator adds the es and en messages of the built-in validation
+// tags to v, so their field errors can be told with Translator.
+func RegisterValidator(v *validator.Validate) error {
+	if err := es_translations.RegisterDefaultTranslations(v, translators[language.Spanish]); err != nil {
+		return err
+	}
+	return en_translations.RegisterDefaultTranslations(v, translators[language.English])
+}
+
+// Translator is the validator translator for tag, see Printer.
+func Translator(tag language.Tag) ut.Translator {
+	if t, ok := translators[tag]; ok {
+		return t
+	}
+	return translators[Default]
+}
@@ -0,0 +1,209 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// memoryBodySize is the size up to which ReadBody keeps a body in memory.
+// Larger bodies, such as CSV uploads, are spooled to a temporary file.
+const memoryBodySize = 1 << 20
+
+// Body is a request body read ahead of the handler. Close removes its
+// temporary file, if any.
+type Body struct {
+	io.ReadSeeker
+	file *os.File
+}
+
+func (b *Body) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	os.Remove(b.file.Name())
+	return err
+}
+
+// ReadBody reads the body of r and returns it, rewound, with the fingerprint
+// of the request: a SHA-256 of its method, path, query and content. The
+// content of a multipart form is that of its parts, so a retry that picks
+// another boundary has the same fingerprint. The body is read whole, so
+// callers limit its size first, e.g. with http.MaxBytesReader.
+func ReadBody(r *http.Request) (*Body, string, error) {
+	body, err := spool(r.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") || hashMultipart(h, body, params["boundary"]) != nil {
+		if err := hashRaw(h, body); err != nil {
+			body.Close()
+			return nil, "", err
+		}
+	}
+
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		body.Close()
+		return nil, "", err
+	}
+	return body, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func spool(src io.Reader) (*Body, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(src, memoryBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if n <= memoryBodySize {
+		return &Body{ReadSeeker: bytes.NewReader(buf.Bytes())}, nil
+	}
+
+	f, err := os.CreateTemp("", "idempotency-*")
+	if err != nil {
+		return nil, err
+	}
+	body := &Body{ReadSeeker: f, file: f}
+	if _, err := io.Copy(f, io.MultiReader(&buf, src)); err != nil {
+		body.Close()
+		return nil, err
+	}
+	return body, nil
+}
+
+func hashRaw(h hash.Hash, body *Body) error {
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(h, body)
+	return err
+}
+
+// hashMultipart hashes the name, file name and content of every part. It
+// fails on a malformed form, which is then hashed as is.
+func hashMultipart(h hash.Hash, body *Body, boundary string) error {
+	if boundary == "" {
+		return errors.New("multipart sin boundary")
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	mr := multipart.NewReader(body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%q %q\n", part.FormName(), part.FileName())
+		n, err := io.Copy(h, part)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "\n%d\n", n)
+	}
+}
+
+// replayHeaders are the response headers kept in a Record. Others, such as
+// the rate limit ones, describe the response being sent and not the stored
+// one.
+var replayHeaders = []string{"Content-Type", "Content-Language", "Content-Disposition", "Location", "ETag", "Link"}
+
+// MaxRecordBody bounds the response body kept for a Record. Larger
+// responses, such as the annotated CSV report of a big import, are sent but
+// not kept, see Recorder.Truncated.
+const MaxRecordBody = 1 << 20
+
+// Recorder passes a response through to the client while keeping a copy of
+// it for a Record, up to MaxRecordBody.
+type Recorder struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+func (rw *Recorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *Recorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if !rw.truncated {
+		if rw.body.Len()+len(b) > MaxRecordBody {
+			rw.truncated = true
+			rw.body = bytes.Buffer{}
+		} else {
+			rw.body.Write(b)
+		}
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *Recorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Status is the status code sent, 0 if nothing was.
+func (rw *Recorder) Status() int {
+	return rw.status
+}
+
+// Truncated reports whether the body outgrew MaxRecordBody and was not kept,
+// in which case the response cannot be recorded.
+func (rw *Recorder) Truncated() bool {
+	return rw.truncated
+}
+
+// Record returns the response recorded for the request with fingerprint.
+func (rw *Recorder) Record(fingerprint string) *Record {
+	header := make(http.Header)
+	for _, name := range replayHeaders {
+		for _, v := range rw.Header().Values(name) {
+			header.Add(name, v)
+		}
+	}
+	return &Record{Fingerprint: fingerprint, Status: rw.status, Header: header, Body: rw.body.Bytes()}
+}
+
+// Replay writes the response of rec, marked with ReplayedHeader.
+func Replay(w http.ResponseWriter, rec *Record) error {
+	for name, values := range rec.Header {
+		w.Header().Del(name)
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	_, err := w.Write(rec.Body)
+	return err
+}
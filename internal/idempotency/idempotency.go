@@ -0,0 +1,67 @@
+// Package idempotency stores the responses of requests sent with an
+// Idempotency-Key header, so that a client retrying after a timeout gets the
+// original response instead of applying the request twice.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Header is the request header carrying the key chosen by the client.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set to "true" on a replayed response.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// PendingTTL bounds how long a key stays reserved by a request that never
+// finishes, e.g. because the replica died while serving it.
+const PendingTTL = 2 * time.Minute
+
+// MaxKeyLength bounds the keys accepted from clients.
+const MaxKeyLength = 255
+
+// Record is what a Store keeps for a key.
+type Record struct {
+	// Fingerprint identifies the request, see ReadBody.
+	Fingerprint string `json:"fingerprint"`
+	// Status is 0 while the first request is being served.
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// Pending reports whether the request that reserved the key has not
+// finished yet.
+func (r *Record) Pending() bool {
+	return r.Status == 0
+}
+
+// Store keeps the records by key. Sharing a Store between replicas makes a
+// retry replay the response whichever replica served the first request.
+type Store interface {
+	// Begin reserves key for a request with fingerprint for ttl and returns
+	// nil. If the key is already taken it returns its record instead, pending
+	// or complete, and leaves it untouched.
+	Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error)
+	// Finish stores the response of the request that reserved key, kept for
+	// ttl.
+	Finish(ctx context.Context, key string, rec *Record, ttl time.Duration) error
+	// Abort releases key without a response, so the request can be retried.
+	Abort(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key can be used as an Idempotency-Key: between 1
+// and MaxKeyLength visible ASCII characters.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > MaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store {
+			s := NewMemoryStore(time.Minute)
+			t.Cleanup(s.Stop)
+			return s
+		},
+		"redis": func(t *testing.T) Store {
+			client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			t.Cleanup(func() { client.Close() })
+			return NewRedisStore(client)
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newStore(t)
+
+			if rec, err := s.Begin(ctx, "k", "fp", time.Minute); rec != nil || err != nil {
+				t.Fatalf("Begin new key = %+v, %v", rec, err)
+			}
+			rec, err := s.Begin(ctx, "k", "other", time.Minute)
+			if err != nil || rec == nil || !rec.Pending() || rec.Fingerprint != "fp" {
+				t.Fatalf("Begin pending key = %+v, %v", rec, err)
+			}
+
+			want := &Record{Fingerprint: "fp", Status: http.StatusCreated, Header: http.Header{"Location": {"/x"}}, Body: []byte(`{"id":1}`)}
+			if err := s.Finish(ctx, "k", want, time.Minute); err != nil {
+				t.Fatalf("Finish: %v", err)
+			}
+			rec, err = s.Begin(ctx, "k", "fp", time.Minute)
+			if err != nil || rec == nil || rec.Status != http.StatusCreated || string(rec.Body) != `{"id":1}` || rec.Header.Get("Location") != "/x" {
+				t.Fatalf("Begin finished key = %+v, %v", rec, err)
+			}
+
+			if err := s.Abort(ctx, "k"); err != nil {
+				t.Fatalf("Abort: %v", err)
+			}
+			if rec, err := s.Begin(ctx, "k", "fp", time.Minute); rec != nil || err != nil {
+				t.Fatalf("Begin aborted key = %+v, %v", rec, err)
+			}
+		})
+	}
+}
+
+func multipartRequest(t *testing.T, boundary, content string) *http.Request {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.SetBoundary(boundary); err != nil {
+		t.Fatal(err)
+	}
+	fw, err := mw.CreateFormFile("file", "tickets.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, content)
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/imports", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func fingerprint(t *testing.T, r *http.Request) string {
+	t.Helper()
+
+	body, fp, err := ReadBody(r)
+	if err != nil {
+		t.Fatalf("ReadBody: %v", err)
+	}
+	defer body.Close()
+	return fp
+}
+
+func TestReadBodyFingerprint(t *testing.T) {
+	a := fingerprint(t, multipartRequest(t, "aaaa", "ticket_id\n1\n"))
+	if b := fingerprint(t, multipartRequest(t, "bbbb", "ticket_id\n1\n")); a != b {
+		t.Error("the fingerprint depends on the multipart boundary")
+	}
+	if c := fingerprint(t, multipartRequest(t, "aaaa", "ticket_id\n2\n")); a == c {
+		t.Error("different files have the same fingerprint")
+	}
+
+	post := func(target, body string) string {
+		return fingerprint(t, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+	}
+	if post("/a", `{"x":1}`) == post("/b", `{"x":1}`) {
+		t.Error("different paths have the same fingerprint")
+	}
+	if post("/a?dry_run=true", `{"x":1}`) == post("/a", `{"x":1}`) {
+		t.Error("different queries have the same fingerprint")
+	}
+}
+
+func TestReadBodyRewinds(t *testing.T) {
+	for _, size := range []int{10, memoryBodySize + 10} {
+		content := strings.Repeat("x", size)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(content))
+
+		body, _, err := ReadBody(r)
+		if err != nil {
+			t.Fatalf("ReadBody(%d bytes): %v", size, err)
+		}
+		if (body.file != nil) != (size > memoryBodySize) {
+			t.Errorf("%d bytes: spooled to a file = %v", size, body.file != nil)
+		}
+		got, err := io.ReadAll(body)
+		if err != nil || string(got) != content {
+			t.Errorf("%d bytes: read back %d bytes, %v", size, len(got), err)
+		}
+		body.Close()
+	}
+}
+
+func TestRecorderLimit(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := NewRecorder(w)
+	rw.Write([]byte(strings.Repeat("x", MaxRecordBody)))
+	if rw.Truncated() || len(rw.Record("fp").Body) != MaxRecordBody {
+		t.Fatalf("a body of MaxRecordBody bytes was not kept")
+	}
+
+	rw.Write([]byte("y"))
+	if !rw.Truncated() || len(rw.Record("fp").Body) != 0 {
+		t.Errorf("a body over MaxRecordBody was kept")
+	}
+	if w.Body.Len() != MaxRecordBody+1 {
+		t.Errorf("the client got %d bytes, want all %d", w.Body.Len(), MaxRecordBody+1)
+	}
+}
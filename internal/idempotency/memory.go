@@ -0,0 +1,88 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a process local Store.
+type MemoryStore struct {
+	sync.Mutex
+	records map[string]*memoryRecord
+	stop    chan struct{}
+	once    sync.Once
+}
+
+type memoryRecord struct {
+	Record
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns a Store whose expired keys are removed every
+// sweepEvery.
+func NewMemoryStore(sweepEvery time.Duration) *MemoryStore {
+	s := &MemoryStore{records: make(map[string]*memoryRecord), stop: make(chan struct{})}
+
+	go func() {
+		ticker := time.NewTicker(sweepEvery)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				s.sweep(now)
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *MemoryStore) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	now := time.Now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if r, ok := s.records[key]; ok && now.Before(r.expiresAt) {
+		rec := r.Record
+		return &rec, nil
+	}
+	s.records[key] = &memoryRecord{Record: Record{Fingerprint: fingerprint}, expiresAt: now.Add(ttl)}
+	return nil, nil
+}
+
+func (s *MemoryStore) Finish(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.records[key] = &memoryRecord{Record: *rec, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Abort(ctx context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// Stop releases the sweeping goroutine.
+func (s *MemoryStore) Stop() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	for key, r := range s.records {
+		if !now.Before(r.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix keeps the keys apart from the rate limiter counters when both
+// share a server.
+const keyPrefix = "idempotency:"
+
+// RedisStore is a Store shared through any server speaking the Redis protocol.
+// Records are kept as JSON.
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	value, err := json.Marshal(Record{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	// SET NX is retried once: the key may expire between a failed SET and
+	// the GET that reads it.
+	for range 2 {
+		ok, err := s.client.SetNX(ctx, keyPrefix+key, value, ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, nil
+		}
+
+		stored, err := s.client.Get(ctx, keyPrefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var rec Record
+		if err := json.Unmarshal(stored, &rec); err != nil {
+			return nil, err
+		}
+		return &rec, nil
+	}
+	return nil, errors.New("idempotency key expired while being read")
+}
+
+func (s *RedisStore) Finish(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, keyPrefix+key, value, ttl).Err()
+}
+
+func (s *RedisStore) Abort(ctx context.Context, key string) error {
+	return s.client.Del(ctx, keyPrefix+key).Err()
+}
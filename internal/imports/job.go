@@ -0,0 +1,85 @@
+package imports
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/i18n"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
+)
+
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Done reports whether s is final.
+func (s State) Done() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCancelled
+}
+
+// Job is a CSV import running in the background. Rows are kept as they are
+// processed, so a cancelled or failed job still reports what was written.
+type Job struct {
+	ID         string                  `json:"id"`
+	State      State                   `json:"state"`
+	FileName   string                  `json:"file_name,omitempty"`
+	Format     string                  `json:"format"`
+	Sheet      string                  `json:"sheet,omitempty"`
+	Profile    string                  `json:"profile,omitempty"`
+	Actor      string                  `json:"actor,omitempty"`
+	DryRun     bool                    `json:"dry_run,omitempty"`
+	Total      int                     `json:"total"`
+	Processed  int                     `json:"processed"`
+	Summary    dto.TicketUpsertSummary `json:"summary"`
+	Error      string                  `json:"error,omitempty"`
+	CreatedAt  time.Time               `json:"created_at"`
+	StartedAt  *time.Time              `json:"started_at,omitempty"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+	Rows       []dto.TicketRowResult   `json:"rows,omitempty"`
+}
+
+// job is the mutable state behind a Job, guarded by mu.
+type job struct {
+	mu  sync.Mutex
+	Job Job
+
+	path   string
+	sheet  string
+	ctx    context.Context
+	cancel context.CancelFunc
+	opts   services.UpsertOptions
+}
+
+// snapshot copies the job, with its rows when withRows is set.
+func (j *job) snapshot(withRows bool) Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := j.Job
+	out.Rows = nil
+	if withRows {
+		out.Rows = append([]dto.TicketRowResult{}, j.Job.Rows...)
+	}
+	return out
+}
+
+func (j *job) finish(state State, err error, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.Job.State = state
+	if err != nil {
+		// In the language of the request that submitted the job.
+		j.Job.Error = apierror.Message(i18n.FromContext(j.ctx), err)
+	}
+	j.Job.FinishedAt = &now
+}
@@ -0,0 +1,381 @@
+package imports
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotFound     = apierror.New(apierror.CodeNotFound, "import no encontrado")
+	ErrQueueFull    = apierror.New(apierror.CodeQueueFull, "cola de imports llena, intente más tarde")
+	ErrJobFinished  = apierror.New(apierror.CodeImportFinished, "el import ya terminó")
+	ErrShuttingDown = apierror.New(apierror.CodeUnavailable, "el servidor se está deteniendo")
+	errPanic        = apierror.New(apierror.CodeInternal, "error interno procesando el import")
+)
+
+// Importer runs an import, services.TicketService in production.
+type Importer interface {
+	UpsertBatchCSV(ctx context.Context, src io.Reader, opts services.UpsertOptions) (*dto.TicketUpsertResponse, error)
+	UpsertBatchXLSX(ctx context.Context, src io.Reader, sheet string, opts services.UpsertOptions) (*dto.TicketUpsertResponse, error)
+}
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Upload is a file to import. Sheet picks the sheet of an XLSX workbook and
+// Profile names the import profile whose mapping is passed in the options.
+type Upload struct {
+	File     io.Reader
+	FileName string
+	Format   string
+	Sheet    string
+	Profile  string
+}
+
+type Config struct {
+	// Workers is the number of imports run at the same time.
+	Workers int
+	// QueueSize is the number of imports that can wait for a worker.
+	QueueSize int
+	// Retention is how long finished jobs can still be queried.
+	Retention time.Duration
+	// Dir is where uploads are kept until processed, os.TempDir() if empty.
+	Dir string
+}
+
+// Manager queues uploads and runs them on a fixed pool of workers. Jobs live
+// in memory, so they are only visible on the instance that received them.
+type Manager struct {
+	importer Importer
+	config   Config
+	logger   *zap.SugaredLogger
+	now      func() time.Time
+
+	mu   sync.RWMutex
+	jobs map[string]*job
+
+	queue  chan *job
+	stop   chan struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewManager(importer Importer, config Config, logger *zap.SugaredLogger) *Manager {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.QueueSize < 0 {
+		config.QueueSize = 0
+	}
+
+	m := &Manager{
+		importer: importer,
+		config:   config,
+		logger:   logger,
+		now:      time.Now,
+		jobs:     make(map[string]*job),
+		queue:    make(chan *job, config.QueueSize),
+		stop:     make(chan struct{}),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	if config.Retention > 0 {
+		m.wg.Add(1)
+		go m.janitor()
+	}
+	return m
+}
+
+// Submit copies the upload to disk and queues it. ctx only carries request
+// values such as the audit metadata, the job is not cancelled when it ends.
+func (m *Manager) Submit(ctx context.Context, upload Upload, actor string, opts services.UpsertOptions) (Job, error) {
+	if upload.Format != FormatXLSX {
+		upload.Format = FormatCSV
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	path, err := m.spool(upload.File, upload.Format)
+	if err != nil {
+		return Job{}, err
+	}
+
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	j := &job{
+		Job: Job{
+			ID:        id,
+			State:     StateQueued,
+			FileName:  upload.FileName,
+			Format:    upload.Format,
+			Sheet:     upload.Sheet,
+			Profile:   upload.Profile,
+			Actor:     actor,
+			DryRun:    opts.DryRun,
+			CreatedAt: m.now(),
+		},
+		path:   path,
+		sheet:  upload.Sheet,
+		ctx:    jobCtx,
+		cancel: cancel,
+		opts:   opts,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		cancel()
+		os.Remove(path)
+		return Job{}, ErrShuttingDown
+	}
+
+	select {
+	case m.queue <- j:
+	default:
+		cancel()
+		os.Remove(path)
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[id] = j
+	return j.snapshot(false), nil
+}
+
+// Get returns the job with its rows.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.RLock()
+	j, ok := m.jobs[id]
+	m.mu.RUnlock()
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.snapshot(true), nil
+}
+
+// List returns every job, newest first, without their rows.
+func (m *Manager) List() []Job {
+	m.mu.RLock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j.snapshot(false))
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].CreatedAt.After(jobs[k].CreatedAt) })
+	return jobs
+}
+
+// Cancel stops a queued or running job. Rows already written stay written.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.RLock()
+	j, ok := m.jobs[id]
+	m.mu.RUnlock()
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	j.mu.Lock()
+	if j.Job.State.Done() {
+		j.mu.Unlock()
+		return j.snapshot(false), ErrJobFinished
+	}
+	if j.Job.State == StateQueued {
+		// The worker skips it when its turn comes.
+		now := m.now()
+		j.Job.State = StateCancelled
+		j.Job.FinishedAt = &now
+	}
+	j.mu.Unlock()
+
+	j.cancel()
+	return j.snapshot(false), nil
+}
+
+// Shutdown stops accepting jobs, cancels the ones queued or running and waits
+// for the workers to return or ctx to end.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.stop)
+		close(m.queue)
+		for _, j := range m.jobs {
+			j.cancel()
+		}
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for j := range m.queue {
+		m.runJob(j)
+	}
+}
+
+func (m *Manager) runJob(j *job) {
+	defer os.Remove(j.path)
+	// A bad file must not take the whole API down with it.
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Errorw("import panicked", "job_id", j.Job.ID, "panic", r, "stack", string(debug.Stack()))
+			j.finish(StateFailed, errPanic, m.now())
+		}
+	}()
+
+	j.mu.Lock()
+	if j.Job.State != StateQueued {
+		// Cancelled while waiting for a worker.
+		j.mu.Unlock()
+		return
+	}
+	if j.ctx.Err() != nil {
+		// Cancelled by Shutdown before it started.
+		now := m.now()
+		j.Job.State = StateCancelled
+		j.Job.FinishedAt = &now
+		j.mu.Unlock()
+		return
+	}
+	started := m.now()
+	j.Job.State = StateRunning
+	j.Job.StartedAt = &started
+	j.mu.Unlock()
+
+	resp, err := m.process(j.ctx, j, j.opts)
+	switch {
+	case err == nil && resp.RolledBack:
+		j.finish(StateFailed, errors.New(resp.Message), m.now())
+	case err == nil:
+		j.finish(StateSucceeded, nil, m.now())
+	case errors.Is(err, context.Canceled):
+		j.finish(StateCancelled, nil, m.now())
+	default:
+		m.logger.Warnw("import failed", "job_id", j.Job.ID, "error", err)
+		j.finish(StateFailed, err, m.now())
+	}
+}
+
+func (m *Manager) process(ctx context.Context, j *job, opts services.UpsertOptions) (*dto.TicketUpsertResponse, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo archivo del import: %w", err)
+	}
+	defer f.Close()
+
+	count := func(src io.Reader) (int, error) { return services.CountCSVRows(src, opts.Columns) }
+	run := m.importer.UpsertBatchCSV
+	if j.Job.Format == FormatXLSX {
+		count = func(src io.Reader) (int, error) { return services.CountXLSXRows(src, j.sheet, opts.Columns) }
+		run = func(ctx context.Context, src io.Reader, opts services.UpsertOptions) (*dto.TicketUpsertResponse, error) {
+			return m.importer.UpsertBatchXLSX(ctx, src, j.sheet, opts)
+		}
+	}
+
+	total, err := count(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	j.Job.Total = total
+	j.mu.Unlock()
+
+	opts.Progress = func(row dto.TicketRowResult, summary dto.TicketUpsertSummary) {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.Job.Processed++
+		j.Job.Summary = summary
+		j.Job.Rows = append(j.Job.Rows, row)
+	}
+	return run(ctx, f, opts)
+}
+
+func (m *Manager) spool(src io.Reader, format string) (string, error) {
+	f, err := os.CreateTemp(m.config.Dir, "import-*."+format)
+	if err != nil {
+		return "", fmt.Errorf("error guardando archivo del import: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, src); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("error guardando archivo del import: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// janitor forgets finished jobs older than the retention.
+func (m *Manager) janitor() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.Retention / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.sweep()
+		}
+	}
+}
+
+// sweep forgets the jobs finished before the retention.
+func (m *Manager) sweep() {
+	cutoff := m.now().Add(-m.config.Retention)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, j := range m.jobs {
+		j.mu.Lock()
+		expired := j.Job.FinishedAt != nil && j.Job.FinishedAt.Before(cutoff)
+		j.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("error generando id de import: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
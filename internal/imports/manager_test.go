@@ -0,0 +1,243 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/services"
+)
+
+// blockingImporter runs each import until release is closed or its context
+// is cancelled, reporting on started when it begins.
+type blockingImporter struct {
+	started chan string
+	release chan struct{}
+	panics  bool
+}
+
+func newBlockingImporter() *blockingImporter {
+	return &blockingImporter{started: make(chan string, 10), release: make(chan struct{})}
+}
+
+func (b *blockingImporter) UpsertBatchCSV(ctx context.Context, src io.Reader, opts services.UpsertOptions) (*dto.TicketUpsertResponse, error) {
+	if b.panics {
+		panic("boom")
+	}
+	b.started <- "csv"
+	select {
+	case <-b.release:
+		return &dto.TicketUpsertResponse{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *blockingImporter) UpsertBatchXLSX(ctx context.Context, src io.Reader, sheet string, opts services.UpsertOptions) (*dto.TicketUpsertResponse, error) {
+	return b.UpsertBatchCSV(ctx, src, opts)
+}
+
+func newTestManager(t *testing.T, importer Importer, config Config) *Manager {
+	t.Helper()
+	config.Dir = t.TempDir()
+	m := NewManager(importer, config, zap.NewNop().Sugar())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = m.Shutdown(ctx)
+	})
+	return m
+}
+
+func submit(t *testing.T, m *Manager) (Job, error) {
+	t.Helper()
+	return m.Submit(context.Background(), Upload{File: strings.NewReader("ticket_id\n1\n2\n"), FileName: "t.csv"}, "ana", services.UpsertOptions{})
+}
+
+// waitState polls the job until it reaches state.
+func waitState(t *testing.T, m *Manager, id string, state State) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", id, err)
+		}
+		if job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, job.State, state)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManagerRunsJob(t *testing.T) {
+	importer := newBlockingImporter()
+	close(importer.release)
+	m := newTestManager(t, importer, Config{Workers: 1, QueueSize: 1})
+
+	job, err := submit(t, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := waitState(t, m, job.ID, StateSucceeded)
+	if got.Total != 2 || got.StartedAt == nil || got.FinishedAt == nil || got.Actor != "ana" {
+		t.Errorf("job = %+v", got)
+	}
+}
+
+func TestManagerQueueFull(t *testing.T) {
+	importer := newBlockingImporter()
+	defer close(importer.release)
+	m := newTestManager(t, importer, Config{Workers: 1, QueueSize: 1})
+
+	running, err := submit(t, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-importer.started
+	if _, err := submit(t, m); err != nil {
+		t.Fatalf("queued job: %v", err)
+	}
+
+	if _, err := submit(t, m); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit = %v, want ErrQueueFull", err)
+	}
+	if n := len(m.List()); n != 2 {
+		t.Errorf("List() has %d jobs, want 2", n)
+	}
+	waitState(t, m, running.ID, StateRunning)
+}
+
+func TestManagerCancel(t *testing.T) {
+	importer := newBlockingImporter()
+	m := newTestManager(t, importer, Config{Workers: 1, QueueSize: 1})
+
+	running, _ := submit(t, m)
+	<-importer.started
+	queued, _ := submit(t, m)
+
+	job, err := m.Cancel(queued.ID)
+	if err != nil || job.State != StateCancelled || job.FinishedAt == nil {
+		t.Fatalf("Cancel(queued) = %+v, %v", job, err)
+	}
+	if _, err := m.Cancel(running.ID); err != nil {
+		t.Fatalf("Cancel(running): %v", err)
+	}
+	waitState(t, m, running.ID, StateCancelled)
+
+	if _, err := m.Cancel(running.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("Cancel(finished) = %v, want ErrJobFinished", err)
+	}
+	if _, err := m.Cancel("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel(unknown) = %v, want ErrNotFound", err)
+	}
+
+	// The worker skips the cancelled job and takes the next one.
+	next, _ := submit(t, m)
+	<-importer.started
+	waitState(t, m, queued.ID, StateCancelled)
+	close(importer.release)
+	waitState(t, m, next.ID, StateSucceeded)
+}
+
+func TestManagerPanic(t *testing.T) {
+	importer := newBlockingImporter()
+	importer.panics = true
+	m := newTestManager(t, importer, Config{Workers: 1, QueueSize: 1})
+
+	job, _ := submit(t, m)
+	got := waitState(t, m, job.ID, StateFailed)
+	if got.Error != "error interno procesando el import" {
+		t.Errorf("Error = %q", got.Error)
+	}
+
+	// The worker survived.
+	importer.panics = false
+	close(importer.release)
+	job, _ = submit(t, m)
+	waitState(t, m, job.ID, StateSucceeded)
+}
+
+func TestManagerSweep(t *testing.T) {
+	importer := newBlockingImporter()
+	m := newTestManager(t, importer, Config{Workers: 1, QueueSize: 1, Retention: time.Hour})
+
+	var mu sync.Mutex
+	now := time.Now()
+	m.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	running, _ := submit(t, m)
+	<-importer.started
+	queued, _ := submit(t, m)
+	if _, err := m.Cancel(queued.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	now = now.Add(30 * time.Minute)
+	mu.Unlock()
+	m.sweep()
+	if _, err := m.Get(queued.ID); err != nil {
+		t.Errorf("job finished 30m ago was swept: %v", err)
+	}
+
+	mu.Lock()
+	now = now.Add(31 * time.Minute)
+	mu.Unlock()
+	m.sweep()
+	if _, err := m.Get(queued.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(expired) = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Get(running.ID); err != nil {
+		t.Errorf("running job was swept: %v", err)
+	}
+	close(importer.release)
+}
+
+func TestManagerShutdown(t *testing.T) {
+	importer := newBlockingImporter()
+	config := Config{Workers: 1, QueueSize: 2}
+	config.Dir = t.TempDir()
+	m := NewManager(importer, config, zap.NewNop().Sugar())
+
+	running, _ := submit(t, m)
+	<-importer.started
+	queued, _ := submit(t, m)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	// Shutdown has waited for the workers: every job is final.
+	for _, id := range []string{running.ID, queued.ID} {
+		if job, _ := m.Get(id); job.State != StateCancelled {
+			t.Errorf("job %s is %s after Shutdown, want cancelled", id, job.State)
+		}
+	}
+	if _, err := submit(t, m); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Submit after Shutdown = %v, want ErrShuttingDown", err)
+	}
+	if files, _ := os.ReadDir(config.Dir); len(files) != 0 {
+		t.Errorf("%d uploads left in %s", len(files), config.Dir)
+	}
+	if err := m.Shutdown(ctx); err != nil {
+		t.Errorf("second Shutdown: %v", err)
+	}
+}
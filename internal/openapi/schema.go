@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// Schema returns the schema of the type of v, read from its json and
+// validate tags. Named structs and the types given to Enum are added to
+// c.Schemas once and referenced.
+//
+// A property is required when validated as such or when it is always
+// encoded: neither a pointer nor omitempty. Pointers without omitempty are
+// nullable.
+func (c *Components) Schema(v any) *Schema {
+	return c.schemaOf(reflect.TypeOf(v))
+}
+
+// Enum describes the named type of values, such as a string type with
+// constants, as a schema listing them.
+func (c *Components) Enum(values ...any) *Schema {
+	t := reflect.TypeOf(values[0])
+	s := c.schemaOf(t)
+	if s.Ref != "" {
+		s = c.Schemas[c.names[t]]
+	}
+	name := c.name(t)
+	s.Enum = values
+	c.Schemas[name] = s
+	return Ref(name)
+}
+
+func (c *Components) schemaOf(t reflect.Type) *Schema {
+	if name, ok := c.names[t]; ok {
+		return Ref(name)
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return c.schemaOf(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: c.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: c.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return c.structSchema(t)
+		}
+		// The schema is registered before its fields are read, so a type
+		// referring to itself gets a reference.
+		name := c.name(t)
+		s := &Schema{}
+		c.Schemas[name] = s
+		*s = *c.structSchema(t)
+		return Ref(name)
+	}
+	// Interfaces and anything else take any value.
+	return &Schema{}
+}
+
+// name registers t and returns the name of its schema: the name of the type,
+// prefixed with its package when another type already took it.
+func (c *Components) name(t reflect.Type) string {
+	if name, ok := c.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := c.Schemas[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	c.names[t] = name
+	return name
+}
+
+func (c *Components) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded := c.structSchema(ft)
+			for k, p := range embedded.Properties {
+				s.Properties[k] = p
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		p := c.schemaOf(f.Type)
+		flags := strings.Split(opts, ",")
+		omitted := slices.Contains(flags, "omitempty") || slices.Contains(flags, "omitzero")
+		pointer := f.Type.Kind() == reflect.Pointer
+		if pointer && !omitted && p.Ref == "" {
+			p.Nullable = true
+		}
+		required := applyRules(p, ft, f.Tag.Get("validate"))
+		if required || (!omitted && !pointer) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = p
+	}
+	slices.Sort(s.Required)
+	return s
+}
+
+// applyRules adds the bounds of the validate tag rules to s, the schema of a
+// field of type t, and reports whether the field is required. Rules after
+// dive apply to the elements and are ignored.
+func applyRules(s *Schema, t reflect.Type, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "min", "max", "len":
+			if s.Ref != "" {
+				continue
+			}
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: regla %q inválida para %s", rule, t))
+			}
+			if name != "max" {
+				bound(s, t, n, true)
+			}
+			if name != "min" {
+				bound(s, t, n, false)
+			}
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, v)
+			}
+		}
+	}
+	return required
+}
+
+// bound sets the lower or upper bound n on s, a length for strings and
+// collections and a value for numbers.
+func bound(s *Schema, t reflect.Type, n int, lower bool) {
+	var length **int
+	switch t.Kind() {
+	case reflect.String:
+		length = pick(lower, &s.MinLength, &s.MaxLength)
+	case reflect.Slice, reflect.Array:
+		length = pick(lower, &s.MinItems, &s.MaxItems)
+	case reflect.Map:
+		length = pick(lower, &s.MinProperties, &s.MaxProperties)
+	default:
+		v := float64(n)
+		*pick(lower, &s.Minimum, &s.Maximum) = &v
+		return
+	}
+	*length = &n
+}
+
+func pick[T any](first bool, a, b T) T {
+	if first {
+		return a
+	}
+	return b
+}
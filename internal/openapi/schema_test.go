@@ -0,0 +1,103 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type color string
+
+type item struct {
+	Name string `json:"name"`
+}
+
+type node struct {
+	Next *node `json:"next,omitempty"`
+}
+
+type base struct {
+	ID int64 `json:"id"`
+}
+
+type payload struct {
+	base
+	Name     string            `json:"name" validate:"required,max=10"`
+	Note     *string           `json:"note,omitempty" validate:"omitempty,max=5"`
+	Parent   *int64            `json:"parent"`
+	Tags     []string          `json:"tags,omitempty" validate:"min=1,dive,max=3"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Color    color             `json:"color"`
+	Size     string            `json:"size,omitempty" validate:"oneof=s m l"`
+	Items    []item            `json:"items"`
+	Node     node              `json:"node"`
+	At       time.Time         `json:"at"`
+	Data     []byte            `json:"data,omitempty"`
+	Any      any               `json:"any,omitempty"`
+	Skipped  string            `json:"-"`
+	internal string
+}
+
+func TestSchema(t *testing.T) {
+	c := NewComponents()
+	c.Enum(color("red"), color("blue"))
+	got := c.Schema(payload{})
+
+	if got.Ref != "#/components/schemas/payload" {
+		t.Fatalf("Schema = %+v, want a reference", got)
+	}
+	s := c.Schemas["payload"]
+
+	wantRequired := []string{"at", "color", "id", "items", "name", "node"}
+	if !reflect.DeepEqual(s.Required, wantRequired) {
+		t.Errorf("required = %v, want %v", s.Required, wantRequired)
+	}
+	if len(s.Properties) != 13 {
+		t.Errorf("properties = %d, want 13", len(s.Properties))
+	}
+
+	p := s.Properties
+	if p["name"].Type != "string" || *p["name"].MaxLength != 10 {
+		t.Errorf("name = %+v", p["name"])
+	}
+	if p["note"].Nullable || *p["note"].MaxLength != 5 {
+		t.Errorf("note = %+v", p["note"])
+	}
+	if !p["parent"].Nullable || p["parent"].Format != "int64" {
+		t.Errorf("parent = %+v", p["parent"])
+	}
+	if *p["tags"].MinItems != 1 || p["tags"].MaxItems != nil || p["tags"].Items.MaxLength != nil {
+		t.Errorf("tags = %+v", p["tags"])
+	}
+	if p["labels"].AdditionalProperties.Type != "string" {
+		t.Errorf("labels = %+v", p["labels"])
+	}
+	if p["color"].Ref != "#/components/schemas/color" || !reflect.DeepEqual(c.Schemas["color"].Enum, []any{color("red"), color("blue")}) {
+		t.Errorf("color = %+v, schema %+v", p["color"], c.Schemas["color"])
+	}
+	if !reflect.DeepEqual(p["size"].Enum, []any{"s", "m", "l"}) {
+		t.Errorf("size = %+v", p["size"])
+	}
+	if p["items"].Items.Ref != "#/components/schemas/item" || c.Schemas["item"].Properties["name"].Type != "string" {
+		t.Errorf("items = %+v", p["items"])
+	}
+	if c.Schemas["node"].Properties["next"].Ref != "#/components/schemas/node" {
+		t.Errorf("node = %+v", c.Schemas["node"])
+	}
+	if p["at"].Format != "date-time" || p["data"].Format != "byte" {
+		t.Errorf("at = %+v, data = %+v", p["at"], p["data"])
+	}
+}
+
+func TestSchemaNames(t *testing.T) {
+	type Problem struct{}
+
+	c := NewComponents()
+	c.Schemas["Problem"] = &Schema{}
+	if got := c.Schema(Problem{}); got.Ref != "#/components/schemas/OpenapiProblem" {
+		t.Errorf("Schema = %s, want the package prefix", got.Ref)
+	}
+	if got := c.Schema(Problem{}); got.Ref != "#/components/schemas/OpenapiProblem" {
+		t.Errorf("second Schema = %s", got.Ref)
+	}
+}
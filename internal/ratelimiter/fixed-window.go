@@ -1,47 +1,64 @@
 package ratelimiter
 
 import (
-	"sync"
+	"context"
 	"time"
 )
 
+// FixedWindowRateLimiter allows limit requests per key in consecutive windows.
+// It is the cheapest algorithm but lets a client send up to 2x limit around a
+// window boundary.
 type FixedWindowRateLimiter struct {
-	sync.RWMutex
-	clients map[string]int
+	store   Store
+	prefix  string
 	limit   int
 	window  time.Duration
+	onError func(error)
+	// own is the store created by NewFixedWindowLimiter, stopped by Stop.
+	own *MemoryStore
 }
 
 func NewFixedWindowLimiter(limit int, window time.Duration) *FixedWindowRateLimiter {
+	own := NewMemoryStore(window)
+	rl := newFixedWindowLimiter(own, "", limit, window, nil)
+	rl.own = own
+	return rl
+}
+
+func newFixedWindowLimiter(store Store, prefix string, limit int, window time.Duration, onError func(error)) *FixedWindowRateLimiter {
 	return &FixedWindowRateLimiter{
-		clients: make(map[string]int),
+		store:   store,
+		prefix:  prefix,
 		limit:   limit,
 		window:  window,
+		onError: onError,
 	}
 }
 
-func (rl *FixedWindowRateLimiter) Allow(ip string) (bool, time.Duration) {
-	rl.RLock()
-	count, exists := rl.clients[ip]
-	rl.RUnlock()
+func (rl *FixedWindowRateLimiter) Allow(key string) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
 
-	if !exists || count < rl.limit {
-		rl.Lock()
-		if !exists {
-			go rl.resetCount(ip)
-		}
+	count, ttl, err := rl.store.Incr(ctx, rl.prefix+key, rl.window)
+	if err != nil {
+		return failOpen(rl.onError, err)
+	}
 
-		rl.clients[ip]++
-		rl.Unlock()
-		return true, 0
+	res := Result{Limit: rl.limit, ResetAfter: ttl}
+	if count > int64(rl.limit) {
+		res.RetryAfter = ttl
+		return res
 	}
 
-	return false, rl.window
+	res.Allowed = true
+	res.Remaining = rl.limit - int(count)
+	return res
 }
 
-func (rl *FixedWindowRateLimiter) resetCount(ip string) {
-	time.Sleep(rl.window)
-	rl.Lock()
-	delete(rl.clients, ip)
-	rl.Unlock()
+// Stop releases the janitor of the limiter's own memory store. Shared stores
+// are left running.
+func (rl *FixedWindowRateLimiter) Stop() {
+	if rl.own != nil {
+		rl.own.Stop()
+	}
 }
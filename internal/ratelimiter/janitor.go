@@ -0,0 +1,37 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// janitor runs sweep on a fixed interval in a single goroutine, so limiters
+// can expire idle clients without one timer per client.
+type janitor struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+func startJanitor(interval time.Duration, sweep func(now time.Time)) *janitor {
+	j := &janitor{stop: make(chan struct{})}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				sweep(now)
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+
+	return j
+}
+
+func (j *janitor) Stop() {
+	j.once.Do(func() { close(j.stop) })
+}
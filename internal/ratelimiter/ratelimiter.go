@@ -1,40 +1,144 @@
 package ratelimiter
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 type Limiter interface {
-	Allow(ip string) (bool, time.Duration)
+	Allow(key string) Result
 }
 
+// Result is the outcome of a single Allow call.
+type Result struct {
+	Allowed bool
+	// Limit is the number of requests allowed per time frame, 0 when unlimited.
+	Limit     int
+	Remaining int
+	// ResetAfter is the time until the quota is fully available again.
+	ResetAfter time.Duration
+	// RetryAfter is the time the client should wait when the request is denied.
+	RetryAfter time.Duration
+}
+
+const (
+	AlgorithmFixedWindow          = "fixed-window"
+	AlgorithmTokenBucket          = "token-bucket"
+	AlgorithmSlidingWindowLog     = "sliding-window-log"
+	AlgorithmSlidingWindowCounter = "sliding-window-counter"
+)
+
 type Config struct {
 	RequestsPerTimeFrame int
 	TimeFrame            time.Duration
 	Enabled              bool
+	// Algorithm is one of the Algorithm* constants, fixed-window when empty.
+	Algorithm string
+	// Burst is only used by the token bucket, see NewTokenBucketLimiter.
+	Burst int
+	// Name prefixes the keys written to a shared Store so that several
+	// limiters can use the same one.
+	Name string
 }
 
+// New returns a token bucket allowing requestsPerMinute with bursts of up to
+// burst requests.
 func New(requestsPerMinute int, burst int) Limiter {
-	window := time.Minute
-	return NewFixedWindowLimiter(requestsPerMinute, window)
+	return NewTokenBucketLimiter(requestsPerMinute, time.Minute, burst)
+}
+
+func NewWithConfig(config Config) (Limiter, error) {
+	if !config.Enabled {
+		return unlimited{}, nil
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	switch config.Algorithm {
+	case "", AlgorithmFixedWindow:
+		return NewFixedWindowLimiter(config.RequestsPerTimeFrame, config.TimeFrame), nil
+	case AlgorithmTokenBucket:
+		return NewTokenBucketLimiter(config.RequestsPerTimeFrame, config.TimeFrame, config.Burst), nil
+	case AlgorithmSlidingWindowLog:
+		return NewSlidingWindowLogLimiter(config.RequestsPerTimeFrame, config.TimeFrame), nil
+	case AlgorithmSlidingWindowCounter:
+		return NewSlidingWindowCounterLimiter(config.RequestsPerTimeFrame, config.TimeFrame), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limiter algorithm %q", config.Algorithm)
+	}
+}
+
+// NewWithStore returns a limiter that keeps its counters in store, so that
+// every replica using the same store shares the limit. Only the fixed window
+// and sliding window counter algorithms support a Store. When the store fails
+// the request is allowed and onError, if not nil, is called.
+func NewWithStore(config Config, store Store, onError func(error)) (Limiter, error) {
+	if !config.Enabled {
+		return unlimited{}, nil
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	prefix := "ratelimit:" + config.Name + ":"
+	switch config.Algorithm {
+	case "", AlgorithmFixedWindow:
+		return newFixedWindowLimiter(store, prefix, config.RequestsPerTimeFrame, config.TimeFrame, onError), nil
+	case AlgorithmSlidingWindowCounter:
+		return newSlidingWindowCounterLimiter(store, prefix, config.RequestsPerTimeFrame, config.TimeFrame, onError), nil
+	default:
+		return nil, fmt.Errorf("rate limiter algorithm %q does not support a shared store", config.Algorithm)
+	}
+}
+
+// validate rejects a limit or time frame that would make the rate zero or
+// negative.
+func (c Config) validate() error {
+	if c.RequestsPerTimeFrame <= 0 {
+		return fmt.Errorf("rate limiter %q: requests per time frame must be positive, got %d", c.Name, c.RequestsPerTimeFrame)
+	}
+	if c.TimeFrame <= 0 {
+		return fmt.Errorf("rate limiter %q: time frame must be positive, got %s", c.Name, c.TimeFrame)
+	}
+	return nil
 }
 
-func NewWithConfig(config Config) Limiter {
-	return NewFixedWindowLimiter(config.RequestsPerTimeFrame, config.TimeFrame)
+func failOpen(onError func(error), err error) Result {
+	if onError != nil {
+		onError(err)
+	}
+	return Result{Allowed: true}
 }
 
-func (rl *FixedWindowRateLimiter) Limit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-		allowed, retryAfter := rl.Allow(ip)
+type unlimited struct{}
 
-		if !allowed {
-			w.Header().Set("Retry-After", retryAfter.String())
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-			return
-		}
+func (unlimited) Allow(string) Result {
+	return Result{Allowed: true}
+}
+
+// SetHeaders writes the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers for res, plus Retry-After when the request was
+// denied. Durations are written as whole seconds, rounded up.
+func SetHeaders(h http.Header, res Result) {
+	if res.Limit <= 0 {
+		return
+	}
+
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", seconds(res.ResetAfter))
+	if !res.Allowed {
+		h.Set("Retry-After", seconds(res.RetryAfter))
+	}
+}
 
-		next.ServeHTTP(w, r)
-	})
+func seconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
 }
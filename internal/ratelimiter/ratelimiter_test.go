@@ -0,0 +1,245 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type stoppableLimiter interface {
+	Limiter
+	Stop()
+}
+
+var algorithms = []struct {
+	name string
+	new  func(limit int, window time.Duration) stoppableLimiter
+}{
+	{AlgorithmFixedWindow, func(l int, w time.Duration) stoppableLimiter { return NewFixedWindowLimiter(l, w) }},
+	{AlgorithmTokenBucket, func(l int, w time.Duration) stoppableLimiter { return NewTokenBucketLimiter(l, w, l/10) }},
+	{AlgorithmSlidingWindowLog, func(l int, w time.Duration) stoppableLimiter { return NewSlidingWindowLogLimiter(l, w) }},
+	{AlgorithmSlidingWindowCounter, func(l int, w time.Duration) stoppableLimiter { return NewSlidingWindowCounterLimiter(l, w) }},
+}
+
+// fakeClock stops the clock of the package at a window boundary until the
+// test ends, returning a func that moves it to start+offset.
+func fakeClock(t *testing.T) func(offset time.Duration) {
+	t.Helper()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = time.Now })
+	return func(offset time.Duration) { now = start.Add(offset) }
+}
+
+func TestAllow(t *testing.T) {
+	type step struct {
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}
+
+	// Every algorithm allows 3 requests per minute.
+	tests := []struct {
+		algorithm string
+		steps     []step
+	}{
+		{AlgorithmFixedWindow, []step{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{10 * time.Second, true, 0, 0},
+			{10 * time.Second, false, 0, 50 * time.Second},
+			{59 * time.Second, false, 0, time.Second},
+			{time.Minute, true, 2, 0},
+		}},
+		{AlgorithmTokenBucket, []step{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, 20 * time.Second},
+			{15 * time.Second, false, 0, 5 * time.Second},
+			{20 * time.Second, true, 0, 0},
+			{20 * time.Second, false, 0, 20 * time.Second},
+			{80 * time.Second, true, 2, 0},
+		}},
+		{AlgorithmSlidingWindowLog, []step{
+			{0, true, 2, 0},
+			{10 * time.Second, true, 1, 0},
+			{20 * time.Second, true, 0, 0},
+			{30 * time.Second, false, 0, 30 * time.Second},
+			{59 * time.Second, false, 0, time.Second},
+			{time.Minute, true, 0, 0},
+			{time.Minute, false, 0, 10 * time.Second},
+			{80 * time.Second, true, 1, 0},
+			{140 * time.Second, true, 2, 0},
+		}},
+		{AlgorithmSlidingWindowCounter, []step{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			// Full until the previous window weighs 2 of its 3 requests.
+			{0, false, 0, 80 * time.Second},
+			{30 * time.Second, false, 0, 50 * time.Second},
+			{70 * time.Second, false, 0, 10 * time.Second},
+			{80 * time.Second, true, 0, 0},
+			{80 * time.Second, false, 0, 20 * time.Second},
+			{100 * time.Second, true, 0, 0},
+			{3 * time.Minute, true, 2, 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			setClock := fakeClock(t)
+			rl, err := NewWithConfig(Config{RequestsPerTimeFrame: 3, TimeFrame: time.Minute, Enabled: true, Algorithm: tt.algorithm})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rl.(stoppableLimiter).Stop()
+
+			for i, s := range tt.steps {
+				setClock(s.at)
+				res := rl.Allow("ip:10.0.0.1")
+				if res.Allowed != s.allowed || res.Limit != 3 {
+					t.Fatalf("step %d at %s: Allow = %+v, want allowed %t", i, s.at, res, s.allowed)
+				}
+				if s.allowed && res.Remaining != s.remaining {
+					t.Errorf("step %d at %s: Remaining = %d, want %d", i, s.at, res.Remaining, s.remaining)
+				}
+				if res.RetryAfter != s.retryAfter {
+					t.Errorf("step %d at %s: RetryAfter = %s, want %s", i, s.at, res.RetryAfter, s.retryAfter)
+				}
+			}
+
+			setClock(tt.steps[len(tt.steps)-1].at)
+			if !rl.Allow("ip:10.0.0.2").Allowed {
+				t.Errorf("another client was limited")
+			}
+		})
+	}
+}
+
+// Retrying while limited must not delay the moment the client gets through.
+func TestAllowDeniedRequestsDoNotCount(t *testing.T) {
+	for _, alg := range algorithms {
+		t.Run(alg.name, func(t *testing.T) {
+			setClock := fakeClock(t)
+			rl := alg.new(3, time.Minute)
+			defer rl.Stop()
+
+			for rl.Allow("client").Allowed {
+			}
+			first := rl.Allow("client").RetryAfter
+			for range 100 {
+				if res := rl.Allow("client"); res.Allowed || res.RetryAfter != first {
+					t.Fatalf("retry = %+v, want denied with RetryAfter %s", res, first)
+				}
+			}
+
+			setClock(first)
+			if !rl.Allow("client").Allowed {
+				t.Errorf("denied after waiting the first Retry-After (%s)", first)
+			}
+		})
+	}
+}
+
+// A zero or negative limit or time frame fails instead of dividing by zero.
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"no requests", Config{RequestsPerTimeFrame: 0, TimeFrame: time.Minute}},
+		{"negative requests", Config{RequestsPerTimeFrame: -1, TimeFrame: time.Minute}},
+		{"no time frame", Config{RequestsPerTimeFrame: 10}},
+		{"negative time frame", Config{RequestsPerTimeFrame: 10, TimeFrame: -time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Enabled = true
+			for _, alg := range []string{AlgorithmFixedWindow, AlgorithmTokenBucket, AlgorithmSlidingWindowLog, AlgorithmSlidingWindowCounter} {
+				tt.config.Algorithm = alg
+				if _, err := NewWithConfig(tt.config); err == nil {
+					t.Errorf("NewWithConfig(%s) accepted the config", alg)
+				}
+			}
+			tt.config.Algorithm = AlgorithmFixedWindow
+			store := NewMemoryStore(time.Minute)
+			defer store.Stop()
+			if _, err := NewWithStore(tt.config, store, nil); err == nil {
+				t.Error("NewWithStore accepted the config")
+			}
+
+			// A disabled limiter ignores the limit.
+			tt.config.Enabled = false
+			if _, err := NewWithConfig(tt.config); err != nil {
+				t.Errorf("disabled: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, Result{Limit: 3, ResetAfter: 1500 * time.Millisecond, RetryAfter: 200 * time.Millisecond})
+
+	want := map[string]string{"RateLimit-Limit": "3", "RateLimit-Remaining": "0", "RateLimit-Reset": "2", "Retry-After": "1"}
+	for name, value := range want {
+		if got := h.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	h = http.Header{}
+	SetHeaders(h, Result{Allowed: true})
+	if len(h) != 0 {
+		t.Errorf("headers written for an unlimited result: %v", h)
+	}
+}
+
+// BenchmarkAllowSingleKey measures contention on one hot client.
+func BenchmarkAllowSingleKey(b *testing.B) {
+	for _, alg := range algorithms {
+		b.Run(alg.name, func(b *testing.B) {
+			rl := alg.new(1000, time.Minute)
+			defer rl.Stop()
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					rl.Allow("client")
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkAllowManyKeys measures the cost of tracking many distinct clients,
+// as seen behind a load balancer.
+func BenchmarkAllowManyKeys(b *testing.B) {
+	const clients = 100_000
+
+	keys := make([]string, clients)
+	for i := range keys {
+		keys[i] = "ip:10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+	}
+
+	for _, alg := range algorithms {
+		b.Run(alg.name, func(b *testing.B) {
+			rl := alg.new(100, time.Minute)
+			defer rl.Stop()
+
+			var n atomic.Uint64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					rl.Allow(keys[n.Add(1)%clients])
+				}
+			})
+		})
+	}
+}
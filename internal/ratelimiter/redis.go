@@ -0,0 +1,60 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// incrScript increments KEYS[1], sets its expiry to ARGV[1] milliseconds when
+// the key is new and returns the value and the remaining TTL atomically.
+var incrScript = redis.NewScript(`
+local value = redis.call('INCR', KEYS[1])
+if value == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {value, redis.call('PTTL', KEYS[1])}
+`)
+
+// decrScript decrements KEYS[1] only if it still exists, so that a key that
+// expired in between is not recreated without a TTL.
+var decrScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+// RedisStore is a Store shared through any server speaking the Redis protocol.
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	res, err := incrScript.Run(ctx, s.client, []string{key}, ttl.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(res) != 2 {
+		return 0, 0, errors.New("unexpected reply from rate limit script")
+	}
+	return res[0], time.Duration(res[1]) * time.Millisecond, nil
+}
+
+func (s *RedisStore) Decr(ctx context.Context, key string) error {
+	return decrScript.Run(ctx, s.client, []string{key}).Err()
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (int64, error) {
+	value, err := s.client.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return value, err
+}
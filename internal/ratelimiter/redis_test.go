@@ -0,0 +1,109 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisStoreSharesLimitAcrossReplicas(t *testing.T) {
+	srv := miniredis.RunT(t)
+	cfg := Config{RequestsPerTimeFrame: 3, TimeFrame: time.Minute, Enabled: true, Name: "test"}
+
+	for _, alg := range []string{AlgorithmFixedWindow, AlgorithmSlidingWindowCounter} {
+		t.Run(alg, func(t *testing.T) {
+			srv.FlushAll()
+			cfg.Algorithm = alg
+
+			// Each replica has its own client, as separate processes would.
+			var replicas []Limiter
+			for range 2 {
+				client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+				t.Cleanup(func() { client.Close() })
+
+				rl, err := NewWithStore(cfg, NewRedisStore(client), func(err error) {
+					t.Errorf("store error: %v", err)
+				})
+				if err != nil {
+					t.Fatalf("NewWithStore: %v", err)
+				}
+				replicas = append(replicas, rl)
+			}
+
+			allowed := 0
+			for i := range 6 {
+				if replicas[i%2].Allow("ip:10.0.0.1").Allowed {
+					allowed++
+				}
+			}
+			if allowed != 3 {
+				t.Fatalf("allowed %d requests across replicas, want 3", allowed)
+			}
+
+			// The sliding window counter may have to wait into the next
+			// window, so Retry-After is bounded by two of them.
+			res := replicas[0].Allow("ip:10.0.0.1")
+			if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > 2*cfg.TimeFrame {
+				t.Errorf("unexpected result once limited: %+v", res)
+			}
+			if !replicas[1].Allow("ip:10.0.0.2").Allowed {
+				t.Errorf("another client was limited")
+			}
+		})
+	}
+}
+
+func TestRedisStoreFailsOpen(t *testing.T) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	var failures int
+	rl, err := NewWithStore(
+		Config{RequestsPerTimeFrame: 1, TimeFrame: time.Minute, Enabled: true},
+		NewRedisStore(client),
+		func(error) { failures++ },
+	)
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+
+	srv.Close()
+	if !rl.Allow("ip:10.0.0.1").Allowed {
+		t.Errorf("request denied while the store is down")
+	}
+	if failures != 1 {
+		t.Errorf("onError called %d times, want 1", failures)
+	}
+}
+
+func TestRedisStoreDecr(t *testing.T) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisStore(client)
+	ctx := context.Background()
+
+	store.Incr(ctx, "k", time.Minute)
+	store.Incr(ctx, "k", time.Minute)
+	if err := store.Decr(ctx, "k"); err != nil {
+		t.Fatalf("Decr: %v", err)
+	}
+	if v, _ := store.Get(ctx, "k"); v != 1 {
+		t.Errorf("Get = %d, want 1", v)
+	}
+	if ttl := srv.TTL("k"); ttl != time.Minute {
+		t.Errorf("TTL = %s, want the one set by Incr", ttl)
+	}
+
+	// An expired key is not recreated without a TTL.
+	if err := store.Decr(ctx, "gone"); err != nil {
+		t.Fatalf("Decr: %v", err)
+	}
+	if srv.Exists("gone") {
+		t.Errorf("Decr created a missing key")
+	}
+}
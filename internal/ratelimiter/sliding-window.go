@@ -0,0 +1,186 @@
+package ratelimiter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SlidingWindowLogRateLimiter keeps the timestamp of every accepted request
+// in the last window, which makes it exact at the cost of O(limit) memory per
+// key.
+type SlidingWindowLogRateLimiter struct {
+	sync.Mutex
+	clients map[string][]time.Time
+	limit   int
+	window  time.Duration
+	janitor *janitor
+}
+
+func NewSlidingWindowLogLimiter(limit int, window time.Duration) *SlidingWindowLogRateLimiter {
+	rl := &SlidingWindowLogRateLimiter{
+		clients: make(map[string][]time.Time),
+		limit:   limit,
+		window:  window,
+	}
+	rl.janitor = startJanitor(window, rl.sweep)
+	return rl
+}
+
+func (rl *SlidingWindowLogRateLimiter) Allow(key string) Result {
+	now := clock()
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	log := rl.trim(rl.clients[key], now)
+
+	res := Result{Limit: rl.limit}
+	if len(log) >= rl.limit {
+		rl.clients[key] = log
+		res.RetryAfter = log[0].Add(rl.window).Sub(now)
+		res.ResetAfter = log[len(log)-1].Add(rl.window).Sub(now)
+		return res
+	}
+
+	log = append(log, now)
+	rl.clients[key] = log
+	res.Allowed = true
+	res.Remaining = rl.limit - len(log)
+	res.ResetAfter = rl.window
+	return res
+}
+
+// Stop releases the janitor goroutine.
+func (rl *SlidingWindowLogRateLimiter) Stop() {
+	rl.janitor.Stop()
+}
+
+// trim drops the timestamps that left the window ending at now.
+func (rl *SlidingWindowLogRateLimiter) trim(log []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-rl.window)
+	i := 0
+	for i < len(log) && !log[i].After(cutoff) {
+		i++
+	}
+	return log[i:]
+}
+
+func (rl *SlidingWindowLogRateLimiter) sweep(now time.Time) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	for key, log := range rl.clients {
+		if log = rl.trim(log, now); len(log) == 0 {
+			delete(rl.clients, key)
+		} else {
+			rl.clients[key] = log
+		}
+	}
+}
+
+// SlidingWindowCounterRateLimiter approximates a sliding window by weighting
+// the count of the previous fixed window by how much of it still overlaps the
+// sliding one. It needs two counters per key.
+type SlidingWindowCounterRateLimiter struct {
+	store   Store
+	prefix  string
+	limit   int
+	window  time.Duration
+	onError func(error)
+	own     *MemoryStore
+}
+
+func NewSlidingWindowCounterLimiter(limit int, window time.Duration) *SlidingWindowCounterRateLimiter {
+	own := NewMemoryStore(window)
+	rl := newSlidingWindowCounterLimiter(own, "", limit, window, nil)
+	rl.own = own
+	return rl
+}
+
+func newSlidingWindowCounterLimiter(store Store, prefix string, limit int, window time.Duration, onError func(error)) *SlidingWindowCounterRateLimiter {
+	return &SlidingWindowCounterRateLimiter{
+		store:   store,
+		prefix:  prefix,
+		limit:   limit,
+		window:  window,
+		onError: onError,
+	}
+}
+
+func (rl *SlidingWindowCounterRateLimiter) Allow(key string) Result {
+	now := clock()
+	start := now.Truncate(rl.window)
+	elapsed := now.Sub(start)
+	index := start.UnixNano() / int64(rl.window)
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	// Counters live for two windows: one as current and one as previous.
+	currentKey := rl.counterKey(key, index)
+	current, _, err := rl.store.Incr(ctx, currentKey, 2*rl.window)
+	if err != nil {
+		return failOpen(rl.onError, err)
+	}
+	previous, err := rl.store.Get(ctx, rl.counterKey(key, index-1))
+	if err != nil {
+		return failOpen(rl.onError, err)
+	}
+
+	weight := 1 - float64(elapsed)/float64(rl.window)
+	estimated := float64(previous)*weight + float64(current)
+
+	res := Result{Limit: rl.limit, ResetAfter: 2*rl.window - elapsed}
+	if estimated > float64(rl.limit) {
+		// Denied requests must not count, or a client retrying in a loop
+		// would push its own Retry-After forward forever. Incrementing first
+		// keeps concurrent requests from all passing the check.
+		if err := rl.store.Decr(ctx, currentKey); err != nil && rl.onError != nil {
+			rl.onError(err)
+		}
+		res.RetryAfter = rl.retryAfter(previous, current-1, elapsed)
+		return res
+	}
+
+	res.Allowed = true
+	res.Remaining = max(0, int(float64(rl.limit)-estimated))
+	return res
+}
+
+// Stop releases the janitor of the limiter's own memory store. Shared stores
+// are left running.
+func (rl *SlidingWindowCounterRateLimiter) Stop() {
+	if rl.own != nil {
+		rl.own.Stop()
+	}
+}
+
+func (rl *SlidingWindowCounterRateLimiter) counterKey(key string, index int64) string {
+	return rl.prefix + key + ":" + strconv.FormatInt(index, 10)
+}
+
+// retryAfter returns when the weighted count drops enough to allow one more
+// request, assuming no other request arrives in between. current does not
+// include the denied request.
+func (rl *SlidingWindowCounterRateLimiter) retryAfter(previous, current int64, elapsed time.Duration) time.Duration {
+	remaining := rl.window - elapsed
+	limit := float64(rl.limit)
+
+	// Within this window: previous*(1 - (elapsed+t)/window) + current + 1 <= limit
+	if free := limit - float64(current) - 1; free >= 0 && previous > 0 {
+		t := time.Duration(float64(rl.window)*(1-free/float64(previous))) - elapsed
+		if t <= remaining {
+			return max(t, 0)
+		}
+	}
+
+	// In the next one, where current becomes the previous counter:
+	// current*(1 - t/window) + 1 <= limit
+	if current == 0 {
+		return remaining
+	}
+	t := time.Duration(float64(rl.window) * (1 - (limit-1)/float64(current)))
+	return remaining + max(t, 0)
+}
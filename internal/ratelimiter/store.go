@@ -0,0 +1,100 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps the counters of the store backed limiters (fixed window and
+// sliding window counter). Sharing a Store between replicas enforces the
+// limits cluster wide.
+type Store interface {
+	// Incr increments key by one and returns its new value and the time left
+	// before it expires. ttl is only applied when the key is created.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error)
+	// Decr takes back an increment of key. It does nothing when the key has
+	// expired in between.
+	Decr(ctx context.Context, key string) error
+	// Get returns the value of key, 0 when it does not exist.
+	Get(ctx context.Context, key string) (int64, error)
+}
+
+// storeTimeout bounds every Store call made from Allow.
+const storeTimeout = 100 * time.Millisecond
+
+// clock is the time source of the limiters and the memory store, replaced
+// in tests.
+var clock = time.Now
+
+// MemoryStore is a process local Store.
+type MemoryStore struct {
+	sync.Mutex
+	counters map[string]*counter
+	janitor  *janitor
+}
+
+type counter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns a Store whose expired keys are removed every
+// sweepEvery.
+func NewMemoryStore(sweepEvery time.Duration) *MemoryStore {
+	s := &MemoryStore{counters: make(map[string]*counter)}
+	s.janitor = startJanitor(sweepEvery, s.sweep)
+	return s
+}
+
+func (s *MemoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	now := clock()
+
+	s.Lock()
+	defer s.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expiresAt) {
+		c = &counter{expiresAt: now.Add(ttl)}
+		s.counters[key] = c
+	}
+	c.value++
+	return c.value, c.expiresAt.Sub(now), nil
+}
+
+func (s *MemoryStore) Decr(ctx context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if c, ok := s.counters[key]; ok && clock().Before(c.expiresAt) && c.value > 0 {
+		c.value--
+	}
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !clock().Before(c.expiresAt) {
+		return 0, nil
+	}
+	return c.value, nil
+}
+
+// Stop releases the janitor goroutine.
+func (s *MemoryStore) Stop() {
+	s.janitor.Stop()
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	for key, c := range s.counters {
+		if !now.Before(c.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucketRateLimiter refills rate tokens per second up to burst tokens
+// per key. Each request takes one token, so a client may send burst requests
+// at once and then rate requests per second.
+type TokenBucketRateLimiter struct {
+	sync.Mutex
+	buckets map[string]*bucket
+	rate    float64
+	burst   int
+	janitor *janitor
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketLimiter allows limit requests per window on average with
+// bursts of up to burst requests. A burst <= 0 defaults to limit.
+func NewTokenBucketLimiter(limit int, window time.Duration, burst int) *TokenBucketRateLimiter {
+	if burst <= 0 {
+		burst = limit
+	}
+
+	rl := &TokenBucketRateLimiter{
+		buckets: make(map[string]*bucket),
+		rate:    float64(limit) / window.Seconds(),
+		burst:   burst,
+	}
+	rl.janitor = startJanitor(window, rl.sweep)
+	return rl
+}
+
+func (rl *TokenBucketRateLimiter) Allow(key string) Result {
+	now := clock()
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	b, exists := rl.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(rl.burst), last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = rl.refill(b, now)
+	b.last = now
+
+	res := Result{Limit: rl.burst}
+	if b.tokens < 1 {
+		res.RetryAfter = rl.timeFor(1 - b.tokens)
+		res.ResetAfter = rl.timeFor(float64(rl.burst) - b.tokens)
+		return res
+	}
+
+	b.tokens--
+	res.Allowed = true
+	res.Remaining = int(math.Floor(b.tokens))
+	res.ResetAfter = rl.timeFor(float64(rl.burst) - b.tokens)
+	return res
+}
+
+// Stop releases the janitor goroutine.
+func (rl *TokenBucketRateLimiter) Stop() {
+	rl.janitor.Stop()
+}
+
+func (rl *TokenBucketRateLimiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(float64(rl.burst), b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+}
+
+func (rl *TokenBucketRateLimiter) timeFor(tokens float64) time.Duration {
+	return time.Duration(tokens / rl.rate * float64(time.Second))
+}
+
+// sweep drops full buckets, a new bucket would be identical.
+func (rl *TokenBucketRateLimiter) sweep(now time.Time) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	for key, b := range rl.buckets {
+		if rl.refill(b, now) >= float64(rl.burst) {
+			delete(rl.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/audit"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
+)
+
+// upsertChunkSize is the number of rows validated and written together. It
+// keeps the IN lists of the set-wise queries under Oracle's 1000 values.
+const upsertChunkSize = 500
+
+// pendingRow is a row waiting for its chunk. err is set for rows that failed
+// before reaching the store, such as unparsable CSV lines.
+type pendingRow struct {
+	res dto.TicketRowResult
+	d   dto.TicketUpsertDTO
+	err error
+}
+
+// queue adds row to the current chunk and flushes it once full.
+func (svc *TicketService) queue(ctx context.Context, b *batchResult, row pendingRow) error {
+	b.pending = append(b.pending, row)
+	if len(b.pending) < upsertChunkSize {
+		return nil
+	}
+	return svc.flush(ctx, b)
+}
+
+// flush validates the pending rows against two set-wise queries, writes the
+// ones that change something with one UpsertMany call and records the results
+// in row order. If UpsertMany fails the rows are written one by one, so the
+// failure is reported on the row that caused it.
+func (svc *TicketService) flush(ctx context.Context, b *batchResult) error {
+	rows := b.pending
+	defer func() { b.pending = rows[:0] }()
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var ids []int64
+	var serials []string
+	for i := range rows {
+		r := &rows[i]
+		if r.err == nil && r.d.TicketID <= 0 {
+			r.err = apierror.Wrapf(ErrInvalidTicketID, "%s", strconv.FormatInt(r.d.TicketID, 10))
+		}
+		if r.err != nil {
+			continue
+		}
+		if _, ok := b.simulated[r.d.TicketID]; !ok {
+			ids = append(ids, r.d.TicketID)
+		}
+		if r.d.NoSerial != nil && *r.d.NoSerial != "" {
+			serials = append(serials, *r.d.NoSerial)
+		}
+	}
+
+	current, err := svc.store.GetManyByID(ctx, ids)
+	if err != nil {
+		failPending(rows, fmt.Errorf("error leyendo tickets: %w", err))
+	}
+	holders, err := svc.store.ActiveSerialHolders(ctx, serials)
+	if err != nil {
+		failPending(rows, fmt.Errorf("error verificando no_serial: %w", err))
+	}
+
+	results := make([]dto.TicketRowResult, len(rows))
+	var writes []int
+	for i, r := range rows {
+		if r.err != nil {
+			svc.logger.Warnf("row %d skipped: %v", r.res.Row, r.err)
+			results[i] = rowFailure(ctx, r.res, r.err)
+			continue
+		}
+
+		before, ok := b.simulated[r.d.TicketID]
+		if !ok {
+			before = current[r.d.TicketID]
+		}
+		if before != nil && before.DeletedAt.Valid {
+			err := apierror.Wrapf(ErrTicketDeleted, "%s", strconv.FormatInt(r.d.TicketID, 10))
+			svc.logger.Warnf("ticket %d skipped: %v", r.d.TicketID, err)
+			results[i] = rowFailure(ctx, r.res, err)
+			continue
+		}
+
+		if r.d.NoSerial != nil && *r.d.NoSerial != "" && b.serialInUse(*r.d.NoSerial, r.d.TicketID, holders) {
+			err := apierror.Wrapf(ErrSerialInUse, "%s", *r.d.NoSerial)
+			svc.logger.Warnf("ticket %d skipped: %v", r.d.TicketID, err)
+			results[i] = rowFailure(ctx, r.res, err)
+			continue
+		}
+
+		results[i] = b.apply(r.res, before, r.d)
+		if results[i].Status != dto.RowStatusUnchanged {
+			writes = append(writes, i)
+		}
+	}
+
+	if b.atomic && hasFailure(results) {
+		// Nothing of this chunk is written, add stops at the failed row.
+		writes = nil
+	}
+
+	if !b.dryRun && len(writes) > 0 {
+		svc.write(ctx, rows, writes, results)
+	}
+
+	for _, res := range results {
+		if err := b.add(res); err != nil {
+			return err
+		}
+	}
+
+	if !b.dryRun {
+		// The store has every change now, later chunks read it from there.
+		b.resetSimulation()
+	}
+	return nil
+}
+
+// write upserts the rows at index writes, falling back to one Upsert per row
+// when the bulk statement fails.
+func (svc *TicketService) write(ctx context.Context, rows []pendingRow, writes []int, results []dto.TicketRowResult) {
+	ds := make([]dto.TicketUpsertDTO, len(writes))
+	for k, i := range writes {
+		ds[k] = rows[i].d
+	}
+
+	err := svc.store.UpsertMany(ctx, ds)
+	if err == nil {
+		return
+	}
+	svc.logger.Warnf("bulk upsert of %d rows failed, retrying row by row: %v", len(ds), err)
+
+	for _, i := range writes {
+		if err := svc.store.Upsert(ctx, rows[i].d); err != nil {
+			svc.logger.Warnf("error updating ticket %d: %v", rows[i].d.TicketID, err)
+			results[i] = rowFailure(ctx, results[i], err)
+		}
+	}
+}
+
+// apply merges d into before, records the result as the simulated state of
+// the ticket and reports the change.
+func (b *batchResult) apply(res dto.TicketRowResult, before *store.AssetReplacementTicket, d dto.TicketUpsertDTO) dto.TicketRowResult {
+	next := &store.AssetReplacementTicket{TicketID: d.TicketID}
+	if before != nil {
+		copied := *before
+		next = &copied
+	}
+	store.MergeUpsert(next, d)
+
+	for _, c := range audit.Diff(before, next) {
+		res.Changes = append(res.Changes, dto.FieldChange{Field: c.Field, Old: c.Old, New: c.New})
+	}
+	switch {
+	case before == nil:
+		res.Status = dto.RowStatusInserted
+	case len(res.Changes) > 0:
+		res.Status = dto.RowStatusUpdated
+	default:
+		res.Status = dto.RowStatusUnchanged
+		return res
+	}
+
+	b.simulate(next)
+	return res
+}
+
+func (b *batchResult) simulate(next *store.AssetReplacementTicket) {
+	if old, ok := b.simulated[next.TicketID]; ok && old.NoSerial.Valid {
+		delete(b.serials[old.NoSerial.String], next.TicketID)
+	}
+
+	b.simulated[next.TicketID] = next
+	if next.NoSerial.Valid && isActive(next) {
+		if b.serials[next.NoSerial.String] == nil {
+			b.serials[next.NoSerial.String] = make(map[int64]bool)
+		}
+		b.serials[next.NoSerial.String][next.TicketID] = true
+	}
+}
+
+// serialInUse reports whether an active ticket other than ticketID has
+// serial, as ExistsActiveOrderWithSerial would after the rows simulated so
+// far were written.
+func (b *batchResult) serialInUse(serial string, ticketID int64, holders map[string][]int64) bool {
+	for _, id := range holders[serial] {
+		if _, ok := b.simulated[id]; ok {
+			// Its current serial is in b.serials.
+			continue
+		}
+		if id != ticketID {
+			return true
+		}
+	}
+	for id := range b.serials[serial] {
+		if id != ticketID {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *batchResult) resetSimulation() {
+	b.simulated = make(map[int64]*store.AssetReplacementTicket)
+	b.serials = make(map[string]map[int64]bool)
+}
+
+func isActive(t *store.AssetReplacementTicket) bool {
+	return !t.StageProcess.Valid || !workflow.Stage(t.StageProcess.String).IsFinal()
+}
+
+// failPending fails every row of the chunk that has not failed yet.
+func failPending(rows []pendingRow, err error) {
+	for i := range rows {
+		if rows[i].err == nil {
+			rows[i].err = err
+		}
+	}
+}
+
+func hasFailure(results []dto.TicketRowResult) bool {
+	for _, res := range results {
+		if res.Status == dto.RowStatusSkipped || res.Status == dto.RowStatusFailed {
+			return true
+		}
+	}
+	return false
+}
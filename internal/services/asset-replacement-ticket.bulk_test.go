@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+)
+
+// roundTrip is the latency added to every repository call, roughly a query
+// to an Oracle server on the same network.
+const roundTrip = 200 * time.Microsecond
+
+// latencyRepository delays every call by roundTrip, so the benchmarks measure
+// round trips rather than the speed of the memory store.
+type latencyRepository struct {
+	store.TicketRepository
+}
+
+func (r latencyRepository) wait() { time.Sleep(roundTrip) }
+
+func (r latencyRepository) GetByID(ctx context.Context, id int64) (*store.AssetReplacementTicket, error) {
+	r.wait()
+	return r.TicketRepository.GetByID(ctx, id)
+}
+
+func (r latencyRepository) Upsert(ctx context.Context, d dto.TicketUpsertDTO) error {
+	r.wait()
+	return r.TicketRepository.Upsert(ctx, d)
+}
+
+func (r latencyRepository) ExistsActiveOrderWithSerial(ctx context.Context, serial string, excludeTicketID int64) (bool, error) {
+	r.wait()
+	return r.TicketRepository.ExistsActiveOrderWithSerial(ctx, serial, excludeTicketID)
+}
+
+func (r latencyRepository) GetManyByID(ctx context.Context, ids []int64) (map[int64]*store.AssetReplacementTicket, error) {
+	r.wait()
+	return r.TicketRepository.GetManyByID(ctx, ids)
+}
+
+func (r latencyRepository) ActiveSerialHolders(ctx context.Context, serials []string) (map[string][]int64, error) {
+	r.wait()
+	return r.TicketRepository.ActiveSerialHolders(ctx, serials)
+}
+
+func (r latencyRepository) UpsertMany(ctx context.Context, ds []dto.TicketUpsertDTO) error {
+	r.wait()
+	return r.TicketRepository.UpsertMany(ctx, ds)
+}
+
+// Rows of deleted tickets are reported instead of being merged into the
+// deleted row as if they were new.
+func TestUpsertBatchDeletedTicket(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	repo := storage.Tickets
+	if err := repo.Create(ctx, &store.AssetReplacementTicket{TicketID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		svc := NewTicketService(repo, storage.Tx, zap.NewNop().Sugar())
+		supplier := "ACME"
+		resp, err := svc.UpsertBatch(ctx, []dto.TicketUpsertDTO{
+			{TicketID: 1, Supplier: &supplier},
+			{TicketID: 2, Supplier: &supplier},
+		}, UpsertOptions{DryRun: dryRun})
+		if err != nil {
+			t.Fatalf("UpsertBatch(dry run %t): %v", dryRun, err)
+		}
+
+		if got := resp.Rows[0]; got.Status != dto.RowStatusSkipped || got.Code != dto.RowCodeTicketDeleted {
+			t.Errorf("dry run %t: row of deleted ticket = %+v, want skipped %s", dryRun, got, dto.RowCodeTicketDeleted)
+		}
+		if got := resp.Rows[1]; got.Status != dto.RowStatusInserted {
+			t.Errorf("dry run %t: new ticket = %+v, want inserted", dryRun, got)
+		}
+		if resp.Summary.Inserted != 1 || resp.Summary.Skipped != 1 {
+			t.Errorf("dry run %t: summary = %+v", dryRun, resp.Summary)
+		}
+	}
+
+	got, err := repo.GetManyByID(ctx, []int64{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted := got[1]; deleted.Supplier.Valid || deleted.Version != 2 {
+		t.Errorf("deleted ticket was written: %+v", deleted)
+	}
+}
+
+// One bad row rolls back every row of an atomic batch, including those of
+// chunks already written.
+func TestUpsertBatchAtomicRollsBack(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	serial := "SN-TAKEN"
+	if err := storage.Tickets.Create(ctx, &store.AssetReplacementTicket{TicketID: 1, NoSerial: sql.NullString{String: serial, Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The row taking the serial of ticket 1 comes after the first chunk.
+	rows := benchmarkRows(upsertChunkSize + 10)
+	for i := range rows {
+		rows[i].TicketID += 1
+	}
+	bad := upsertChunkSize + 5
+	rows[bad].NoSerial = &serial
+
+	var progress []dto.TicketRowResult
+	svc := NewTicketService(storage.Tickets, storage.Tx, zap.NewNop().Sugar())
+	resp, err := svc.UpsertBatch(ctx, rows, UpsertOptions{
+		Atomic:   true,
+		Progress: func(row dto.TicketRowResult, _ dto.TicketUpsertSummary) { progress = append(progress, row) },
+	})
+	if err != nil {
+		t.Fatalf("UpsertBatch: %v", err)
+	}
+
+	if !resp.RolledBack || resp.UpdatedCount != 0 {
+		t.Errorf("response = rolled back %t, updated %d, want rolled back and 0", resp.RolledBack, resp.UpdatedCount)
+	}
+	if len(resp.Rows) != bad+1 || resp.Rows[bad].Code != dto.RowCodeSerialInUse {
+		t.Fatalf("%d rows reported, the last %+v, want %d ending at the serial conflict", len(resp.Rows), resp.Rows[len(resp.Rows)-1], bad+1)
+	}
+
+	tickets, total, err := storage.Tickets.GetByFilters(ctx, store.TicketFilter{}, store.TicketPage{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || tickets[0].TicketID != 1 || tickets[0].Version != 1 {
+		t.Errorf("%d tickets left after the rollback, want only ticket 1 unchanged", total)
+	}
+
+	// Progress reported every row up to the failed one, although none of
+	// them was saved.
+	if len(progress) != len(resp.Rows) || progress[0].Status != dto.RowStatusInserted || progress[bad].Status != dto.RowStatusSkipped {
+		t.Errorf("progress got %d rows, want the %d of the response", len(progress), len(resp.Rows))
+	}
+}
+
+func TestUpsertBatchAtomicCommits(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	svc := NewTicketService(storage.Tickets, storage.Tx, zap.NewNop().Sugar())
+
+	resp, err := svc.UpsertBatch(ctx, benchmarkRows(3), UpsertOptions{Atomic: true})
+	if err != nil {
+		t.Fatalf("UpsertBatch: %v", err)
+	}
+	if resp.RolledBack || resp.Summary.Inserted != 3 {
+		t.Errorf("response = %+v, want 3 inserted", resp.Summary)
+	}
+	if _, total, _ := storage.Tickets.GetByFilters(ctx, store.TicketFilter{}, store.TicketPage{Limit: 10}); total != 3 {
+		t.Errorf("%d tickets saved, want 3", total)
+	}
+}
+
+// changesString formats changes as field:old->new, NULL for a missing value.
+func changesString(changes []dto.FieldChange) string {
+	value := func(v *string) string {
+		if v == nil {
+			return "NULL"
+		}
+		return *v
+	}
+	var parts []string
+	for _, c := range changes {
+		parts = append(parts, c.Field+":"+value(c.Old)+"->"+value(c.New))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestUpsertBatchDryRun(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	for _, tk := range []*store.AssetReplacementTicket{
+		{TicketID: 1, Supplier: sql.NullString{String: "ACME", Valid: true}},
+		{TicketID: 2, NoSerial: sql.NullString{String: "SN-2", Valid: true}},
+	} {
+		if err := storage.Tickets.Create(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	str := func(s string) *string { return &s }
+
+	rows := []dto.TicketUpsertDTO{
+		{TicketID: 1, Supplier: str("Globex"), Capex: str("CX-1")},
+		{TicketID: 2, NoSerial: str("SN-2")},
+		{TicketID: 10, NoSerial: str("SN-A")},
+		// Later rows of ticket 10 see the earlier ones.
+		{TicketID: 10, Supplier: str("Initech")},
+		{TicketID: 11, NoSerial: str("SN-A")},
+		{TicketID: 10, NoSerial: str("SN-B")},
+		{TicketID: 12, NoSerial: str("SN-A")},
+		{TicketID: 1, Supplier: str("Globex")},
+	}
+	want := []struct {
+		status, code, changes string
+	}{
+		{dto.RowStatusUpdated, "", "capex:NULL->CX-1 supplier:ACME->Globex"},
+		{dto.RowStatusUnchanged, "", ""},
+		{dto.RowStatusInserted, "", "no_serial:NULL->SN-A"},
+		{dto.RowStatusUpdated, "", "supplier:NULL->Initech"},
+		{dto.RowStatusSkipped, dto.RowCodeSerialInUse, ""},
+		{dto.RowStatusUpdated, "", "no_serial:SN-A->SN-B"},
+		// Ticket 10 gave SN-A up in the row before.
+		{dto.RowStatusInserted, "", "no_serial:NULL->SN-A"},
+		{dto.RowStatusUnchanged, "", ""},
+	}
+
+	svc := NewTicketService(storage.Tickets, storage.Tx, zap.NewNop().Sugar())
+	resp, err := svc.UpsertBatch(ctx, rows, UpsertOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("UpsertBatch: %v", err)
+	}
+	if !resp.DryRun || len(resp.Rows) != len(want) {
+		t.Fatalf("response = dry run %t with %d rows, want %d", resp.DryRun, len(resp.Rows), len(want))
+	}
+	for i, w := range want {
+		got := resp.Rows[i]
+		if got.Status != w.status || got.Code != w.code || changesString(got.Changes) != w.changes {
+			t.Errorf("row %d = %s %s [%s], want %s %s [%s]", i+1, got.Status, got.Code, changesString(got.Changes), w.status, w.code, w.changes)
+		}
+	}
+	if s := resp.Summary; s.Inserted != 2 || s.Updated != 3 || s.Unchanged != 2 || s.Skipped != 1 {
+		t.Errorf("summary = %+v", s)
+	}
+
+	// Nothing was written.
+	for _, id := range []int64{10, 11, 12} {
+		if _, err := storage.Tickets.GetByID(ctx, id); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("ticket %d: %v, want ErrNotFound", id, err)
+		}
+	}
+	tk, err := storage.Tickets.GetByID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.Version != 1 || tk.Supplier.String != "ACME" || tk.Capex.Valid {
+		t.Errorf("ticket 1 was changed: %+v", tk)
+	}
+	if entries, _ := storage.Audit.GetByTicketID(ctx, 1); len(entries) != 0 {
+		t.Errorf("%d audit entries recorded", len(entries))
+	}
+}
+
+func benchmarkRows(n int) []dto.TicketUpsertDTO {
+	rows := make([]dto.TicketUpsertDTO, n)
+	for i := range rows {
+		id := strconv.Itoa(i + 1)
+		serial, supplier := "SN-"+id, "ACME"
+		rows[i] = dto.TicketUpsertDTO{TicketID: int64(i + 1), NoSerial: &serial, Supplier: &supplier}
+	}
+	return rows
+}
+
+// upsertRowByRow is the loop UpsertBatch ran before the bulk path: one serial
+// check, one read and one MERGE per row.
+func upsertRowByRow(ctx context.Context, repo store.TicketRepository, rows []dto.TicketUpsertDTO) error {
+	for _, d := range rows {
+		if _, err := repo.ExistsActiveOrderWithSerial(ctx, *d.NoSerial, d.TicketID); err != nil {
+			return err
+		}
+		if _, err := repo.GetByID(ctx, d.TicketID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if err := repo.Upsert(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BenchmarkUpsertBatch compares the row-by-row loop with the chunked bulk
+// path. rows/s is the throughput to look at.
+func BenchmarkUpsertBatch(b *testing.B) {
+	const n = 1000
+	rows := benchmarkRows(n)
+	ctx := context.Background()
+
+	b.Run("row-by-row", func(b *testing.B) {
+		for b.Loop() {
+			repo := latencyRepository{store.NewMemoryTicketStore()}
+			if err := upsertRowByRow(ctx, repo, rows); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+
+	b.Run("bulk", func(b *testing.B) {
+		for b.Loop() {
+			storage := store.NewMemoryStorage()
+			svc := NewTicketService(latencyRepository{storage.Tickets}, storage.Tx, zap.NewNop().Sugar())
+			resp, err := svc.UpsertBatch(ctx, rows, UpsertOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if resp.Summary.Inserted != n {
+				b.Fatalf("inserted %d rows, want %d", resp.Summary.Inserted, n)
+			}
+		}
+		b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+}
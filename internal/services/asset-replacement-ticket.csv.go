@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+)
+
+// ErrInvalidCSV is returned when the file itself cannot be imported, as opposed
+// to a single bad row.
+var ErrInvalidCSV = apierror.New(apierror.CodeInvalidFile, "CSV inválido")
+
+var (
+	ErrInvalidRow      = apierror.New(apierror.CodeInvalidRow, "fila inválida")
+	ErrInvalidTicketID = apierror.New(apierror.CodeInvalidTicketID, "ticket_id inválido")
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var csvColumns = []string{"ticket_id", "no_serial", "order_number", "capex", "invoice_number", "supplier"}
+
+// RowError is a problem with a single row of an import.
+type RowError struct {
+	Line     int
+	TicketID string
+	Err      error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("línea %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// TicketCSVReader streams TicketUpsertDTO rows out of a CSV file. Every column
+// is read as text, so values like serial "00123" are kept as is.
+type TicketCSVReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	record  []string
+}
+
+// NewTicketCSVReader reads the header of src and finds the ticket columns in
+// it through m, see ColumnMapping. A nil m only accepts the field names. A
+// UTF-8 BOM is skipped and the separator is ';' when the header has no ','
+// (spreadsheets in Spanish locale).
+func NewTicketCSVReader(src io.Reader, m ColumnMapping) (*TicketCSVReader, error) {
+	br := bufio.NewReader(src)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+
+	r := csv.NewReader(br)
+	r.ReuseRecord = true
+	if first, err := br.Peek(br.Buffered()); err == nil {
+		line, _, _ := bytes.Cut(first, []byte("\n"))
+		if !bytes.ContainsRune(line, ',') && bytes.ContainsRune(line, ';') {
+			r.Comma = ';'
+		}
+	}
+
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, apierror.Wrapf(ErrInvalidCSV, "el archivo está vacío")
+		}
+		return nil, apierror.Wrapf(ErrInvalidCSV, "error leyendo encabezado: %v", err)
+	}
+
+	columns, err := headerColumns(header, m)
+	if err != nil {
+		return nil, apierror.Wrapf(ErrInvalidCSV, "%v", err)
+	}
+
+	return &TicketCSVReader{r: r, columns: columns}, nil
+}
+
+// Next returns the next row and the line it starts on. It returns io.EOF after
+// the last row and a *RowError for rows that cannot be read, in which case
+// reading can continue with the following row.
+func (cr *TicketCSVReader) Next() (dto.TicketUpsertDTO, int, error) {
+	record, err := cr.r.Read()
+	cr.record = record
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return dto.TicketUpsertDTO{}, 0, io.EOF
+		}
+
+		// FieldPos is only valid after a successful Read, the line of a
+		// parse error comes with it.
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line := parseErr.StartLine
+			if line == 0 {
+				line = parseErr.Line
+			}
+			return dto.TicketUpsertDTO{}, line, &RowError{Line: line, Err: apierror.Wrapf(ErrInvalidRow, "%v", parseErr.Err)}
+		}
+		return dto.TicketUpsertDTO{}, 0, err
+	}
+
+	line, _ := cr.r.FieldPos(0)
+	d, err := recordDTO(cr.columns, record, line)
+	return d, line, err
+}
+
+// Record returns the row last read by Next in csvColumns order, so it can be
+// written back next to its result. Missing columns are empty.
+func (cr *TicketCSVReader) Record() []string {
+	return columnRecord(cr.columns, cr.record)
+}
+
+// Fields returns the ticket fields, besides ticket_id, that the file has a
+// column for.
+func (cr *TicketCSVReader) Fields() []string {
+	return headerFields(cr.columns)
+}
+
+// recordDTO turns a row into a TicketUpsertDTO using the positions in
+// columns. It returns a *RowError when the ticket_id is not valid.
+func recordDTO(columns map[string]int, record []string, line int) (dto.TicketUpsertDTO, error) {
+	rawID := field(columns, record, "ticket_id")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id <= 0 {
+		return dto.TicketUpsertDTO{}, &RowError{Line: line, TicketID: rawID, Err: apierror.Wrapf(ErrInvalidTicketID, "%q", rawID)}
+	}
+
+	return dto.TicketUpsertDTO{
+		TicketID:      id,
+		NoSerial:      toPtr(field(columns, record, "no_serial")),
+		OrderNumber:   toPtr(field(columns, record, "order_number")),
+		Capex:         toPtr(field(columns, record, "capex")),
+		InvoiceNumber: toPtr(field(columns, record, "invoice_number")),
+		Supplier:      toPtr(field(columns, record, "supplier")),
+	}, nil
+}
+
+// columnRecord returns record in csvColumns order.
+func columnRecord(columns map[string]int, record []string) []string {
+	out := make([]string, len(csvColumns))
+	for i, col := range csvColumns {
+		out[i] = field(columns, record, col)
+	}
+	return out
+}
+
+func field(columns map[string]int, record []string, name string) string {
+	i, ok := columns[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	// record may be reused by its reader, copy the value out of it.
+	return strings.Clone(strings.TrimSpace(record[i]))
+}
+
+// CountCSVRows returns the number of rows of src after the header, counting
+// the ones that cannot be parsed as well.
+func CountCSVRows(src io.Reader, m ColumnMapping) (int, error) {
+	reader, err := NewTicketCSVReader(src, m)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for {
+		_, line, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+
+		var rowErr *RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return n, fmt.Errorf("error leyendo CSV en línea %d: %w", line, err)
+		}
+		n++
+	}
+}
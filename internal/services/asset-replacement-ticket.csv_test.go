@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// A parse error in the first field used to panic in csv.Reader.FieldPos.
+func TestTicketCSVReaderMalformedQuoting(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantLine int
+		wantRows int
+	}{
+		{"unterminated quote", "ticket_id,no_serial\n\"1,ab", 2, 1},
+		{"bare quote", "ticket_id,no_serial\n1\"2,ab\n3,cd\n", 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewTicketCSVReader(strings.NewReader(tt.src), nil)
+			if err != nil {
+				t.Fatalf("NewTicketCSVReader: %v", err)
+			}
+
+			_, line, err := reader.Next()
+			var rowErr *RowError
+			if !errors.As(err, &rowErr) || !errors.Is(err, ErrInvalidRow) {
+				t.Fatalf("Next() = %v, want a *RowError with ErrInvalidRow", err)
+			}
+			if line != tt.wantLine || rowErr.Line != tt.wantLine {
+				t.Errorf("line = %d, RowError.Line = %d, want %d", line, rowErr.Line, tt.wantLine)
+			}
+
+			n, err := CountCSVRows(strings.NewReader(tt.src), nil)
+			if err != nil || n != tt.wantRows {
+				t.Errorf("CountCSVRows = %d, %v, want %d", n, err, tt.wantRows)
+			}
+		})
+	}
+
+	reader, _ := NewTicketCSVReader(strings.NewReader("ticket_id,no_serial\n1\"2,ab\n3,cd\n"), nil)
+	reader.Next()
+	d, line, err := reader.Next()
+	if err != nil || d.TicketID != 3 || line != 3 {
+		t.Errorf("row after the bad one = %+v line %d, %v", d, line, err)
+	}
+	if _, _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next() = %v, want io.EOF", err)
+	}
+}
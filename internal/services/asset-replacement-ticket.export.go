@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+)
+
+var ErrInvalidExportFormat = apierror.New(apierror.CodeInvalidExportFormat, "formato de exportación inválido")
+
+const (
+	ExportCSV    = "csv"
+	ExportXLSX   = "xlsx"
+	ExportNDJSON = "ndjson"
+)
+
+// exportColumns are the import columns followed by read only ones. Imports
+// ignore the latter, so an export can be edited and uploaded as is.
+var exportColumns = append(append([]string(nil), csvColumns...), "category_id", "center_dist_id", "center_dist", "stage_process")
+
+// exportSheet is the sheet of an XLSX export.
+const exportSheet = "Tickets"
+
+// ticketWriter writes tickets to an export one at a time. Nothing reaches
+// the destination before the first Write or Close, so the caller can still
+// report an error that happens before any ticket is read. Discard releases
+// the writer without completing the export.
+type ticketWriter interface {
+	Write(t *store.AssetReplacementTicket) error
+	Close() error
+	Discard()
+}
+
+// Export streams the tickets matching f to w in format, ExportCSV, ExportXLSX or ExportNDJSON, and returns how many were
+// written. CSV and NDJSON are written as tickets are read. XLSX rows are
+// spooled to disk by excelize and the workbook is written to w once complete.
+func (svc *TicketService) Export(ctx context.Context, w io.Writer, format string, f store.TicketFilter) (int, error) {
+	tw, err := newTicketWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	err = svc.store.ForEach(ctx, f, func(t *store.AssetReplacementTicket) error {
+		n++
+		return tw.Write(t)
+	})
+	if err != nil {
+		tw.Discard()
+		return n, err
+	}
+	return n, tw.Close()
+}
+
+func newTicketWriter(w io.Writer, format string) (ticketWriter, error) {
+	switch format {
+	case ExportCSV:
+		return &csvTicketWriter{w: w}, nil
+	case ExportXLSX:
+		return newXLSXTicketWriter(w)
+	case ExportNDJSON:
+		return &ndjsonTicketWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, apierror.Wrapf(ErrInvalidExportFormat, "%q, se esperaba csv, xlsx o ndjson", format)
+	}
+}
+
+// exportRecord lays out t in exportColumns order, NULL being empty.
+func exportRecord(t *store.AssetReplacementTicket) []string {
+	return []string{
+		strconv.FormatInt(t.TicketID, 10),
+		t.NoSerial.String,
+		t.OrderNumber.String,
+		t.Capex.String,
+		t.InvoiceNumber.String,
+		t.Supplier.String,
+		nullInt64(t.CategoryID),
+		nullInt64(t.CenterDistID),
+		t.CenterDist.String,
+		t.StageProcess.String,
+	}
+}
+
+func nullInt64(v sql.NullInt64) string {
+	if !v.Valid {
+		return ""
+	}
+	return strconv.FormatInt(v.Int64, 10)
+}
+
+// csvTicketWriter writes the same layout TicketCSVReader reads, with a BOM
+// as WriteUpsertReport does.
+type csvTicketWriter struct {
+	w      io.Writer
+	cw     *csv.Writer
+	header bool
+}
+
+func (cw *csvTicketWriter) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+	cw.header = true
+	if _, err := cw.w.Write(utf8BOM); err != nil {
+		return err
+	}
+	cw.cw = csv.NewWriter(cw.w)
+	return cw.cw.Write(exportColumns)
+}
+
+func (cw *csvTicketWriter) Write(t *store.AssetReplacementTicket) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	return cw.cw.Write(exportRecord(t))
+}
+
+func (cw *csvTicketWriter) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.cw.Flush()
+	return cw.cw.Error()
+}
+
+func (cw *csvTicketWriter) Discard() {
+	if cw.cw != nil {
+		cw.cw.Flush()
+	}
+}
+
+// xlsxTicketWriter writes every value as text, so serials like "00123" keep
+// their leading zeros when the workbook is opened and imported back.
+type xlsxTicketWriter struct {
+	w    io.Writer
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+}
+
+func newXLSXTicketWriter(w io.Writer) (*xlsxTicketWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", exportSheet); err != nil {
+		f.Close()
+		return nil, err
+	}
+	sw, err := f.NewStreamWriter(exportSheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	xw := &xlsxTicketWriter{w: w, file: f, sw: sw}
+	if err := xw.writeRow(exportColumns); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (xw *xlsxTicketWriter) writeRow(values []string) error {
+	xw.row++
+	cells := make([]any, len(values))
+	for i, v := range values {
+		cells[i] = excelize.Cell{Value: v}
+	}
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	return xw.sw.SetRow(cell, cells)
+}
+
+func (xw *xlsxTicketWriter) Write(t *store.AssetReplacementTicket) error {
+	return xw.writeRow(exportRecord(t))
+}
+
+func (xw *xlsxTicketWriter) Close() error {
+	defer xw.file.Close()
+	if err := xw.sw.Flush(); err != nil {
+		return err
+	}
+	return xw.file.Write(xw.w)
+}
+
+// Discard drops the spooled rows without writing the workbook.
+func (xw *xlsxTicketWriter) Discard() {
+	xw.file.Close()
+}
+
+// ndjsonTicketWriter writes one JSON object per line with the export column
+// names as keys, NULL values being null.
+type ndjsonTicketWriter struct {
+	enc *json.Encoder
+}
+
+type ndjsonTicket struct {
+	TicketID      int64   `json:"ticket_id"`
+	NoSerial      *string `json:"no_serial"`
+	OrderNumber   *string `json:"order_number"`
+	Capex         *string `json:"capex"`
+	InvoiceNumber *string `json:"invoice_number"`
+	Supplier      *string `json:"supplier"`
+	CategoryID    *int64  `json:"category_id"`
+	CenterDistID  *int64  `json:"center_dist_id"`
+	CenterDist    *string `json:"center_dist"`
+	StageProcess  *string `json:"stage_process"`
+}
+
+func (nw *ndjsonTicketWriter) Write(t *store.AssetReplacementTicket) error {
+	return nw.enc.Encode(ndjsonTicket{
+		TicketID:      t.TicketID,
+		NoSerial:      nullString(t.NoSerial),
+		OrderNumber:   nullString(t.OrderNumber),
+		Capex:         nullString(t.Capex),
+		InvoiceNumber: nullString(t.InvoiceNumber),
+		Supplier:      nullString(t.Supplier),
+		CategoryID:    nullInt64Ptr(t.CategoryID),
+		CenterDistID:  nullInt64Ptr(t.CenterDistID),
+		CenterDist:    nullString(t.CenterDist),
+		StageProcess:  nullString(t.StageProcess),
+	})
+}
+
+func (nw *ndjsonTicketWriter) Close() error {
+	return nil
+}
+
+func (nw *ndjsonTicketWriter) Discard() {}
+
+func nullString(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+func nullInt64Ptr(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
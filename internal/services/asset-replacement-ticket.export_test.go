@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+)
+
+func exportService(t *testing.T) *TicketService {
+	t.Helper()
+
+	repo := store.NewMemoryTicketStore()
+	tickets := []*store.AssetReplacementTicket{
+		{TicketID: 1, NoSerial: sql.NullString{String: "00123", Valid: true}, Supplier: sql.NullString{String: "ACME, S.A.", Valid: true}},
+		{TicketID: 2, OrderNumber: sql.NullString{String: "PO-2", Valid: true}, CategoryID: sql.NullInt64{Int64: 7, Valid: true}},
+	}
+	for _, tk := range tickets {
+		tk.StageProcess = sql.NullString{String: "Request Initiated", Valid: true}
+		if err := repo.Create(context.Background(), tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewTicketService(repo, nil, zap.NewNop().Sugar())
+}
+
+// reimport reads an export back with the import readers.
+func reimport(t *testing.T, reader rowReader) map[int64]dto.TicketUpsertDTO {
+	t.Helper()
+
+	got := make(map[int64]dto.TicketUpsertDTO)
+	for {
+		d, _, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return got
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got[d.TicketID] = d
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	want := map[int64]dto.TicketUpsertDTO{
+		1: {TicketID: 1, NoSerial: ptrTo("00123"), Supplier: ptrTo("ACME, S.A.")},
+		2: {TicketID: 2, OrderNumber: ptrTo("PO-2")},
+	}
+
+	for _, format := range []string{ExportCSV, ExportXLSX} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := exportService(t).Export(context.Background(), &buf, format, store.TicketFilter{})
+			if err != nil || n != 2 {
+				t.Fatalf("Export = %d, %v", n, err)
+			}
+
+			var reader rowReader
+			if format == ExportCSV {
+				reader, err = NewTicketCSVReader(&buf, nil)
+			} else {
+				var xr *TicketXLSXReader
+				xr, err = NewTicketXLSXReader(&buf, "", nil)
+				if err == nil {
+					defer xr.Close()
+				}
+				reader = xr
+			}
+			if err != nil {
+				t.Fatalf("reading export: %v", err)
+			}
+
+			if got := reimport(t, reader); !reflect.DeepEqual(got, want) {
+				t.Errorf("reimported %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := exportService(t).Export(context.Background(), &buf, ExportNDJSON, store.TicketFilter{}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var obj map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &obj); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, obj)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	for _, obj := range lines {
+		for _, col := range exportColumns {
+			if _, ok := obj[col]; !ok {
+				t.Errorf("ticket %v has no %s", obj["ticket_id"], col)
+			}
+		}
+	}
+}
+
+func TestExportInvalidFormat(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := exportService(t).Export(context.Background(), &buf, "pdf", store.TicketFilter{})
+	if !errors.Is(err, ErrInvalidExportFormat) || buf.Len() != 0 {
+		t.Errorf("Export(pdf) = %v with %d bytes written", err, buf.Len())
+	}
+}
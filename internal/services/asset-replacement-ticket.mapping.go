@@ -0,0 +1,121 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+)
+
+// ErrInvalidMapping is returned for a ColumnMapping that cannot be used.
+var ErrInvalidMapping = apierror.New(apierror.CodeInvalidMapping, "mapeo de columnas inválido")
+
+// ColumnMapping maps each ticket field, named as in csvColumns, to the source
+// headers that may hold it, e.g. "no_serial": {"N° Serie", "Serie"}. A field
+// is always found under its own name too, so a mapping only lists aliases.
+// Headers are matched ignoring case, accents and repeated spaces.
+//
+// Only ticket_id is required in a file. Missing columns, like empty cells,
+// keep the current value of the ticket.
+type ColumnMapping map[string][]string
+
+// ValidateMapping checks that m only maps known fields and that no header
+// points to two different fields.
+func ValidateMapping(m ColumnMapping) error {
+	owner := make(map[string]string, len(csvColumns))
+	for _, col := range csvColumns {
+		owner[col] = col
+	}
+
+	fields := make([]string, 0, len(m))
+	for f := range m {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	for _, f := range fields {
+		if !isImportColumn(f) {
+			return apierror.Wrapf(ErrInvalidMapping, "campo desconocido %q, se esperaba uno de %s", f, strings.Join(csvColumns, ", "))
+		}
+		for _, alias := range m[f] {
+			key := normalizeHeader(alias)
+			if key == "" {
+				return apierror.Wrapf(ErrInvalidMapping, "encabezado vacío para %s", f)
+			}
+			if other, ok := owner[key]; ok && other != f {
+				return apierror.Wrapf(ErrInvalidMapping, "el encabezado %q está asignado a %s y %s", alias, other, f)
+			}
+			owner[key] = f
+		}
+	}
+	return nil
+}
+
+func isImportColumn(name string) bool {
+	for _, col := range csvColumns {
+		if col == name {
+			return true
+		}
+	}
+	return false
+}
+
+// headerColumns maps the ticket fields found in header, through m, to their
+// position. Headers that match no field are ignored.
+func headerColumns(header []string, m ColumnMapping) (map[string]int, error) {
+	fields := make(map[string]string)
+	for _, col := range csvColumns {
+		fields[col] = col
+		for _, alias := range m[col] {
+			fields[normalizeHeader(alias)] = col
+		}
+	}
+
+	columns := make(map[string]int, len(csvColumns))
+	for i, name := range header {
+		f, ok := fields[normalizeHeader(name)]
+		if !ok {
+			continue
+		}
+		if prev, dup := columns[f]; dup {
+			return nil, apierror.Newf(apierror.CodeInvalidFile, "las columnas %q y %q corresponden ambas a %s", header[prev], name, f)
+		}
+		columns[f] = i
+	}
+
+	if _, ok := columns["ticket_id"]; !ok {
+		return nil, apierror.Newf(apierror.CodeInvalidFile, "columna requerida: %s", "ticket_id")
+	}
+	return columns, nil
+}
+
+// headerFields returns the fields other than ticket_id found in columns, in
+// csvColumns order. They are the fields an import may change.
+func headerFields(columns map[string]int) []string {
+	var fields []string
+	for _, col := range csvColumns[1:] {
+		if _, ok := columns[col]; ok {
+			fields = append(fields, col)
+		}
+	}
+	return fields
+}
+
+// normalizeHeader lowercases s, drops its accents and collapses its spaces,
+// so "  Número  de Serie" and "numero de serie" are the same header. The
+// ordinal indicator º is read as the degree sign, both are used for "N°".
+func normalizeHeader(s string) string {
+	// A Chain keeps state, so it cannot be shared between goroutines.
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	s, _, err := transform.String(stripAccents, s)
+	if err != nil {
+		return ""
+	}
+	s = strings.ReplaceAll(s, "º", "°")
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var compras = ColumnMapping{
+	"ticket_id":    {"Ticket"},
+	"no_serial":    {"N° Serie"},
+	"order_number": {"Orden de Compra"},
+	"supplier":     {"Proveedor"},
+}
+
+func TestTicketCSVReaderMapping(t *testing.T) {
+	src := "Proveedor;nº  SERIE;Ticket;Comentario;ORDEN DE COMPRA\n" +
+		"ACME;00123;10;urgente;PO-1\n" +
+		";;11;;\n"
+
+	reader, err := NewTicketCSVReader(strings.NewReader(src), compras)
+	if err != nil {
+		t.Fatalf("NewTicketCSVReader: %v", err)
+	}
+	if got, want := reader.Fields(), []string{"no_serial", "order_number", "supplier"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields() = %v, want %v", got, want)
+	}
+
+	d, _, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if d.TicketID != 10 || *d.NoSerial != "00123" || *d.OrderNumber != "PO-1" || *d.Supplier != "ACME" {
+		t.Errorf("row 2 = %+v", d)
+	}
+	if d.Capex != nil || d.InvoiceNumber != nil {
+		t.Errorf("missing columns should be nil, got capex %v invoice %v", d.Capex, d.InvoiceNumber)
+	}
+	if got, want := reader.Record(), []string{"10", "00123", "PO-1", "", "", "ACME"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Record() = %q, want %q", got, want)
+	}
+
+	d, _, err = reader.Next()
+	if err != nil || d.TicketID != 11 || d.NoSerial != nil {
+		t.Errorf("row 3 = %+v, %v", d, err)
+	}
+	if _, _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next() = %v, want io.EOF", err)
+	}
+}
+
+func TestTicketCSVReaderHeaderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		m      ColumnMapping
+	}{
+		{"no ticket_id", "no_serial,supplier", nil},
+		{"alias without mapping", "Ticket,N° Serie", nil},
+		{"field twice", "ticket_id,Ticket", compras},
+		{"alias and name", "ticket_id,supplier,proveedor", compras},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTicketCSVReader(strings.NewReader(tt.header+"\n"), tt.m)
+			if !errors.Is(err, ErrInvalidCSV) {
+				t.Errorf("err = %v, want ErrInvalidCSV", err)
+			}
+		})
+	}
+}
+
+func TestValidateMapping(t *testing.T) {
+	if err := ValidateMapping(compras); err != nil {
+		t.Errorf("ValidateMapping(compras) = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		m    ColumnMapping
+	}{
+		{"unknown field", ColumnMapping{"stage": {"Etapa"}}},
+		{"empty alias", ColumnMapping{"supplier": {"  "}}},
+		{"alias of two fields", ColumnMapping{"supplier": {"Proveedor"}, "capex": {"PROVEEDOR"}}},
+		{"alias is another field", ColumnMapping{"order_number": {"No_Serial"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMapping(tt.m); !errors.Is(err, ErrInvalidMapping) {
+				t.Errorf("err = %v, want ErrInvalidMapping", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/i18n"
+)
+
+// reportColumns are appended to the import columns in the annotated CSV. The
+// CSV reader ignores them, so the report can be fixed and uploaded again.
+var reportColumns = []string{"status", "error_code", "error_message"}
+
+// rowFailure marks res as skipped or failed depending on err, told in the
+// language of ctx.
+func rowFailure(ctx context.Context, res dto.TicketRowResult, err error) dto.TicketRowResult {
+	res.Status = dto.RowStatusSkipped
+	switch {
+	case errors.Is(err, ErrInvalidRow):
+		res.Code = dto.RowCodeInvalidRow
+	case errors.Is(err, ErrInvalidTicketID):
+		res.Code = dto.RowCodeInvalidTicketID
+	case errors.Is(err, ErrSerialInUse):
+		res.Code = dto.RowCodeSerialInUse
+	case errors.Is(err, ErrTicketDeleted):
+		res.Code = dto.RowCodeTicketDeleted
+	default:
+		res.Status = dto.RowStatusFailed
+		res.Code = dto.RowCodeStoreError
+	}
+	res.Message = apierror.Message(i18n.FromContext(ctx), err)
+	res.Changes = nil
+	return res
+}
+
+// dtoRecord lays out d like a row of an import file.
+func dtoRecord(d dto.TicketUpsertDTO) []string {
+	value := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+	return []string{
+		strconv.FormatInt(d.TicketID, 10),
+		value(d.NoSerial),
+		value(d.OrderNumber),
+		value(d.Capex),
+		value(d.InvoiceNumber),
+		value(d.Supplier),
+	}
+}
+
+// WriteUpsertReport writes every row of resp as it was sent followed by its
+// status, error code and message. A BOM is written first so spreadsheets
+// open accented messages correctly.
+func WriteUpsertReport(w io.Writer, resp *dto.TicketUpsertResponse) error {
+	if _, err := w.Write(utf8BOM); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(append([]string{}, csvColumns...), reportColumns...)); err != nil {
+		return err
+	}
+
+	record := make([]string, 0, len(csvColumns)+len(reportColumns))
+	for _, row := range resp.Rows {
+		record = append(record[:0], row.Record...)
+		for len(record) < len(csvColumns) {
+			record = append(record, "")
+		}
+		record = append(record, row.Status, row.Code, row.Message)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
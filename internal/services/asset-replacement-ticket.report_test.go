@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+)
+
+func TestWriteUpsertReport(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	for _, tk := range []*store.AssetReplacementTicket{
+		{TicketID: 1, Supplier: sql.NullString{String: "ACME", Valid: true}},
+		{TicketID: 2, NoSerial: sql.NullString{String: "SN-2", Valid: true}},
+		{TicketID: 3},
+	} {
+		if err := storage.Tickets.Create(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.Tickets.Delete(ctx, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	input := "ticket_id,no_serial,supplier\n" +
+		"1,,Globex\n" + // updated
+		"2,,\n" + // unchanged
+		"4,SN-4,\n" + // inserted
+		"abc,SN-X,\n" + // invalid ticket_id
+		"5,SN-2,\n" + // serial of ticket 2
+		"3,,Initech\n" // deleted
+
+	svc := NewTicketService(storage.Tickets, storage.Tx, zap.NewNop().Sugar())
+	resp, err := svc.UpsertBatchCSV(ctx, strings.NewReader(input), UpsertOptions{})
+	if err != nil {
+		t.Fatalf("UpsertBatchCSV: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteUpsertReport(&buf, resp); err != nil {
+		t.Fatalf("WriteUpsertReport: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), utf8BOM) {
+		t.Fatal("the report does not start with a BOM")
+	}
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes()[len(utf8BOM):])).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the report: %v", err)
+	}
+
+	wantHeader := []string{"ticket_id", "no_serial", "order_number", "capex", "invoice_number", "supplier", "status", "error_code", "error_message"}
+	if !reflect.DeepEqual(records[0], wantHeader) {
+		t.Fatalf("header = %v, want %v", records[0], wantHeader)
+	}
+
+	want := []struct {
+		ticketID, serial, supplier string
+		status, code               string
+	}{
+		{"1", "", "Globex", dto.RowStatusUpdated, ""},
+		{"2", "", "", dto.RowStatusUnchanged, ""},
+		{"4", "SN-4", "", dto.RowStatusInserted, ""},
+		{"abc", "SN-X", "", dto.RowStatusSkipped, dto.RowCodeInvalidTicketID},
+		{"5", "SN-2", "", dto.RowStatusSkipped, dto.RowCodeSerialInUse},
+		{"3", "", "Initech", dto.RowStatusSkipped, dto.RowCodeTicketDeleted},
+	}
+	rows := records[1:]
+	if len(rows) != len(want) {
+		t.Fatalf("%d report rows, want one per input row: %v", len(rows), rows)
+	}
+	for i, w := range want {
+		row := rows[i]
+		if len(row) != len(wantHeader) {
+			t.Errorf("row %d has %d columns: %v", i+1, len(row), row)
+			continue
+		}
+		if row[0] != w.ticketID || row[1] != w.serial || row[5] != w.supplier {
+			t.Errorf("row %d data = %v, want the input row", i+1, row[:6])
+		}
+		if row[6] != w.status || row[7] != w.code {
+			t.Errorf("row %d = %s/%s, want %s/%s", i+1, row[6], row[7], w.status, w.code)
+		}
+		if (w.code != "") != (row[8] != "") {
+			t.Errorf("row %d error_message = %q with error_code %q", i+1, row[8], w.code)
+		}
+	}
+
+	// The report is imported again as is, the extra columns are ignored.
+	reader, err := NewTicketCSVReader(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("NewTicketCSVReader of the report: %v", err)
+	}
+	if d, _, err := reader.Next(); err != nil || d.TicketID != 1 || d.Supplier == nil || *d.Supplier != "Globex" {
+		t.Errorf("first row read back = %+v, %v", d, err)
+	}
+}
@@ -2,116 +2,306 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
+	"io"
 	"strings"
 
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/i18n"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
-	"github.com/go-gota/gota/dataframe"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
 	"go.uber.org/zap"
+	"golang.org/x/text/language"
 )
 
+var ErrSerialInUse = apierror.New(apierror.CodeSerialInUse, "no_serial ya asignado a una orden activa")
+
+// ErrTicketDeleted rejects batch rows of deleted tickets. Upsert would update
+// them without restoring them, so the row would be lost from sight.
+var ErrTicketDeleted = apierror.New(apierror.CodeNotFound, "el ticket fue eliminado")
+
 type TicketService struct {
-	store  store.TicketRepository
-	logger *zap.SugaredLogger
+	store    store.TicketRepository
+	tx       store.Transactor
+	workflow *workflow.Machine
+	logger   *zap.SugaredLogger
 }
 
-func NewTicketService(repo store.TicketRepository, logger *zap.SugaredLogger) *TicketService {
-	return &TicketService{store: repo, logger: logger}
+func NewTicketService(repo store.TicketRepository, tx store.Transactor, logger *zap.SugaredLogger) *TicketService {
+	return &TicketService{store: repo, tx: tx, workflow: workflow.Default, logger: logger}
 }
 
-func (svc *TicketService) UpsertBatch(ctx context.Context, dtos []dto.TicketUpsertDTO) (dto.TicketUpsertResponse, error) {
-	var updatedCount int
-	var skipped []int64
+// TransitionStage moves a ticket to the stage named to, enforcing the
+// transitions and guards declared in the workflow. It fails with
+// store.ErrVersionMismatch unless the ticket is still at version.
+func (svc *TicketService) TransitionStage(ctx context.Context, ticketID, version int64, to string) (*store.AssetReplacementTicket, error) {
+	target, err := workflow.Parse(to)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := svc.store.GetByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Version != version {
+		return nil, store.ErrVersionMismatch
+	}
 
-	for _, d := range dtos {
-		err := svc.validateUpsert(ctx, d)
+	current := workflow.Initial
+	if t.StageProcess.Valid && t.StageProcess.String != "" {
+		current, err = workflow.Parse(t.StageProcess.String)
 		if err != nil {
-			svc.logger.Warnf("ticket %d skipped: %v", d.TicketID, err)
-			skipped = append(skipped, d.TicketID)
-			continue
+			return nil, fmt.Errorf("ticket %d tiene una etapa inválida: %v", ticketID, err)
 		}
+	}
 
-		if err := svc.store.Upsert(ctx, d); err != nil {
-			svc.logger.Warnf("error updating ticket %d: %v", d.TicketID, err)
-			skipped = append(skipped, d.TicketID)
-			continue
-		}
-		updatedCount++
+	if err := svc.workflow.Check(workflowTicket(t), current, target); err != nil {
+		return nil, err
 	}
 
-	resp := dto.TicketUpsertResponse{
-		UpdatedCount: updatedCount,
-		SkippedIDs:   skipped,
-		Message:      fmt.Sprintf("%d tickets actualizados de forma exitosa: ", updatedCount),
+	t.StageProcess = sql.NullString{String: target.String(), Valid: true}
+	if err := svc.store.Update(ctx, t); err != nil {
+		return nil, err
 	}
-	return resp, nil
+
+	svc.logger.Infow("ticket stage changed", "ticket_id", ticketID, "from", current, "to", target)
+	return t, nil
 }
 
-func (svc *TicketService) validateUpsert(ctx context.Context, d dto.TicketUpsertDTO) error {
-	if d.NoSerial != nil && *d.NoSerial != "" {
-		exists, err := svc.store.ExistsActiveOrderWithSerial(ctx, *d.NoSerial, d.TicketID)
-		if err != nil {
-			return fmt.Errorf("error verificando no_serial: %w", err)
-		}
-		if exists {
-			return fmt.Errorf("no_serial %s ya asignado a una orden activa", *d.NoSerial)
-		}
+func workflowTicket(t *store.AssetReplacementTicket) workflow.Ticket {
+	return workflow.Ticket{
+		NoSerial:      t.NoSerial.String,
+		OrderNumber:   t.OrderNumber.String,
+		Capex:         t.Capex.String,
+		InvoiceNumber: t.InvoiceNumber.String,
 	}
-	return nil
 }
 
-func (svc *TicketService) UpsertBatchCSV(ctx context.Context, csvPath string) (*dto.TicketUpsertResponse, error) {
-	file, err := os.Open(csvPath)
+// UpsertOptions changes how a batch is applied.
+type UpsertOptions struct {
+	// DryRun validates the batch and reports what would change for every
+	// ticket without writing anything.
+	DryRun bool
+
+	// Atomic applies the whole batch in one transaction and rolls it back on
+	// the first row that is skipped or fails.
+	Atomic bool
+
+	// Columns finds the ticket columns of a CSV or XLSX file, see
+	// ColumnMapping. When nil only the field names are accepted.
+	Columns ColumnMapping
+
+	// Progress, when set, is called after every row with its result and the
+	// totals so far. Rows of an atomic batch are reported before it commits:
+	// when it is rolled back, the rows reported up to the failed one were
+	// not saved after all, as the response's RolledBack tells.
+	Progress func(row dto.TicketRowResult, summary dto.TicketUpsertSummary)
+}
+
+// UpsertBatch applies dtos in chunks: each chunk is validated with set-wise
+// queries and written with a single UpsertMany call.
+func (svc *TicketService) UpsertBatch(ctx context.Context, dtos []dto.TicketUpsertDTO, opts UpsertOptions) (dto.TicketUpsertResponse, error) {
+	b := newBatchResult(ctx, opts)
+	err := svc.runBatch(ctx, b, func(ctx context.Context) error {
+		for i, d := range dtos {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			row := pendingRow{res: dto.TicketRowResult{Row: i + 1, TicketID: d.TicketID, Record: dtoRecord(d)}, d: d}
+			if err := svc.queue(ctx, b, row); err != nil {
+				return err
+			}
+		}
+		return svc.flush(ctx, b)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error abrindo archivo CSV: %w", err)
+		return dto.TicketUpsertResponse{}, err
 	}
-	defer file.Close()
+	return b.response(), nil
+}
 
-	df := dataframe.ReadCSV(file)
-	requiredCols := []string{"ticket_id", "no_serial", "order_number", "capex", "invoice_number", "supplier"}
+// errBatchAborted stops an atomic batch at its first failed row.
+var errBatchAborted = errors.New("lote abortado")
 
-	dfCols := df.Names()
-	colMap := make(map[string]bool)
-	for _, col := range dfCols {
-		colMap[col] = true
+// runBatch calls fn, inside a transaction for atomic batches. A dry run writes
+// nothing, so it only needs to stop at the first failure.
+func (svc *TicketService) runBatch(ctx context.Context, b *batchResult, fn func(ctx context.Context) error) error {
+	var err error
+	if b.atomic && !b.dryRun {
+		err = svc.tx.WithinTx(ctx, fn)
+	} else {
+		err = fn(ctx)
 	}
 
-	for _, col := range requiredCols {
-		if !colMap[col] {
-			return nil, fmt.Errorf("columna requerida o inválida: %s", col)
-		}
+	if errors.Is(err, errBatchAborted) {
+		svc.logger.Warnf("atomic batch rolled back at row %d: %s", b.abortedAt.Row, b.abortedAt.Message)
+		return nil
 	}
+	return err
+}
 
-	var dtos []dto.TicketUpsertDTO
-	for i := 0; i < df.Nrow(); i++ {
-		row := df.Subset(i)
-		id, err := strconv.ParseInt(row.Col("ticket_id").Elem(0).String(), 10, 64)
-		if err != nil || id == 0 {
-			continue
-		}
-
-		dtoRow := dto.TicketUpsertDTO{
-			TicketID:      id,
-			NoSerial:      toPtr(row.Col("no_serial").Elem(0).String()),
-			OrderNumber:   toPtr(row.Col("order_number").Elem(0).String()),
-			Capex:         toPtr(row.Col("capex").Elem(0).String()),
-			InvoiceNumber: toPtr(row.Col("invoice_number").Elem(0).String()),
-			Supplier:      toPtr(row.Col("supplier").Elem(0).String()),
-		}
+// UpsertBatchCSV streams src, only one chunk of rows is held in memory at a
+// time besides the results. Rows that cannot be parsed are reported with their
+// line number and the import goes on with the next row.
+func (svc *TicketService) UpsertBatchCSV(ctx context.Context, src io.Reader, opts UpsertOptions) (*dto.TicketUpsertResponse, error) {
+	reader, err := NewTicketCSVReader(src, opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	return svc.upsertRows(ctx, reader, opts)
+}
 
-		dtos = append(dtos, dtoRow)
+// UpsertBatchXLSX imports one sheet of a workbook, see NewTicketXLSXReader,
+// through the same pipeline as UpsertBatchCSV.
+func (svc *TicketService) UpsertBatchXLSX(ctx context.Context, src io.Reader, sheet string, opts UpsertOptions) (*dto.TicketUpsertResponse, error) {
+	reader, err := NewTicketXLSXReader(src, sheet, opts.Columns)
+	if err != nil {
+		return nil, err
 	}
+	defer reader.Close()
+
+	return svc.upsertRows(ctx, reader, opts)
+}
+
+// rowReader is a source of import rows, TicketCSVReader or TicketXLSXReader.
+type rowReader interface {
+	Next() (dto.TicketUpsertDTO, int, error)
+	Record() []string
+}
+
+func (svc *TicketService) upsertRows(ctx context.Context, reader rowReader, opts UpsertOptions) (*dto.TicketUpsertResponse, error) {
+	b := newBatchResult(ctx, opts)
+	err := svc.runBatch(ctx, b, func(ctx context.Context) error {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 
-	resp, err := svc.UpsertBatch(ctx, dtos)
+			d, line, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				return svc.flush(ctx, b)
+			}
+
+			var rowErr *RowError
+			if errors.As(err, &rowErr) {
+				svc.logger.Warnf("import %v", rowErr)
+				row := pendingRow{res: dto.TicketRowResult{Row: rowErr.Line, Record: reader.Record()}, err: rowErr.Err}
+				if err := svc.queue(ctx, b, row); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("error leyendo archivo en fila %d: %w", line, err)
+			}
+
+			row := pendingRow{res: dto.TicketRowResult{Row: line, TicketID: d.TicketID, Record: reader.Record()}, d: d}
+			if err := svc.queue(ctx, b, row); err != nil {
+				return err
+			}
+		}
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	resp := b.response()
 	return &resp, nil
 }
 
+type batchResult struct {
+	dryRun   bool
+	atomic   bool
+	progress func(dto.TicketRowResult, dto.TicketUpsertSummary)
+	// lang is the language of Message and of the row messages.
+	lang    language.Tag
+	summary dto.TicketUpsertSummary
+	skipped []int64
+	rows    []dto.TicketRowResult
+
+	// abortedAt is the row that stopped an atomic batch.
+	abortedAt *dto.TicketRowResult
+
+	// pending are the rows of the chunk being built.
+	pending []pendingRow
+
+	// simulated holds the tickets as the rows not yet written leave them, so
+	// a ticket that appears twice is checked against its first change. In a
+	// dry run nothing is written and it covers the whole batch. serials
+	// indexes the active simulated tickets by serial.
+	simulated map[int64]*store.AssetReplacementTicket
+	serials   map[string]map[int64]bool
+}
+
+func newBatchResult(ctx context.Context, opts UpsertOptions) *batchResult {
+	b := &batchResult{dryRun: opts.DryRun, atomic: opts.Atomic, progress: opts.Progress, lang: i18n.FromContext(ctx)}
+	b.resetSimulation()
+	return b
+}
+
+// add records res. It returns errBatchAborted when res fails an atomic batch.
+func (b *batchResult) add(res dto.TicketRowResult) error {
+	switch res.Status {
+	case dto.RowStatusInserted:
+		b.summary.Inserted++
+	case dto.RowStatusUpdated:
+		b.summary.Updated++
+	case dto.RowStatusUnchanged:
+		b.summary.Unchanged++
+	case dto.RowStatusSkipped:
+		b.summary.Skipped++
+	case dto.RowStatusFailed:
+		b.summary.Failed++
+	}
+	if (res.Status == dto.RowStatusSkipped || res.Status == dto.RowStatusFailed) && res.TicketID != 0 {
+		b.skipped = append(b.skipped, res.TicketID)
+	}
+	b.rows = append(b.rows, res)
+	if b.progress != nil {
+		b.progress(res, b.summary)
+	}
+
+	if b.atomic && (res.Status == dto.RowStatusSkipped || res.Status == dto.RowStatusFailed) {
+		b.abortedAt = &res
+		return errBatchAborted
+	}
+	return nil
+}
+
+func (b *batchResult) response() dto.TicketUpsertResponse {
+	p := i18n.Printer(b.lang)
+	updated := b.summary.Inserted + b.summary.Updated + b.summary.Unchanged
+	resp := dto.TicketUpsertResponse{
+		UpdatedCount: updated,
+		SkippedIDs:   b.skipped,
+		Summary:      b.summary,
+		Rows:         b.rows,
+		Message:      p.Sprintf("%d tickets actualizados de forma exitosa", updated),
+	}
+	if resp.Rows == nil {
+		resp.Rows = []dto.TicketRowResult{}
+	}
+	switch {
+	case b.abortedAt != nil && b.dryRun:
+		resp.DryRun = true
+		resp.RolledBack = true
+		resp.UpdatedCount = 0
+		resp.Message = p.Sprintf("simulación: la fila %d falla (%s), no se guardaría ningún cambio", b.abortedAt.Row, b.abortedAt.Message)
+	case b.abortedAt != nil:
+		resp.RolledBack = true
+		resp.UpdatedCount = 0
+		resp.Message = p.Sprintf("fila %d: %s; transacción revertida, no se guardó ningún cambio", b.abortedAt.Row, b.abortedAt.Message)
+	case b.dryRun:
+		resp.DryRun = true
+		resp.Message = p.Sprintf("simulación: %d tickets serían actualizados, no se guardó ningún cambio", updated)
+	}
+	return resp
+}
+
 func toPtr(s string) *string {
 	if strings.TrimSpace(s) == "" {
 		return nil
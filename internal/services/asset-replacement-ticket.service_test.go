@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
+)
+
+func TestTransitionStage(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	svc := NewTicketService(storage.Tickets, storage.Tx, zap.NewNop().Sugar())
+
+	// Ticket 1 has a NULL STAGE_PROCESS, which is the initial stage.
+	for _, tk := range []*store.AssetReplacementTicket{
+		{TicketID: 1},
+		{TicketID: 2, StageProcess: sql.NullString{String: "Archived", Valid: true}},
+	} {
+		if err := storage.Tickets.Create(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := svc.TransitionStage(ctx, 1, 1, "completed"); !errors.Is(err, workflow.ErrIllegalTransition) {
+		t.Errorf("NULL stage to COMPLETED = %v, want ErrIllegalTransition", err)
+	}
+	got, err := svc.TransitionStage(ctx, 1, 1, "procurement phase")
+	if err != nil {
+		t.Fatalf("NULL stage to Procurement Phase: %v", err)
+	}
+	if got.StageProcess.String != string(workflow.StageProcurement) || got.Version != 2 {
+		t.Errorf("ticket after the transition = %+v", got)
+	}
+
+	// The ticket is now at version 2.
+	if _, err := svc.TransitionStage(ctx, 1, 1, "Request Initiated"); !errors.Is(err, store.ErrVersionMismatch) {
+		t.Errorf("stale version = %v, want ErrVersionMismatch", err)
+	}
+	if _, err := svc.TransitionStage(ctx, 1, 2, "Draft"); !errors.Is(err, workflow.ErrUnknownStage) {
+		t.Errorf("unknown target = %v, want ErrUnknownStage", err)
+	}
+	if _, err := svc.TransitionStage(ctx, 2, 1, "Procurement Phase"); err == nil {
+		t.Error("ticket with an invalid stage was moved")
+	}
+	if _, err := svc.TransitionStage(ctx, 3, 1, "Procurement Phase"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("missing ticket = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+)
+
+// ErrInvalidXLSX is ErrInvalidCSV for spreadsheets.
+var ErrInvalidXLSX = apierror.New(apierror.CodeInvalidFile, "XLSX inválido")
+
+// MaxUploadBytes is the largest file the import endpoints accept.
+const MaxUploadBytes = 32 << 20 // 32 MB
+
+// A workbook is a zip archive, so an upload within MaxUploadBytes can still
+// inflate to far more. XLSX parts compress about 10:1: larger workbooks are
+// rejected, and parts over xlsxUnzipXMLLimit are unzipped to temporary
+// files instead of memory.
+const (
+	xlsxUnzipLimit    = 10 * MaxUploadBytes
+	xlsxUnzipXMLLimit = MaxUploadBytes
+)
+
+// TicketXLSXReader streams TicketUpsertDTO rows out of one sheet of a
+// workbook. Cells are read with their display format, so a serial typed as
+// "00123" or a cell formatted as "00000" keeps its leading zeros.
+type TicketXLSXReader struct {
+	file    *excelize.File
+	rows    *excelize.Rows
+	columns map[string]int
+	line    int
+	record  []string
+}
+
+// NewTicketXLSXReader opens src and reads the header of sheet, which is a
+// sheet name or its 1-based position. An empty sheet means the first one.
+// Columns are found through m as in NewTicketCSVReader.
+// The workbook is held in memory, as XLSX files are zip archives, and may not
+// inflate past xlsxUnzipLimit. Close must be called once done.
+func NewTicketXLSXReader(src io.Reader, sheet string, m ColumnMapping) (*TicketXLSXReader, error) {
+	f, err := excelize.OpenReader(src, excelize.Options{
+		UnzipSizeLimit:    xlsxUnzipLimit,
+		UnzipXMLSizeLimit: xlsxUnzipXMLLimit,
+	})
+	if err != nil {
+		return nil, apierror.Wrapf(ErrInvalidXLSX, "%v", err)
+	}
+
+	name, err := sheetName(f, sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	rows, err := f.Rows(name)
+	if err != nil {
+		f.Close()
+		return nil, apierror.Wrapf(ErrInvalidXLSX, "error leyendo hoja %q: %v", name, err)
+	}
+
+	xr := &TicketXLSXReader{file: f, rows: rows}
+	header, err := xr.read()
+	if err != nil {
+		xr.Close()
+		if errors.Is(err, io.EOF) {
+			return nil, apierror.Wrapf(ErrInvalidXLSX, "la hoja %q está vacía", name)
+		}
+		return nil, apierror.Wrapf(ErrInvalidXLSX, "error leyendo encabezado: %v", err)
+	}
+
+	xr.columns, err = headerColumns(header, m)
+	if err != nil {
+		xr.Close()
+		return nil, apierror.Wrapf(ErrInvalidXLSX, "%v", err)
+	}
+	return xr, nil
+}
+
+func sheetName(f *excelize.File, sheet string) (string, error) {
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return "", apierror.Wrapf(ErrInvalidXLSX, "el libro no tiene hojas")
+	}
+	if sheet == "" {
+		return sheets[0], nil
+	}
+
+	for _, name := range sheets {
+		if strings.EqualFold(name, sheet) {
+			return name, nil
+		}
+	}
+	if i, err := strconv.Atoi(sheet); err == nil && i >= 1 && i <= len(sheets) {
+		return sheets[i-1], nil
+	}
+	return "", apierror.Wrapf(ErrInvalidXLSX, "hoja no encontrada: %q", sheet)
+}
+
+// Next returns the next non-empty row and its row number in the sheet, with
+// the same errors as TicketCSVReader.Next.
+func (xr *TicketXLSXReader) Next() (dto.TicketUpsertDTO, int, error) {
+	for {
+		record, err := xr.read()
+		if err != nil {
+			return dto.TicketUpsertDTO{}, 0, err
+		}
+		if isBlank(record) {
+			continue
+		}
+
+		d, err := recordDTO(xr.columns, record, xr.line)
+		return d, xr.line, err
+	}
+}
+
+// Record returns the row last read by Next in csvColumns order.
+func (xr *TicketXLSXReader) Record() []string {
+	return columnRecord(xr.columns, xr.record)
+}
+
+// Fields is TicketCSVReader.Fields for the sheet.
+func (xr *TicketXLSXReader) Fields() []string {
+	return headerFields(xr.columns)
+}
+
+func (xr *TicketXLSXReader) Close() error {
+	xr.rows.Close()
+	return xr.file.Close()
+}
+
+func (xr *TicketXLSXReader) read() ([]string, error) {
+	if !xr.rows.Next() {
+		if err := xr.rows.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	xr.line++
+
+	record, err := xr.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	xr.record = record
+	return record, nil
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// CountXLSXRows is CountCSVRows for a sheet of a workbook.
+func CountXLSXRows(src io.Reader, sheet string, m ColumnMapping) (int, error) {
+	reader, err := NewTicketXLSXReader(src, sheet, m)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	var n int
+	for {
+		_, _, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+
+		var rowErr *RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return n, fmt.Errorf("error leyendo XLSX: %w", err)
+		}
+		n++
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// workbook builds an XLSX file with a "Resumen" sheet first and the ticket
+// rows in a "Tickets" sheet.
+func workbook(t *testing.T, rows [][]any) *bytes.Buffer {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName("Sheet1", "Resumen"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.NewSheet("Tickets"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Tickets", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A numeric serial shown with leading zeros through its number format.
+	style, err := f.NewStyle(&excelize.Style{CustomNumFmt: ptrTo("00000")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetCellStyle("Tickets", "B3", "B3", style); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func ptrTo[T any](v T) *T { return &v }
+
+func TestTicketXLSXReader(t *testing.T) {
+	header := []any{"Ticket_ID", "no_serial", "order_number", "capex", "invoice_number", "supplier"}
+	rows := [][]any{
+		header,
+		{"10", "00123", "PO-1", "", "", "ACME"},
+		{11, 42, "", "", "", ""},
+		{},
+		{"abc", "", "", "", "", ""},
+	}
+
+	for _, sheet := range []string{"Tickets", "tickets", "2"} {
+		t.Run(sheet, func(t *testing.T) {
+			reader, err := NewTicketXLSXReader(workbook(t, rows), sheet, nil)
+			if err != nil {
+				t.Fatalf("NewTicketXLSXReader: %v", err)
+			}
+			defer reader.Close()
+
+			d, line, err := reader.Next()
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if d.TicketID != 10 || line != 2 || *d.NoSerial != "00123" || *d.Supplier != "ACME" || d.Capex != nil {
+				t.Errorf("row 2 = %+v at line %d", d, line)
+			}
+
+			d, line, err = reader.Next()
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if d.TicketID != 11 || line != 3 || *d.NoSerial != "00042" {
+				t.Errorf("row 3 = %+v at line %d, want serial 00042", d, line)
+			}
+
+			// The blank row 4 is skipped.
+			_, line, err = reader.Next()
+			var rowErr *RowError
+			if !errors.As(err, &rowErr) || !errors.Is(err, ErrInvalidTicketID) || line != 5 {
+				t.Errorf("row 5: line %d, err %v, want invalid ticket_id", line, err)
+			}
+
+			if _, _, err := reader.Next(); !errors.Is(err, io.EOF) {
+				t.Errorf("Next after last row: %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestTicketXLSXReaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   io.Reader
+		sheet string
+	}{
+		{"not a workbook", bytes.NewBufferString("ticket_id,no_serial\n"), ""},
+		{"unknown sheet", workbook(t, [][]any{{"ticket_id"}}), "Compras"},
+		{"sheet out of range", workbook(t, [][]any{{"ticket_id"}}), "3"},
+		{"missing ticket_id", workbook(t, [][]any{{"no_serial", "supplier"}}), "Tickets"},
+		{"empty sheet", workbook(t, nil), "Tickets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTicketXLSXReader(tt.src, tt.sheet, nil)
+			if !errors.Is(err, ErrInvalidXLSX) {
+				t.Errorf("err = %v, want ErrInvalidXLSX", err)
+			}
+		})
+	}
+}
+
+// A workbook within the upload limit that inflates past xlsxUnzipLimit is
+// rejected before it is unzipped.
+func TestTicketXLSXReaderUnzipLimit(t *testing.T) {
+	src := workbook(t, [][]any{{"ticket_id"}, {"10"}})
+	zr, err := zip.NewReader(bytes.NewReader(src.Bytes()), int64(src.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bomb bytes.Buffer
+	zw := zip.NewWriter(&bomb)
+	for _, f := range zr.File {
+		if err := zw.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := zw.Create("xl/media/padding.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeros := make([]byte, 1<<20)
+	for n := 0; n <= xlsxUnzipLimit; n += len(zeros) {
+		w.Write(zeros)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if bomb.Len() > MaxUploadBytes {
+		t.Fatalf("the workbook is %d bytes, over the upload limit", bomb.Len())
+	}
+
+	_, err = NewTicketXLSXReader(&bomb, "", nil)
+	if !errors.Is(err, ErrInvalidXLSX) || !strings.Contains(err.Error(), "unzip size exceeds") {
+		t.Errorf("err = %v, want ErrInvalidXLSX for the unzip size limit", err)
+	}
+}
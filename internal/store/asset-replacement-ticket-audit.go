@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditEntry is one recorded mutation of a ticket. Entries are stored in
+// ASSETS_REPLACEMENT_TICKET_AUDIT:
+//
+//	ID NUMBER GENERATED ALWAYS AS IDENTITY, TICKET_ID NUMBER, ACTION VARCHAR2(20),
+//	SOURCE VARCHAR2(20), ACTOR VARCHAR2(200), REQUEST_ID VARCHAR2(200),
+//	CHANGES CLOB CHECK (CHANGES IS JSON), CREATED_AT DATE DEFAULT SYSDATE
+type AuditEntry struct {
+	ID        int64         `json:"id"`
+	TicketID  int64         `json:"ticket_id"`
+	Action    string        `json:"action"`
+	Source    string        `json:"source"`
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id,omitempty"`
+	Changes   []FieldChange `json:"changes"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// FieldChange holds the value of a column before and after a mutation, nil
+// meaning NULL.
+type FieldChange struct {
+	Field string  `json:"field"`
+	Old   *string `json:"old"`
+	New   *string `json:"new"`
+}
+
+type AuditStore struct {
+	db *sql.DB
+}
+
+func (s *AuditStore) Record(ctx context.Context, e *AuditEntry) error {
+	query := `
+		INSERT INTO ASSETS_REPLACEMENT_TICKET_AUDIT
+			(TICKET_ID, ACTION, SOURCE, ACTOR, REQUEST_ID, CHANGES, CREATED_AT)
+		VALUES (:1, :2, :3, :4, :5, :6, :7)
+		RETURNING ID INTO :8
+	`
+
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return fmt.Errorf("error encoding audit changes: %w", err)
+	}
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err = conn(ctx, s.db).ExecContext(
+		ctx,
+		query,
+		e.TicketID,
+		e.Action,
+		e.Source,
+		e.Actor,
+		e.RequestID,
+		string(changes),
+		e.CreatedAt,
+		sql.Out{Dest: &e.ID},
+	)
+	if err != nil {
+		return fmt.Errorf("error recording audit entry: %w", err)
+	}
+	return nil
+}
+
+func (s *AuditStore) GetByTicketID(ctx context.Context, ticketID int64) ([]AuditEntry, error) {
+	query := `
+		SELECT ID, TICKET_ID, ACTION, SOURCE, ACTOR, REQUEST_ID, CHANGES, CREATED_AT
+		FROM ASSETS_REPLACEMENT_TICKET_AUDIT
+		WHERE TICKET_ID = :1
+		ORDER BY CREATED_AT, ID
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var (
+			e         AuditEntry
+			requestID sql.NullString
+			changes   string
+		)
+		if err := rows.Scan(
+			&e.ID,
+			&e.TicketID,
+			&e.Action,
+			&e.Source,
+			&e.Actor,
+			&requestID,
+			&changes,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %w", err)
+		}
+		e.RequestID = requestID.String
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, fmt.Errorf("error decoding audit changes: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type MemoryAuditStore struct {
+	sync.RWMutex
+	entries []AuditEntry
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+func (s *MemoryAuditStore) Record(ctx context.Context, e *AuditEntry) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	e.ID = int64(len(s.entries) + 1)
+
+	stored := *e
+	stored.Changes = append([]FieldChange(nil), e.Changes...)
+	s.entries = append(s.entries, stored)
+	return nil
+}
+
+func (s *MemoryAuditStore) GetByTicketID(ctx context.Context, ticketID int64) ([]AuditEntry, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var entries []AuditEntry
+	for _, e := range s.entries {
+		if e.TicketID == ticketID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (s *MemoryAuditStore) snapshot() []AuditEntry {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]AuditEntry(nil), s.entries...)
+}
+
+func (s *MemoryAuditStore) restore(entries []AuditEntry) {
+	s.Lock()
+	defer s.Unlock()
+	s.entries = entries
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
+)
+
+// bulkChunkSize bounds the rows sent in one array bound statement and the
+// values of an IN list, which Oracle limits to 1000.
+const bulkChunkSize = 1000
+
+// GetManyByID returns the tickets among ids, keyed by TICKET_ID. Missing
+// tickets are left out. Deleted tickets are returned with DeletedAt set,
+// because Upsert still matches them.
+func (s *TicketStore) GetManyByID(ctx context.Context, ids []int64) (map[int64]*AssetReplacementTicket, error) {
+	tickets := make(map[int64]*AssetReplacementTicket, len(ids))
+	for start := 0; start < len(ids); start += bulkChunkSize {
+		chunk := ids[start:min(start+bulkChunkSize, len(ids))]
+		in, args := inBinds(1, chunk)
+		query := `
+			SELECT ` + ticketColumns + `
+			FROM ASSETS_REPLACEMENT_TICKETS
+			WHERE TICKET_ID IN (` + in + `)
+		`
+
+		if err := s.queryTickets(ctx, query, args, tickets); err != nil {
+			return nil, fmt.Errorf("error fetching tickets by ID: %w", err)
+		}
+	}
+	return tickets, nil
+}
+
+func (s *TicketStore) queryTickets(ctx context.Context, query string, args []any, into map[int64]*AssetReplacementTicket) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t AssetReplacementTicket
+		if err := scanTicket(rows, &t); err != nil {
+			return err
+		}
+		into[t.TicketID] = &t
+	}
+	return rows.Err()
+}
+
+// ActiveSerialHolders returns, for each of serials, the tickets of active
+// orders that have it. It is the set-wise form of ExistsActiveOrderWithSerial.
+func (s *TicketStore) ActiveSerialHolders(ctx context.Context, serials []string) (map[string][]int64, error) {
+	holders := make(map[string][]int64)
+	for start := 0; start < len(serials); start += bulkChunkSize {
+		chunk := serials[start:min(start+bulkChunkSize, len(serials))]
+		in, args := inBinds(1, chunk)
+		final, finalArgs := stageBinds(len(args)+1, workflow.FinalStages())
+		query := `
+			SELECT NO_SERIAL, TICKET_ID
+			FROM ASSETS_REPLACEMENT_TICKETS
+			WHERE NO_SERIAL IN (` + in + `)
+			  AND NVL(STAGE_PROCESS, 'NULL') NOT IN (` + final + `)
+			  AND DELETED_AT IS NULL
+		`
+
+		if err := s.querySerialHolders(ctx, query, append(args, finalArgs...), holders); err != nil {
+			return nil, fmt.Errorf("error fetching serial holders: %w", err)
+		}
+	}
+	return holders, nil
+}
+
+func (s *TicketStore) querySerialHolders(ctx context.Context, query string, args []any, into map[string][]int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var serial string
+		var ticketID int64
+		if err := rows.Scan(&serial, &ticketID); err != nil {
+			return err
+		}
+		into[serial] = append(into[serial], ticketID)
+	}
+	return rows.Err()
+}
+
+// UpsertMany runs the MERGE of Upsert for every row with go-ora array
+// binding, one round trip per chunk instead of one per row. Rows are applied
+// in order, so a ticket repeated in ds ends up with its last values.
+func (s *TicketStore) UpsertMany(ctx context.Context, ds []dto.TicketUpsertDTO) error {
+	query := `
+	MERGE INTO ASSETS_REPLACEMENT_TICKETS tgt
+	USING (SELECT :1 AS TICKET_ID FROM dual) src
+	ON (tgt.TICKET_ID = src.TICKET_ID)
+	WHEN MATCHED THEN
+		UPDATE SET
+			tgt.NO_SERIAL = NVL(:2, tgt.NO_SERIAL),
+			tgt.ORDER_NUMBER = NVL(:3, tgt.ORDER_NUMBER),
+			tgt.CAPEX = NVL(:4, tgt.CAPEX),
+			tgt.INVOICE_NUMBER = NVL(:5, tgt.INVOICE_NUMBER),
+			tgt.SUPPLIER = NVL(:6, tgt.SUPPLIER),
+			tgt.LAST_UPDATED = SYSDATE,
+			tgt.UPDATED_AT = SYSDATE,
+			tgt.VERSION = tgt.VERSION + 1
+	WHEN NOT MATCHED THEN
+		INSERT (TICKET_ID, NO_SERIAL, ORDER_NUMBER, CAPEX, INVOICE_NUMBER, SUPPLIER, CREATED_AT, UPDATED_AT)
+		VALUES (:1, :2, :3, :4, :5, :6, SYSDATE, SYSDATE)
+	`
+
+	for start := 0; start < len(ds); start += bulkChunkSize {
+		chunk := ds[start:min(start+bulkChunkSize, len(ds))]
+
+		ticketIDs := make([]int64, len(chunk))
+		serials := make([]sql.NullString, len(chunk))
+		orders := make([]sql.NullString, len(chunk))
+		capex := make([]sql.NullString, len(chunk))
+		invoices := make([]sql.NullString, len(chunk))
+		suppliers := make([]sql.NullString, len(chunk))
+		for i, d := range chunk {
+			ticketIDs[i] = d.TicketID
+			serials[i] = SqlString(d.NoSerial)
+			orders[i] = SqlString(d.OrderNumber)
+			capex[i] = SqlString(d.Capex)
+			invoices[i] = SqlString(d.InvoiceNumber)
+			suppliers[i] = SqlString(d.Supplier)
+		}
+
+		if err := s.execArray(ctx, query, ticketIDs, serials, orders, capex, invoices, suppliers); err != nil {
+			return fmt.Errorf("error upserting tickets %d to %d: %w", start+1, start+len(chunk), err)
+		}
+	}
+	return nil
+}
+
+func (s *TicketStore) execArray(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := conn(ctx, s.db).ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
+)
+
+// TicketFilter narrows the tickets listed by GetByFilters and ForEach. Zero
+// fields do not filter. Deleted tickets are never listed.
+type TicketFilter struct {
+	// Stages lists the stages to include. Nil means the pending stages, the
+	// tickets still being worked on. Tickets without a stage are in
+	// workflow.Initial.
+	Stages []workflow.Stage
+
+	CenterDistID *int64
+	// CenterDist matches the distribution center name exactly.
+	CenterDist string
+	CategoryID *int64
+	// Supplier matches a part of the supplier, ignoring case.
+	Supplier string
+	Capex    string
+	// HasInvoice lists the tickets with (true) or without (false) an invoice.
+	HasInvoice *bool
+	NoSerial   string
+
+	// From bounds are inclusive and To bounds exclusive.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+
+	// Q matches a part of the ticket ID, serial, order number, CAPEX,
+	// invoice number, supplier or distribution center, ignoring case.
+	Q string
+}
+
+func (f TicketFilter) stages() []workflow.Stage {
+	if f.Stages == nil {
+		return workflow.PendingStages()
+	}
+	return f.Stages
+}
+
+// where builds the conditions of f for ASSETS_REPLACEMENT_TICKETS.
+func (f TicketFilter) where() *whereBuilder {
+	b := &whereBuilder{}
+	b.add("DELETED_AT IS NULL")
+
+	stages := make([]string, len(f.stages()))
+	for i, s := range f.stages() {
+		stages[i] = string(s)
+	}
+	// A NULL STAGE_PROCESS is the initial stage, see workflow.Initial.
+	if containsStage(f.stages(), workflow.Initial) {
+		inOrNull(b, "STAGE_PROCESS", stages)
+	} else {
+		in(b, "STAGE_PROCESS", stages)
+	}
+
+	if f.CenterDistID != nil {
+		b.add("CENTER_DIST_ID = ?", *f.CenterDistID)
+	}
+	if f.CenterDist != "" {
+		b.add("CENTER_DIST = ?", f.CenterDist)
+	}
+	if f.CategoryID != nil {
+		b.add("CATEGORY_ID = ?", *f.CategoryID)
+	}
+	if f.Supplier != "" {
+		b.contains(f.Supplier, "SUPPLIER")
+	}
+	if f.Capex != "" {
+		b.add("CAPEX = ?", f.Capex)
+	}
+	if f.HasInvoice != nil {
+		if *f.HasInvoice {
+			b.add("INVOICE_NUMBER IS NOT NULL")
+		} else {
+			b.add("INVOICE_NUMBER IS NULL")
+		}
+	}
+	if f.NoSerial != "" {
+		b.add("NO_SERIAL = ?", f.NoSerial)
+	}
+	if f.CreatedFrom != nil {
+		b.add("CREATED_AT >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		b.add("CREATED_AT < ?", *f.CreatedTo)
+	}
+	if f.UpdatedFrom != nil {
+		b.add("UPDATED_AT >= ?", *f.UpdatedFrom)
+	}
+	if f.UpdatedTo != nil {
+		b.add("UPDATED_AT < ?", *f.UpdatedTo)
+	}
+	if f.Q != "" {
+		b.contains(f.Q, "TO_CHAR(TICKET_ID)", "NO_SERIAL", "ORDER_NUMBER", "CAPEX", "INVOICE_NUMBER", "SUPPLIER", "CENTER_DIST")
+	}
+	return b
+}
+
+// matches is where for the memory store. Empty strings are NULL, as in Oracle.
+func (f TicketFilter) matches(t *AssetReplacementTicket) bool {
+	c := readCopy(t)
+
+	stage := workflow.Stage(c.StageProcess.String)
+	if stage == "" {
+		stage = workflow.Initial
+	}
+	if !containsStage(f.stages(), stage) {
+		return false
+	}
+	if f.CenterDistID != nil && (!c.CenterDistID.Valid || c.CenterDistID.Int64 != *f.CenterDistID) {
+		return false
+	}
+	if f.CenterDist != "" && c.CenterDist.String != f.CenterDist {
+		return false
+	}
+	if f.CategoryID != nil && (!c.CategoryID.Valid || c.CategoryID.Int64 != *f.CategoryID) {
+		return false
+	}
+	if f.Supplier != "" && !containsFold(c.Supplier, f.Supplier) {
+		return false
+	}
+	if f.Capex != "" && t.Capex.String != f.Capex {
+		return false
+	}
+	if f.HasInvoice != nil && *f.HasInvoice != (c.InvoiceNumber.String != "") {
+		return false
+	}
+	if f.NoSerial != "" && c.NoSerial.String != f.NoSerial {
+		return false
+	}
+	if f.CreatedFrom != nil && c.CreatedAt.Before(*f.CreatedFrom) {
+		return false
+	}
+	if f.CreatedTo != nil && !c.CreatedAt.Before(*f.CreatedTo) {
+		return false
+	}
+	if f.UpdatedFrom != nil && c.UpdatedAt.Before(*f.UpdatedFrom) {
+		return false
+	}
+	if f.UpdatedTo != nil && !c.UpdatedAt.Before(*f.UpdatedTo) {
+		return false
+	}
+	if f.Q != "" {
+		id := sql.NullString{String: strconv.FormatInt(c.TicketID, 10), Valid: true}
+		found := false
+		for _, v := range []sql.NullString{id, c.NoSerial, c.OrderNumber, t.Capex, c.InvoiceNumber, c.Supplier, c.CenterDist} {
+			if containsFold(v, f.Q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsStage(stages []workflow.Stage, s workflow.Stage) bool {
+	for _, st := range stages {
+		if st == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(v sql.NullString, sub string) bool {
+	return v.Valid && v.String != "" && strings.Contains(strings.ToUpper(v.String), strings.ToUpper(sub))
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
 )
 
 type AssetReplacementTicket struct {
@@ -25,25 +27,28 @@ type AssetReplacementTicket struct {
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     sql.NullString `json:"deleted_at"`
+
+	// Version counts the writes to the ticket, starting at 1, and is kept in
+	// VERSION NUMBER DEFAULT 1 NOT NULL. Update and Delete only apply to the
+	// version they are given, so a read-modify-write cannot overwrite a
+	// change made in between.
+	Version int64 `json:"version"`
 }
 
 type TicketStore struct {
 	db *sql.DB
 }
 
-func (s *TicketStore) GetByID(ctx context.Context, id int64) (*AssetReplacementTicket, error) {
-	query := `
-		SELECT ID, TICKET_ID, CATEGORY_ID, NO_SERIAL, ORDER_NUMBER, NULLIF(CAPEX, '0') AS CAPEX,,
-			INVOICE_NUMBER, SUPPLIER, CENTER_DIST_ID, CENTER_DIST, STAGE_PROCESS
-		FROM ASSETS_REPLACEMENT_TICKETS
-		WHERE TICKET_ID = :1  
-			`
+// ticketColumns are the columns every ticket read selects, in the order
+// scanTicket reads them, so all reads return the same fields as the memory
+// store does.
+const ticketColumns = `ID, TICKET_ID, CATEGORY_ID, NO_SERIAL, ORDER_NUMBER, NULLIF(CAPEX, '0') AS CAPEX,
+	INVOICE_NUMBER, SUPPLIER, CENTER_DIST_ID, CENTER_DIST, STAGE_PROCESS, CREATED_AT, UPDATED_AT, VERSION,
+	TO_CHAR(DELETED_AT, 'YYYY-MM-DD"T"HH24:MI:SS') AS DELETED_AT`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-
-	var t AssetReplacementTicket
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+// scanTicket reads a row of ticketColumns, from *sql.Row or *sql.Rows.
+func scanTicket(row interface{ Scan(dest ...any) error }, t *AssetReplacementTicket) error {
+	return row.Scan(
 		&t.ID,
 		&t.TicketID,
 		&t.CategoryID,
@@ -52,11 +57,29 @@ func (s *TicketStore) GetByID(ctx context.Context, id int64) (*AssetReplacementT
 		&t.Capex,
 		&t.InvoiceNumber,
 		&t.Supplier,
-		&t.CenterDist,
 		&t.CenterDistID,
+		&t.CenterDist,
 		&t.StageProcess,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+		&t.Version,
+		&t.DeletedAt,
 	)
+}
 
+func (s *TicketStore) GetByID(ctx context.Context, id int64) (*AssetReplacementTicket, error) {
+	query := `
+		SELECT ` + ticketColumns + `
+		FROM ASSETS_REPLACEMENT_TICKETS
+		WHERE TICKET_ID = :1
+		  AND DELETED_AT IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var t AssetReplacementTicket
+	err := scanTicket(conn(ctx, s.db).QueryRowContext(ctx, query, id), &t)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -67,32 +90,36 @@ func (s *TicketStore) GetByID(ctx context.Context, id int64) (*AssetReplacementT
 
 }
 
-func (s *TicketStore) GetAll(ctx context.Context, stage, offset, limit int) ([]AssetReplacementTicket, int, error) {
+// GetByFilters returns a page of the tickets matching f and how many match
+// in total. Rows are in the order of p.Sort, also for a backward cursor.
+func (s *TicketStore) GetByFilters(ctx context.Context, f TicketFilter, p TicketPage) ([]AssetReplacementTicket, int, error) {
+	b := f.where()
 	countQuery := `
 		SELECT COUNT(*)
 		FROM ASSETS_REPLACEMENT_TICKETS
-		WHERE STAGE_PROCESS IN ('Request Initiated', 'Procurement Phase')
-		  AND DELETED_AT IS NULL
-	`
+		` + b.where()
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
 
 	var total int
-	if err := s.db.QueryRowContext(ctx, countQuery).Scan(&total); err != nil {
+	if err := conn(ctx, s.db).QueryRowContext(ctx, countQuery, b.args...).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("error counting tickets: %w", err)
 	}
 
+	offset := p.Offset
+	if p.Cursor != nil {
+		p.seek(b)
+		offset = 0
+	}
 	query := `
-		SELECT ID, TICKET_ID, CATEGORY_ID, NO_SERIAL, ORDER_NUMBER, NULLIF(CAPEX, '0') AS CAPEX,
-			   INVOICE_NUMBER, SUPPLIER, CENTER_DIST_ID, CENTER_DIST
+		SELECT ` + ticketColumns + `
 		FROM ASSETS_REPLACEMENT_TICKETS
-		WHERE STAGE_PROCESS IN ('Request Initiated', 'Procurement Phase')
-		ORDER BY CREATED_AT DESC
-		OFFSET :1 ROWS FETCH NEXT :2 ROWS ONLY
-	`
+		` + b.where() + `
+		ORDER BY ` + p.orderBy() + `
+		OFFSET ` + b.next(0) + ` ROWS FETCH NEXT ` + b.next(1) + ` ROWS ONLY`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-
-	rows, err := s.db.QueryContext(ctx, query, offset, limit)
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, append(b.args, offset, p.Limit)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("error fetching tickets: %w", err)
 	}
@@ -101,18 +128,7 @@ func (s *TicketStore) GetAll(ctx context.Context, stage, offset, limit int) ([]A
 	var tickets []AssetReplacementTicket
 	for rows.Next() {
 		var t AssetReplacementTicket
-		if err := rows.Scan(
-			&t.ID,
-			&t.TicketID,
-			&t.CategoryID,
-			&t.NoSerial,
-			&t.OrderNumber,
-			&t.Capex,
-			&t.InvoiceNumber,
-			&t.Supplier,
-			&t.CenterDistID,
-			&t.CenterDist,
-		); err != nil {
+		if err := scanTicket(rows, &t); err != nil {
 			return nil, 0, fmt.Errorf("error scanning ticket: %w", err)
 		}
 		tickets = append(tickets, t)
@@ -122,83 +138,45 @@ func (s *TicketStore) GetAll(ctx context.Context, stage, offset, limit int) ([]A
 		return nil, 0, fmt.Errorf("rows iteration error: %w", err)
 	}
 
+	if p.Cursor != nil && p.Cursor.Backward {
+		slices.Reverse(tickets)
+	}
 	return tickets, total, nil
 }
 
-func (s *TicketStore) GetByFilters(ctx context.Context, filters map[string]interface{}, offset, limit int) ([]AssetReplacementTicket, int, error) {
-	baseQuery := `
-        SELECT
-            ID, TICKET_ID, CATEGORY_ID, NO_SERIAL, ORDER_NUMBER, NULLIF(CAPEX, '0') AS CAPEX, 
-            INVOICE_NUMBER, SUPPLIER, CENTER_DIST_ID, CENTER_DIST
-        FROM ASSETS_REPLACEMENT_TICKETS
-        WHERE
-            STAGE_PROCESS IN ('Request Initiated', 'Procurement Phase')
-            OR NVL(STAGE_PROCESS, 'NULL') NOT IN ('COMPLETED')
-        `
-
-	var args []interface{}
-	argIndex := 1
-
-	if v, ok := filters["TICKET_ID"]; ok {
-		baseQuery += fmt.Sprintf("AND TICKET_ID = :%d", argIndex)
-		args = append(args, v)
-		argIndex++
-	}
-
-	if v, ok := filters["CENTER_DIST_ID"]; ok {
-		baseQuery += fmt.Sprintf(" AND CENTER_DIST_ID = :%d", argIndex)
-		args = append(args, v)
-		argIndex++
-	}
-	if v, ok := filters["CENTER_DIST"]; ok {
-		baseQuery += fmt.Sprintf(" AND CENTER_DIST = :%d", argIndex)
-		args = append(args, v)
-		argIndex++
-	}
-
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (%s)", baseQuery)
-	var total int
-	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, fmt.Errorf("error counting filtered tickets: %w", err)
-	}
-
-	baseQuery += fmt.Sprintf(" ORDER BY CREATED_AT DESC OFFSET :%d ROWS FETCH NEXT :%d ROWS ONLY", argIndex, argIndex+1)
-	args = append(args, offset, limit)
-
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+// ForEach calls fn with every ticket GetByFilters lists for f, in the default
+// order, as rows are read, so exports do not hold the whole table in memory.
+// It stops at the first error returned by fn. The query is bound by ctx only,
+// not by QueryTimeoutDuration, as fn may be writing to a slow client.
+func (s *TicketStore) ForEach(ctx context.Context, f TicketFilter, fn func(t *AssetReplacementTicket) error) error {
+	b := f.where()
+	query := `
+		SELECT ` + ticketColumns + `
+		FROM ASSETS_REPLACEMENT_TICKETS
+		` + b.where() + `
+		ORDER BY CREATED_AT DESC, ID DESC
+	`
 
-	rows, err := s.db.QueryContext(ctx, baseQuery, args...)
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, b.args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error fetching filtered tickets: %w", err)
+		return fmt.Errorf("error fetching tickets: %w", err)
 	}
 	defer rows.Close()
 
-	var tickets []AssetReplacementTicket
 	for rows.Next() {
 		var t AssetReplacementTicket
-		if err := rows.Scan(
-			&t.ID,
-			&t.TicketID,
-			&t.CategoryID,
-			&t.NoSerial,
-			&t.OrderNumber,
-			&t.Capex,
-			&t.InvoiceNumber,
-			&t.Supplier,
-			&t.CenterDistID,
-			&t.CenterDist,
-		); err != nil {
-			return nil, 0, fmt.Errorf("error scanning ticket: %w", err)
+		if err := scanTicket(rows, &t); err != nil {
+			return fmt.Errorf("error scanning ticket: %w", err)
+		}
+		if err := fn(&t); err != nil {
+			return err
 		}
-		tickets = append(tickets, t)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("rows iteration error: %w", err)
+		return fmt.Errorf("rows iteration error: %w", err)
 	}
-
-	return tickets, total, nil
+	return nil
 }
 
 func (s *TicketStore) Create(ctx context.Context, t *AssetReplacementTicket) error {
@@ -212,7 +190,7 @@ func (s *TicketStore) Create(ctx context.Context, t *AssetReplacementTicket) err
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(
+	_, err := conn(ctx, s.db).ExecContext(
 		ctx,
 		query,
 		t.TicketID,
@@ -228,8 +206,12 @@ func (s *TicketStore) Create(ctx context.Context, t *AssetReplacementTicket) err
 		sql.Out{Dest: &t.ID},
 	)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
 		return fmt.Errorf("error creating ticket: %w", err)
 	}
+	t.Version = 1
 	return nil
 }
 
@@ -246,7 +228,8 @@ func (s *TicketStore) Upsert(ctx context.Context, d dto.TicketUpsertDTO) error {
 			tgt.INVOICE_NUMBER = NVL(:5, tgt.INVOICE_NUMBER),
 			tgt.SUPPLIER = NVL(:6, tgt.SUPPLIER),
 			tgt.LAST_UPDATED = SYSDATE,
-			tgt.UPDATED_AT = SYSDATE
+			tgt.UPDATED_AT = SYSDATE,
+			tgt.VERSION = tgt.VERSION + 1
 	WHEN NOT MATCHED THEN
 		INSERT (TICKET_ID, NO_SERIAL, ORDER_NUMBER, CAPEX, INVOICE_NUMBER, SUPPLIER, CREATED_AT, UPDATED_AT)
 		VALUES (:1, :2, :3, :4, :5, :6, SYSDATE, SYSDATE)
@@ -255,7 +238,7 @@ func (s *TicketStore) Upsert(ctx context.Context, d dto.TicketUpsertDTO) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query,
+	_, err := conn(ctx, s.db).ExecContext(ctx, query,
 		d.TicketID,
 		d.NoSerial,
 		d.OrderNumber,
@@ -272,66 +255,76 @@ func (s *TicketStore) Upsert(ctx context.Context, d dto.TicketUpsertDTO) error {
 }
 
 func (s *TicketStore) ExistsActiveOrderWithSerial(ctx context.Context, serial string, excludeTicketID int64) (bool, error) {
+	final, args := stageBinds(3, workflow.FinalStages())
 	query := `
 		SELECT COUNT(1)
 		FROM ASSETS_REPLACEMENT_TICKETS
 		WHERE NO_SERIAL = :1
-		  AND NVL(STAGE_PROCESS, 'NULL') NOT IN ('COMPLETED')
 		  AND TICKET_ID <> :2
+		  AND NVL(STAGE_PROCESS, 'NULL') NOT IN (` + final + `)
 		  AND DELETED_AT IS NULL
 	`
 	var count int
-	err := s.db.QueryRowContext(ctx, query, serial, excludeTicketID).Scan(&count)
+	err := conn(ctx, s.db).QueryRowContext(ctx, query, append([]any{serial, excludeTicketID}, args...)...).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func (s *TicketStore) Delete(ctx context.Context, id int64) error {
+// Delete soft deletes the ticket if it is still at version. It returns
+// ErrVersionMismatch when the ticket was changed since.
+func (s *TicketStore) Delete(ctx context.Context, id, version int64) error {
 	query := `
 		UPDATE ASSETS_REPLACEMENT_TICKETS
-			SET DELETED_AT = SYSDATE, UPDATED_AT = SYSDATE
-			WHERE ID = :1
+			SET DELETED_AT = SYSDATE, UPDATED_AT = SYSDATE, VERSION = VERSION + 1
+			WHERE TICKET_ID = :1
+			  AND VERSION = :2
+			  AND DELETED_AT IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, id)
+	res, err := conn(ctx, s.db).ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
 
 	rows, _ := res.RowsAffected()
 	if rows == 0 {
-		return ErrNotFound
+		return s.missingOrStale(ctx, "TICKET_ID", id)
 	}
 
 	return nil
 }
 
+// Update writes t if the ticket is still at t.Version, which is then
+// incremented. It returns ErrVersionMismatch when the ticket was changed
+// since it was read.
 func (s *TicketStore) Update(ctx context.Context, t *AssetReplacementTicket) error {
 	query := `
 		UPDATE ASSETS_REPLACEMENT_TICKETS
 		SET
-			NO_SERIAL = :2,
-			ORDER_NUMBER = :3,
-			CAPEX =:5,
-			INVOICE_NUMBER = :6,
-			SUPPLIER = :7,
-			CENTER_DIST_ID = :8,
-			CENTER_DIST = :9,
-			STAGE_PROCESS = :10
-            LAST_UPDATED = SYSDATE,
-            UPDATED_AT = SYSDATE
-			WHERE ID = :11
-
+			NO_SERIAL = :1,
+			ORDER_NUMBER = :2,
+			CAPEX = :3,
+			INVOICE_NUMBER = :4,
+			SUPPLIER = :5,
+			CENTER_DIST_ID = :6,
+			CENTER_DIST = :7,
+			STAGE_PROCESS = :8,
+			LAST_UPDATED = SYSDATE,
+			UPDATED_AT = SYSDATE,
+			VERSION = VERSION + 1
+		WHERE ID = :9
+		  AND VERSION = :10
+		  AND DELETED_AT IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	res, err := s.db.ExecContext(
+	res, err := conn(ctx, s.db).ExecContext(
 		ctx,
 		query,
 		t.NoSerial,
@@ -342,6 +335,8 @@ func (s *TicketStore) Update(ctx context.Context, t *AssetReplacementTicket) err
 		t.CenterDistID,
 		t.CenterDist,
 		t.StageProcess,
+		t.ID,
+		t.Version,
 	)
 	if err != nil {
 		return fmt.Errorf("error updating ticket: %w", err)
@@ -349,11 +344,33 @@ func (s *TicketStore) Update(ctx context.Context, t *AssetReplacementTicket) err
 	}
 	rows, _ := res.RowsAffected()
 	if rows == 0 {
-		return ErrNotFound
+		return s.missingOrStale(ctx, "ID", t.ID)
 	}
+	t.Version++
 	return nil
 }
 
+// missingOrStale tells why a versioned write matched no row: ErrNotFound if
+// there is no live ticket with the given column value, ErrVersionMismatch if
+// there is one at another version.
+func (s *TicketStore) missingOrStale(ctx context.Context, column string, id int64) error {
+	query := `
+		SELECT COUNT(1)
+		FROM ASSETS_REPLACEMENT_TICKETS
+		WHERE ` + column + ` = :1
+		  AND DELETED_AT IS NULL
+	`
+
+	var count int
+	if err := conn(ctx, s.db).QueryRowContext(ctx, query, id).Scan(&count); err != nil {
+		return fmt.Errorf("error checking ticket version: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionMismatch
+}
+
 func (s *TicketStore) GetBasicTickets(ctx context.Context) ([]AssetReplacementTicket, error) {
 	query := `
 		SELECT 
@@ -366,7 +383,7 @@ func (s *TicketStore) GetBasicTickets(ctx context.Context) ([]AssetReplacementTi
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching basic tickets: %w", err)
 	}
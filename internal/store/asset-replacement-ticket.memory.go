@@ -0,0 +1,355 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
+)
+
+// MemoryTicketStore is an in-memory TicketRepository that mirrors the
+// semantics of the Oracle backed TicketStore. It is meant for local
+// development and tests, data is lost when the process exits.
+type MemoryTicketStore struct {
+	sync.RWMutex
+	tickets map[int64]*AssetReplacementTicket
+	nextID  int64
+	now     func() time.Time
+}
+
+func NewMemoryTicketStore() *MemoryTicketStore {
+	return &MemoryTicketStore{
+		tickets: make(map[int64]*AssetReplacementTicket),
+		nextID:  1,
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryTicketStore) GetByID(ctx context.Context, id int64) (*AssetReplacementTicket, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	t := s.findByTicketID(id)
+	if t == nil || t.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return readCopy(t), nil
+}
+
+func (s *MemoryTicketStore) GetByFilters(ctx context.Context, f TicketFilter, p TicketPage) ([]AssetReplacementTicket, int, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	matched := s.filter(f)
+	total := len(matched)
+	sort.SliceStable(matched, func(i, j int) bool { return p.compare(matched[i], matched[j]) < 0 })
+	if p.Cursor == nil {
+		return paginate(matched, p.Offset, p.Limit), total, nil
+	}
+
+	var page []*AssetReplacementTicket
+	for _, t := range matched {
+		if p.afterCursor(t) {
+			page = append(page, t)
+		}
+	}
+	if p.Cursor.Backward && len(page) > p.Limit {
+		page = page[len(page)-p.Limit:]
+	}
+	return paginate(page, 0, p.Limit), total, nil
+}
+
+// filter returns the tickets matching f, newest first. The caller holds the
+// lock.
+func (s *MemoryTicketStore) filter(f TicketFilter) []*AssetReplacementTicket {
+	var matched []*AssetReplacementTicket
+	for _, t := range s.tickets {
+		if !t.DeletedAt.Valid && f.matches(t) {
+			matched = append(matched, t)
+		}
+	}
+	sortByCreatedDesc(matched)
+	return matched
+}
+
+// ForEach copies the tickets GetByFilters lists before calling fn, so fn
+// runs without the lock held.
+func (s *MemoryTicketStore) ForEach(ctx context.Context, f TicketFilter, fn func(t *AssetReplacementTicket) error) error {
+	s.RLock()
+	tickets := paginate(s.filter(f), 0, -1)
+	s.RUnlock()
+
+	for i := range tickets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&tickets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTicketStore) Create(ctx context.Context, t *AssetReplacementTicket) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.findByTicketID(t.TicketID) != nil {
+		return ErrConflict
+	}
+
+	now := s.now()
+	t.ID = s.nextID
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	t.Version = 1
+	s.nextID++
+
+	stored := *t
+	s.tickets[stored.ID] = &stored
+	return nil
+}
+
+func (s *MemoryTicketStore) Update(ctx context.Context, t *AssetReplacementTicket) error {
+	s.Lock()
+	defer s.Unlock()
+
+	current, ok := s.tickets[t.ID]
+	if !ok || current.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if current.Version != t.Version {
+		return ErrVersionMismatch
+	}
+
+	now := s.now()
+	current.NoSerial = t.NoSerial
+	current.OrderNumber = t.OrderNumber
+	current.Capex = t.Capex
+	current.InvoiceNumber = t.InvoiceNumber
+	current.Supplier = t.Supplier
+	current.CenterDistID = t.CenterDistID
+	current.CenterDist = t.CenterDist
+	current.StageProcess = t.StageProcess
+	current.LastUpdated = sql.NullTime{Time: now, Valid: true}
+	current.UpdatedAt = now
+	current.Version++
+	t.Version = current.Version
+	return nil
+}
+
+func (s *MemoryTicketStore) Delete(ctx context.Context, id, version int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	t := s.findByTicketID(id)
+	if t == nil || t.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if t.Version != version {
+		return ErrVersionMismatch
+	}
+
+	now := s.now()
+	t.DeletedAt = sql.NullString{String: now.Format(time.RFC3339), Valid: true}
+	t.UpdatedAt = now
+	t.Version++
+	return nil
+}
+
+// Upsert follows the MERGE used by TicketStore: tickets are matched by
+// TICKET_ID (deleted or not) and nil or empty values keep the current
+// column value, just like NVL does in Oracle.
+func (s *MemoryTicketStore) Upsert(ctx context.Context, d dto.TicketUpsertDTO) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.upsert(d)
+	return nil
+}
+
+func (s *MemoryTicketStore) UpsertMany(ctx context.Context, ds []dto.TicketUpsertDTO) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, d := range ds {
+		s.upsert(d)
+	}
+	return nil
+}
+
+func (s *MemoryTicketStore) upsert(d dto.TicketUpsertDTO) {
+	now := s.now()
+	if t := s.findByTicketID(d.TicketID); t != nil {
+		MergeUpsert(t, d)
+		t.LastUpdated = sql.NullTime{Time: now, Valid: true}
+		t.UpdatedAt = now
+		t.Version++
+		return
+	}
+
+	t := &AssetReplacementTicket{
+		ID:        s.nextID,
+		TicketID:  d.TicketID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	}
+	MergeUpsert(t, d)
+	s.tickets[t.ID] = t
+	s.nextID++
+}
+
+func (s *MemoryTicketStore) GetManyByID(ctx context.Context, ids []int64) (map[int64]*AssetReplacementTicket, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	tickets := make(map[int64]*AssetReplacementTicket, len(ids))
+	for _, t := range s.tickets {
+		if wanted[t.TicketID] {
+			tickets[t.TicketID] = readCopy(t)
+		}
+	}
+	return tickets, nil
+}
+
+func (s *MemoryTicketStore) ActiveSerialHolders(ctx context.Context, serials []string) (map[string][]int64, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	wanted := make(map[string]bool, len(serials))
+	for _, serial := range serials {
+		wanted[serial] = true
+	}
+
+	holders := make(map[string][]int64)
+	for _, t := range s.tickets {
+		if t.DeletedAt.Valid || !t.NoSerial.Valid || !wanted[t.NoSerial.String] {
+			continue
+		}
+		if t.StageProcess.Valid && workflow.Stage(t.StageProcess.String).IsFinal() {
+			continue
+		}
+		holders[t.NoSerial.String] = append(holders[t.NoSerial.String], t.TicketID)
+	}
+	return holders, nil
+}
+
+func (s *MemoryTicketStore) ExistsActiveOrderWithSerial(ctx context.Context, serial string, excludeTicketID int64) (bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, t := range s.tickets {
+		if t.DeletedAt.Valid || t.TicketID == excludeTicketID {
+			continue
+		}
+		if !t.NoSerial.Valid || t.NoSerial.String != serial {
+			continue
+		}
+		if t.StageProcess.Valid && workflow.Stage(t.StageProcess.String).IsFinal() {
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *MemoryTicketStore) GetBasicTickets(ctx context.Context) ([]AssetReplacementTicket, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var matched []*AssetReplacementTicket
+	for _, t := range s.tickets {
+		if t.DeletedAt.Valid {
+			continue
+		}
+		matched = append(matched, t)
+	}
+	sortByCreatedDesc(matched)
+
+	return paginate(matched, 0, len(matched)), nil
+}
+
+func (s *MemoryTicketStore) findByTicketID(ticketID int64) *AssetReplacementTicket {
+	for _, t := range s.tickets {
+		if t.TicketID == ticketID {
+			return t
+		}
+	}
+	return nil
+}
+
+func sortByCreatedDesc(tickets []*AssetReplacementTicket) {
+	sort.Slice(tickets, func(i, j int) bool {
+		if tickets[i].CreatedAt.Equal(tickets[j].CreatedAt) {
+			return tickets[i].ID > tickets[j].ID
+		}
+		return tickets[i].CreatedAt.After(tickets[j].CreatedAt)
+	})
+}
+
+func paginate(tickets []*AssetReplacementTicket, offset, limit int) []AssetReplacementTicket {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(tickets) {
+		return nil
+	}
+
+	end := offset + limit
+	if limit < 0 || end > len(tickets) {
+		end = len(tickets)
+	}
+
+	result := make([]AssetReplacementTicket, 0, end-offset)
+	for _, t := range tickets[offset:end] {
+		result = append(result, *readCopy(t))
+	}
+	return result
+}
+
+// readCopy returns a copy of t as the SELECT statements of TicketStore would
+// return it, applying NULLIF(CAPEX, '0').
+func readCopy(t *AssetReplacementTicket) *AssetReplacementTicket {
+	c := *t
+	if c.Capex.Valid && c.Capex.String == "0" {
+		c.Capex = sql.NullString{}
+	}
+	return &c
+}
+
+type memoryTicketState struct {
+	tickets map[int64]AssetReplacementTicket
+	nextID  int64
+}
+
+func (s *MemoryTicketStore) snapshot() memoryTicketState {
+	s.RLock()
+	defer s.RUnlock()
+
+	state := memoryTicketState{tickets: make(map[int64]AssetReplacementTicket, len(s.tickets)), nextID: s.nextID}
+	for id, t := range s.tickets {
+		state.tickets[id] = *t
+	}
+	return state
+}
+
+func (s *MemoryTicketStore) restore(state memoryTicketState) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.tickets = make(map[int64]*AssetReplacementTicket, len(state.tickets))
+	for id, t := range state.tickets {
+		s.tickets[id] = &t
+	}
+	s.nextID = state.nextID
+}
@@ -0,0 +1,14 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store/storetest"
+)
+
+func TestMemoryTicketStoreContract(t *testing.T) {
+	storetest.RunTicketRepositoryContract(t, func(t *testing.T) store.TicketRepository {
+		return store.NewMemoryTicketStore()
+	})
+}
@@ -0,0 +1,328 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+)
+
+var ErrInvalidCursor = apierror.New(apierror.CodeInvalidCursor, "cursor inválido")
+
+// TicketPage selects a page of the tickets listed by GetByFilters.
+type TicketPage struct {
+	// Sort orders the tickets, by -created_at when empty. ID is always the
+	// last key, so the order is total and a cursor points to a single row.
+	Sort []SortKey
+
+	Offset int
+	Limit  int
+
+	// Cursor, when set, replaces Offset: the page starts right after the row
+	// it points to or, for a backward cursor, ends right before it.
+	Cursor *Cursor
+}
+
+// SortKey orders tickets by one of the fields in sortFields.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+type sortKind int
+
+const (
+	sortInt sortKind = iota
+	sortTime
+	sortString
+)
+
+type sortField struct {
+	// expr is the SQL the rows are ordered and compared by. Nullable text
+	// columns are read as ' ', so NULLs sort as the lowest value and can be
+	// compared in a cursor.
+	expr  string
+	kind  sortKind
+	value func(t *AssetReplacementTicket) string
+}
+
+// sortFields are the fields tickets can be sorted by.
+var sortFields = map[string]sortField{
+	"ticket_id":     {"TICKET_ID", sortInt, func(t *AssetReplacementTicket) string { return strconv.FormatInt(t.TicketID, 10) }},
+	"created_at":    {"CREATED_AT", sortTime, func(t *AssetReplacementTicket) string { return t.CreatedAt.Format(time.RFC3339Nano) }},
+	"updated_at":    {"UPDATED_AT", sortTime, func(t *AssetReplacementTicket) string { return t.UpdatedAt.Format(time.RFC3339Nano) }},
+	"stage_process": {"NVL(STAGE_PROCESS, ' ')", sortString, func(t *AssetReplacementTicket) string { return sortText(t.StageProcess.String) }},
+	"supplier":      {"NVL(SUPPLIER, ' ')", sortString, func(t *AssetReplacementTicket) string { return sortText(t.Supplier.String) }},
+	"center_dist":   {"NVL(CENTER_DIST, ' ')", sortString, func(t *AssetReplacementTicket) string { return sortText(t.CenterDist.String) }},
+	"order_number":  {"NVL(ORDER_NUMBER, ' ')", sortString, func(t *AssetReplacementTicket) string { return sortText(t.OrderNumber.String) }},
+	"no_serial":     {"NVL(NO_SERIAL, ' ')", sortString, func(t *AssetReplacementTicket) string { return sortText(t.NoSerial.String) }},
+}
+
+// sortText is the NVL(col, ' ') of sortFields, an empty string being NULL.
+func sortText(s string) string {
+	if s == "" {
+		return " "
+	}
+	return s
+}
+
+var defaultSort = []SortKey{{Field: "created_at", Desc: true}}
+
+// ParseSort reads a comma separated list of sortFields, each optionally
+// prefixed with - for descending order, e.g. "-created_at,supplier".
+func ParseSort(s string) ([]SortKey, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var keys []SortKey
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		key := SortKey{Field: strings.TrimPrefix(strings.TrimPrefix(part, "-"), "+")}
+		key.Desc = strings.HasPrefix(part, "-")
+
+		if _, ok := sortFields[key.Field]; !ok {
+			return nil, apierror.Newf(apierror.CodeInvalidRequest, "campo de orden inválido: %q, se esperaba uno de %s", key.Field, strings.Join(SortFields(), ", "))
+		}
+		if seen[key.Field] {
+			return nil, apierror.Newf(apierror.CodeInvalidRequest, "campo de orden repetido: %q", key.Field)
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SortFields lists the fields tickets can be sorted by, see ParseSort.
+func SortFields() []string {
+	names := make([]string, 0, len(sortFields))
+	for name := range sortFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FormatSort is the inverse of ParseSort.
+func FormatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.Field
+		if k.Desc {
+			parts[i] = "-" + k.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (p TicketPage) sort() []SortKey {
+	if len(p.Sort) == 0 {
+		return defaultSort
+	}
+	return p.Sort
+}
+
+// Cursor points to a row of a listing: its values for the sort keys and its
+// ID. It is handed to clients as an opaque string, see Encode.
+type Cursor struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	ID       int64    `json:"id"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+// CursorFor returns the cursor of t in a listing ordered by p.Sort, going
+// backward when backward is set.
+func (p TicketPage) CursorFor(t *AssetReplacementTicket, backward bool) *Cursor {
+	keys := p.sort()
+	c := &Cursor{Sort: FormatSort(keys), ID: t.ID, Backward: backward}
+	for _, k := range keys {
+		c.Values = append(c.Values, sortFields[k.Field].value(t))
+	}
+	return c
+}
+
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor reads a cursor made by Encode for a listing sorted by keys.
+// A cursor of a listing sorted differently is rejected.
+func DecodeCursor(s string, keys []SortKey) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if len(keys) == 0 {
+		keys = defaultSort
+	}
+	if c.Sort != FormatSort(keys) {
+		return nil, apierror.Wrapf(ErrInvalidCursor, "fue creado para sort=%s", c.Sort)
+	}
+	if len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	for i, k := range keys {
+		if _, err := sortFields[k.Field].parse(c.Values[i]); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// parse converts a cursor value to the type of the field, for binds and
+// comparisons.
+func (f sortField) parse(v string) (any, error) {
+	switch f.kind {
+	case sortInt:
+		return strconv.ParseInt(v, 10, 64)
+	case sortTime:
+		return time.Parse(time.RFC3339Nano, v)
+	default:
+		return v, nil
+	}
+}
+
+// orderBy returns the ORDER BY list of p, reversed for a backward cursor.
+func (p TicketPage) orderBy() string {
+	backward := p.Cursor != nil && p.Cursor.Backward
+	keys := p.sort()
+	parts := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		parts = append(parts, sortFields[k.Field].expr+direction(k.Desc != backward))
+	}
+	parts = append(parts, "ID"+direction(keys[len(keys)-1].Desc != backward))
+	return strings.Join(parts, ", ")
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+	return " ASC"
+}
+
+// seek adds to b the condition selecting the rows after the cursor in the
+// order of orderBy: (k1 > v1) OR (k1 = v1 AND k2 > v2) ... OR (keys equal AND
+// ID > id), with < for descending keys.
+func (p TicketPage) seek(b *whereBuilder) {
+	c := p.Cursor
+	keys := p.sort()
+
+	exprs := make([]string, 0, len(keys)+1)
+	values := make([]any, 0, len(keys)+1)
+	ops := make([]string, 0, len(keys)+1)
+	for i, k := range keys {
+		f := sortFields[k.Field]
+		v, _ := f.parse(c.Values[i])
+		exprs = append(exprs, f.expr)
+		values = append(values, v)
+		ops = append(ops, seekOp(k.Desc, c.Backward))
+	}
+	exprs = append(exprs, "ID")
+	values = append(values, c.ID)
+	ops = append(ops, seekOp(keys[len(keys)-1].Desc, c.Backward))
+
+	var ors []string
+	var args []any
+	for i := range exprs {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, exprs[j]+" = ?")
+			args = append(args, values[j])
+		}
+		ands = append(ands, exprs[i]+" "+ops[i]+" ?")
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	b.add("("+strings.Join(ors, " OR ")+")", args...)
+}
+
+func seekOp(desc, backward bool) string {
+	if desc != backward {
+		return "<"
+	}
+	return ">"
+}
+
+// compare orders a and b as orderBy does, without the reversal of a backward
+// cursor. It is used by the memory store.
+func (p TicketPage) compare(a, b *AssetReplacementTicket) int {
+	keys := p.sort()
+	for _, k := range keys {
+		f := sortFields[k.Field]
+		if c := f.compare(f.value(a), f.value(b)); c != 0 {
+			if k.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+	c := compareInt64(a.ID, b.ID)
+	if keys[len(keys)-1].Desc {
+		return -c
+	}
+	return c
+}
+
+// afterCursor reports whether t comes after the cursor in its direction.
+func (p TicketPage) afterCursor(t *AssetReplacementTicket) bool {
+	c := p.Cursor
+	keys := p.sort()
+	cmp := 0
+	for i, k := range keys {
+		f := sortFields[k.Field]
+		if cmp = f.compare(f.value(t), c.Values[i]); cmp != 0 {
+			if k.Desc {
+				cmp = -cmp
+			}
+			break
+		}
+	}
+	if cmp == 0 {
+		cmp = compareInt64(t.ID, c.ID)
+		if keys[len(keys)-1].Desc {
+			cmp = -cmp
+		}
+	}
+	if c.Backward {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+func (f sortField) compare(a, b string) int {
+	switch f.kind {
+	case sortInt:
+		x, _ := strconv.ParseInt(a, 10, 64)
+		y, _ := strconv.ParseInt(b, 10, 64)
+		return compareInt64(x, y)
+	case sortTime:
+		x, _ := time.Parse(time.RFC3339Nano, a)
+		y, _ := time.Parse(time.RFC3339Nano, b)
+		return x.Compare(y)
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
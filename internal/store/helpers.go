@@ -1,6 +1,15 @@
 package store
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
+	"github.com/sijms/go-ora/v2/network"
+)
 
 func SqlString(s *string) sql.NullString {
 	if s == nil {
@@ -15,3 +24,48 @@ func SqlInt64(i *int64) sql.NullInt64 {
 	}
 	return sql.NullInt64{Int64: *i, Valid: true}
 }
+
+// MergeUpsert applies d to t the way the MERGE of TicketStore.Upsert does:
+// nil or empty values keep the current column value.
+func MergeUpsert(t *AssetReplacementTicket, d dto.TicketUpsertDTO) {
+	t.NoSerial = nvl(d.NoSerial, t.NoSerial)
+	t.OrderNumber = nvl(d.OrderNumber, t.OrderNumber)
+	t.Capex = nvl(d.Capex, t.Capex)
+	t.InvoiceNumber = nvl(d.InvoiceNumber, t.InvoiceNumber)
+	t.Supplier = nvl(d.Supplier, t.Supplier)
+}
+
+// nvl mimics Oracle's NVL for string binds, where an empty string is NULL.
+func nvl(v *string, current sql.NullString) sql.NullString {
+	if v == nil || *v == "" {
+		return current
+	}
+	return sql.NullString{String: *v, Valid: true}
+}
+
+// isUniqueViolation reports whether err is ORA-00001 (unique constraint violated).
+func isUniqueViolation(err error) bool {
+	var oraErr *network.OracleError
+	return errors.As(err, &oraErr) && oraErr.ErrCode == 1
+}
+
+// stageBinds returns the bind placeholders for stages starting at :start,
+// ready to be used in an IN (...) list, and their values.
+func stageBinds(start int, stages []workflow.Stage) (string, []any) {
+	values := make([]string, len(stages))
+	for i, s := range stages {
+		values[i] = string(s)
+	}
+	return inBinds(start, values)
+}
+
+// inBinds is stageBinds for any list of values.
+func inBinds[T any](start int, values []T) (string, []any) {
+	placeholders := make([]string, len(values))
+	args := make([]any, len(values))
+	for i, v := range values {
+		placeholders[i] = fmt.Sprintf(":%d", start+i)
+		args[i] = v
+	}
+	return strings.Join(placeholders, ", "), args
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ImportProfile is a saved column mapping for CSV and XLSX imports, see
+// services.ColumnMapping. Profiles are stored in IMPORT_PROFILES:
+//
+//	NAME VARCHAR2(100) PRIMARY KEY, DESCRIPTION VARCHAR2(400),
+//	COLUMNS CLOB CHECK (COLUMNS IS JSON), CREATED_AT DATE, UPDATED_AT DATE
+type ImportProfile struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Columns     map[string][]string `json:"columns"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+type ImportProfileStore struct {
+	db *sql.DB
+}
+
+func (s *ImportProfileStore) List(ctx context.Context) ([]ImportProfile, error) {
+	query := `
+		SELECT NAME, DESCRIPTION, COLUMNS, CREATED_AT, UPDATED_AT
+		FROM IMPORT_PROFILES
+		ORDER BY NAME
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching import profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := []ImportProfile{}
+	for rows.Next() {
+		p, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return profiles, nil
+}
+
+func (s *ImportProfileStore) GetByName(ctx context.Context, name string) (*ImportProfile, error) {
+	query := `
+		SELECT NAME, DESCRIPTION, COLUMNS, CREATED_AT, UPDATED_AT
+		FROM IMPORT_PROFILES
+		WHERE NAME = :1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	p, err := scanImportProfile(conn(ctx, s.db).QueryRowContext(ctx, query, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return p, err
+}
+
+// Save creates the profile or replaces the description and columns of the
+// one with the same name, then sets the timestamps of p.
+func (s *ImportProfileStore) Save(ctx context.Context, p *ImportProfile) error {
+	query := `
+		MERGE INTO IMPORT_PROFILES tgt
+		USING (SELECT :1 AS NAME FROM dual) src
+		ON (tgt.NAME = src.NAME)
+		WHEN MATCHED THEN
+			UPDATE SET
+				tgt.DESCRIPTION = :2,
+				tgt.COLUMNS = :3,
+				tgt.UPDATED_AT = :4
+		WHEN NOT MATCHED THEN
+			INSERT (NAME, DESCRIPTION, COLUMNS, CREATED_AT, UPDATED_AT)
+			VALUES (:1, :2, :3, :4, :4)
+	`
+
+	columns, err := json.Marshal(p.Columns)
+	if err != nil {
+		return fmt.Errorf("error encoding import profile columns: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err = conn(ctx, s.db).ExecContext(ctx, query, p.Name, p.Description, string(columns), time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving import profile: %w", err)
+	}
+
+	saved, err := s.GetByName(ctx, p.Name)
+	if err != nil {
+		return err
+	}
+	p.CreatedAt, p.UpdatedAt = saved.CreatedAt, saved.UpdatedAt
+	return nil
+}
+
+func (s *ImportProfileStore) Delete(ctx context.Context, name string) error {
+	query := `DELETE FROM IMPORT_PROFILES WHERE NAME = :1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, query, name)
+	if err != nil {
+		return fmt.Errorf("error deleting import profile: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanImportProfile(row interface{ Scan(...any) error }) (*ImportProfile, error) {
+	var (
+		p           ImportProfile
+		description sql.NullString
+		columns     string
+	)
+	if err := row.Scan(&p.Name, &description, &columns, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error scanning import profile: %w", err)
+	}
+	p.Description = description.String
+	if err := json.Unmarshal([]byte(columns), &p.Columns); err != nil {
+		return nil, fmt.Errorf("error decoding import profile columns: %w", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryImportProfileStore struct {
+	sync.RWMutex
+	profiles map[string]ImportProfile
+	now      func() time.Time
+}
+
+func NewMemoryImportProfileStore() *MemoryImportProfileStore {
+	return &MemoryImportProfileStore{
+		profiles: make(map[string]ImportProfile),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryImportProfileStore) List(ctx context.Context) ([]ImportProfile, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	profiles := make([]ImportProfile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		profiles = append(profiles, copyImportProfile(p))
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+func (s *MemoryImportProfileStore) GetByName(ctx context.Context, name string) (*ImportProfile, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	p, ok := s.profiles[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	p = copyImportProfile(p)
+	return &p, nil
+}
+
+func (s *MemoryImportProfileStore) Save(ctx context.Context, p *ImportProfile) error {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	p.CreatedAt, p.UpdatedAt = now, now
+	if current, ok := s.profiles[p.Name]; ok {
+		p.CreatedAt = current.CreatedAt
+	}
+	s.profiles[p.Name] = copyImportProfile(*p)
+	return nil
+}
+
+func (s *MemoryImportProfileStore) Delete(ctx context.Context, name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.profiles[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.profiles, name)
+	return nil
+}
+
+func copyImportProfile(p ImportProfile) ImportProfile {
+	columns := make(map[string][]string, len(p.Columns))
+	for f, aliases := range p.Columns {
+		columns[f] = append([]string(nil), aliases...)
+	}
+	p.Columns = columns
+	return p
+}
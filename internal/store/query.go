@@ -0,0 +1,85 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// whereBuilder collects the conditions of a WHERE clause and their values.
+// Conditions are written with ? for each value and numbered as :1, :2... in
+// order, so values only ever travel as binds and never in the SQL text.
+type whereBuilder struct {
+	conds []string
+	args  []any
+}
+
+// add appends cond, ANDed with the others, binding values to its ? in order.
+func (b *whereBuilder) add(cond string, values ...any) {
+	if n := strings.Count(cond, "?"); n != len(values) {
+		panic(fmt.Sprintf("store: condition %q has %d placeholders for %d values", cond, n, len(values)))
+	}
+
+	var sb strings.Builder
+	for _, part := range strings.SplitAfter(cond, "?") {
+		if !strings.HasSuffix(part, "?") {
+			sb.WriteString(part)
+			continue
+		}
+		b.args = append(b.args, values[0])
+		values = values[1:]
+		fmt.Fprintf(&sb, "%s:%d", strings.TrimSuffix(part, "?"), len(b.args))
+	}
+	b.conds = append(b.conds, sb.String())
+}
+
+// in appends "column IN (...)" with a bind per value. An empty list matches
+// nothing.
+func in[T any](b *whereBuilder, column string, values []T) {
+	if len(values) == 0 {
+		b.conds = append(b.conds, "1 = 0")
+		return
+	}
+	binds, args := inBinds(len(b.args)+1, values)
+	b.conds = append(b.conds, column+" IN ("+binds+")")
+	b.args = append(b.args, args...)
+}
+
+// inOrNull is in that also matches a NULL column.
+func inOrNull[T any](b *whereBuilder, column string, values []T) {
+	if len(values) == 0 {
+		b.conds = append(b.conds, column+" IS NULL")
+		return
+	}
+	binds, args := inBinds(len(b.args)+1, values)
+	b.conds = append(b.conds, "("+column+" IN ("+binds+") OR "+column+" IS NULL)")
+	b.args = append(b.args, args...)
+}
+
+// contains appends a case insensitive substring match of value against any
+// of columns. LIKE wildcards in value are matched literally.
+func (b *whereBuilder) contains(value string, columns ...string) {
+	pattern := "%" + likeEscaper.Replace(strings.ToUpper(value)) + "%"
+	ors := make([]string, len(columns))
+	values := make([]any, len(columns))
+	for i, col := range columns {
+		ors[i] = "UPPER(" + col + `) LIKE ? ESCAPE '\'`
+		values[i] = pattern
+	}
+	b.add("("+strings.Join(ors, " OR ")+")", values...)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// where returns the WHERE clause, empty without conditions.
+func (b *whereBuilder) where() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(b.conds, "\n\t\t  AND ")
+}
+
+// next returns the placeholder of the next bind, for the clauses that follow
+// the WHERE such as OFFSET and FETCH.
+func (b *whereBuilder) next(offset int) string {
+	return fmt.Sprintf(":%d", len(b.args)+1+offset)
+}
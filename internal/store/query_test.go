@@ -0,0 +1,73 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWhereBuilder(t *testing.T) {
+	b := &whereBuilder{}
+	b.add("DELETED_AT IS NULL")
+	in(b, "STAGE_PROCESS", []string{"A", "B"})
+	b.add("CREATED_AT >= ? AND CREATED_AT < ?", 1, 2)
+	b.contains("50%_off", "SUPPLIER", "CAPEX")
+
+	want := "WHERE DELETED_AT IS NULL\n\t\t  AND STAGE_PROCESS IN (:1, :2)\n\t\t  AND CREATED_AT >= :3 AND CREATED_AT < :4\n\t\t  AND (UPPER(SUPPLIER) LIKE :5 ESCAPE '\\' OR UPPER(CAPEX) LIKE :6 ESCAPE '\\')"
+	if got := b.where(); got != want {
+		t.Errorf("where() =\n%s\nwant\n%s", got, want)
+	}
+
+	pattern := `%50\%\_OFF%`
+	if want := []any{"A", "B", 1, 2, pattern, pattern}; !reflect.DeepEqual(b.args, want) {
+		t.Errorf("args = %v, want %v", b.args, want)
+	}
+	if got := b.next(1); got != ":8" {
+		t.Errorf("next(1) = %s, want :8", got)
+	}
+}
+
+func TestWhereBuilderEmptyIn(t *testing.T) {
+	b := &whereBuilder{}
+	in(b, "STAGE_PROCESS", []string{})
+	if got := b.where(); !strings.Contains(got, "1 = 0") || len(b.args) != 0 {
+		t.Errorf("where() = %q with %v", got, b.args)
+	}
+}
+
+func TestWhereBuilderInOrNull(t *testing.T) {
+	b := &whereBuilder{}
+	b.add("ID > ?", 1)
+	inOrNull(b, "STAGE_PROCESS", []string{"A", "B"})
+	if got, want := b.where(), "WHERE ID > :1\n\t\t  AND (STAGE_PROCESS IN (:2, :3) OR STAGE_PROCESS IS NULL)"; got != want {
+		t.Errorf("where() =\n%s\nwant\n%s", got, want)
+	}
+
+	b = &whereBuilder{}
+	inOrNull(b, "STAGE_PROCESS", []string{})
+	if got := b.where(); got != "WHERE STAGE_PROCESS IS NULL" || len(b.args) != 0 {
+		t.Errorf("where() = %q with %v", got, b.args)
+	}
+}
+
+func TestTicketPageSeek(t *testing.T) {
+	keys, err := ParseSort("supplier,-created_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := TicketPage{Sort: keys, Cursor: &Cursor{Values: []string{"ACME", "2026-01-02T03:04:05Z"}, ID: 9, Backward: true}}
+
+	if got, want := p.orderBy(), "NVL(SUPPLIER, ' ') DESC, CREATED_AT ASC, ID ASC"; got != want {
+		t.Errorf("orderBy() = %q, want %q", got, want)
+	}
+
+	b := &whereBuilder{}
+	p.seek(b)
+	want := "WHERE ((NVL(SUPPLIER, ' ') < :1) OR (NVL(SUPPLIER, ' ') = :2 AND CREATED_AT > :3) OR (NVL(SUPPLIER, ' ') = :4 AND CREATED_AT = :5 AND ID > :6))"
+	if got := b.where(); got != want {
+		t.Errorf("seek =\n%s\nwant\n%s", got, want)
+	}
+	if len(b.args) != 6 || b.args[5] != int64(9) {
+		t.Errorf("args = %v", b.args)
+	}
+}
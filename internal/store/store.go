@@ -3,37 +3,75 @@ package store
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"time"
 
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
 	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
 )
 
 var (
-	ErrNotFound          = errors.New("resource not found")
-	ErrConflict          = errors.New("resource already exists")
+	ErrNotFound          = apierror.New(apierror.CodeNotFound, "resource not found")
+	ErrConflict          = apierror.New(apierror.CodeAlreadyExists, "resource already exists")
+	ErrVersionMismatch   = apierror.New(apierror.CodeVersionMismatch, "resource was modified")
 	QueryTimeoutDuration = time.Second * 5
 )
 
 type TicketRepository interface {
-	GetAll(ctx context.Context, stage, offset, limit int) ([]AssetReplacementTicket, int, error)
+	GetByFilters(ctx context.Context, f TicketFilter, p TicketPage) ([]AssetReplacementTicket, int, error)
 	GetByID(ctx context.Context, id int64) (*AssetReplacementTicket, error)
 	Create(ctx context.Context, ticket *AssetReplacementTicket) error
 	Update(ctx context.Context, ticket *AssetReplacementTicket) error
-	Delete(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id, version int64) error
 
 	Upsert(ctx context.Context, d dto.TicketUpsertDTO) error
 	ExistsActiveOrderWithSerial(ctx context.Context, serial string, excludeTicketID int64) (bool, error)
 	GetBasicTickets(ctx context.Context) ([]AssetReplacementTicket, error)
+	ForEach(ctx context.Context, f TicketFilter, fn func(t *AssetReplacementTicket) error) error
+
+	// Set-wise forms of GetByID, ExistsActiveOrderWithSerial and Upsert for
+	// batch imports. Unlike GetByID, GetManyByID also returns deleted
+	// tickets, with DeletedAt set.
+	GetManyByID(ctx context.Context, ids []int64) (map[int64]*AssetReplacementTicket, error)
+	ActiveSerialHolders(ctx context.Context, serials []string) (map[string][]int64, error)
+	UpsertMany(ctx context.Context, ds []dto.TicketUpsertDTO) error
+}
+
+type AuditRepository interface {
+	Record(ctx context.Context, entry *AuditEntry) error
+	GetByTicketID(ctx context.Context, ticketID int64) ([]AuditEntry, error)
+}
+
+type ImportProfileRepository interface {
+	List(ctx context.Context) ([]ImportProfile, error)
+	GetByName(ctx context.Context, name string) (*ImportProfile, error)
+	Save(ctx context.Context, p *ImportProfile) error
+	Delete(ctx context.Context, name string) error
 }
 
 type Storage struct {
-	Tickets TicketRepository
+	Tickets        TicketRepository
+	Audit          AuditRepository
+	ImportProfiles ImportProfileRepository
+	Tx             Transactor
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Tickets: &TicketStore{db: db},
+		Tickets:        &TicketStore{db: db},
+		Audit:          &AuditStore{db: db},
+		ImportProfiles: &ImportProfileStore{db: db},
+		Tx:             &sqlTransactor{db: db},
+	}
+}
+
+func NewMemoryStorage() Storage {
+	tickets := NewMemoryTicketStore()
+	audit := NewMemoryAuditStore()
+	return Storage{
+		Tickets:        tickets,
+		Audit:          audit,
+		ImportProfiles: NewMemoryImportProfileStore(),
+		Tx:             &memoryTransactor{tickets: tickets, audit: audit},
 	}
 }
 
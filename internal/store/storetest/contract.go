@@ -0,0 +1,944 @@
+// Package storetest holds the behaviour every store.TicketRepository
+// implementation must honour. Backends plug into it from their own tests:
+//
+//	func TestMemoryTicketStore(t *testing.T) {
+//		storetest.RunTicketRepositoryContract(t, func(t *testing.T) store.TicketRepository {
+//			return store.NewMemoryTicketStore()
+//		})
+//	}
+//
+// The Oracle TicketStore runs it when ORACLE_TEST_DSN is set, see
+// TestOracleTicketStoreContract in internal/store.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/dto"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/store"
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/workflow"
+)
+
+// Factory returns an empty repository for a single subtest.
+type Factory func(t *testing.T) store.TicketRepository
+
+const (
+	stageInitiated   = "Request Initiated"
+	stageProcurement = "Procurement Phase"
+	stageCompleted   = "COMPLETED"
+)
+
+func RunTicketRepositoryContract(t *testing.T, newRepo Factory) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, repo store.TicketRepository)
+	}{
+		{"CreateAssignsID", testCreateAssignsID},
+		{"CreateDuplicateTicketID", testCreateDuplicateTicketID},
+		{"GetByIDUsesTicketID", testGetByIDUsesTicketID},
+		{"GetByIDNotFound", testGetByIDNotFound},
+		{"GetByIDHidesZeroCapex", testGetByIDHidesZeroCapex},
+		{"Update", testUpdate},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"DeleteIsSoft", testDeleteIsSoft},
+		{"DeleteNotFound", testDeleteNotFound},
+		{"WritesBumpVersion", testWritesBumpVersion},
+		{"StaleVersionIsRejected", testStaleVersionIsRejected},
+		{"UpsertInserts", testUpsertInserts},
+		{"UpsertMergesNonEmptyValues", testUpsertMergesNonEmptyValues},
+		{"UpsertDoesNotRestoreDeleted", testUpsertDoesNotRestoreDeleted},
+		{"ExistsActiveOrderWithSerial", testExistsActiveOrderWithSerial},
+		{"GetManyByIDSkipsMissingReturnsDeleted", testGetManyByIDSkipsMissingReturnsDeleted},
+		{"ActiveSerialHolders", testActiveSerialHolders},
+		{"UpsertManyAppliesRowsInOrder", testUpsertManyAppliesRowsInOrder},
+		{"GetByFiltersDefaultsToPendingStages", testGetByFiltersDefaultsToPendingStages},
+		{"GetByFiltersNullStageIsInitial", testGetByFiltersNullStageIsInitial},
+		{"GetByFilters", testGetByFilters},
+		{"GetByFiltersPagination", testGetByFiltersPagination},
+		{"GetByFiltersSorts", testGetByFiltersSorts},
+		{"GetByFiltersCursor", testGetByFiltersCursor},
+		{"ForEachMatchesGetByFilters", testForEachMatchesGetByFilters},
+		{"GetBasicTicketsSkipsDeleted", testGetBasicTicketsSkipsDeleted},
+		{"ReadsReturnVersionAndTimestamps", testReadsReturnVersionAndTimestamps},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.fn(t, newRepo(t))
+		})
+	}
+}
+
+func testCreateAssignsID(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+
+	first := mustCreate(t, repo, newTicket(100, stageInitiated))
+	second := mustCreate(t, repo, newTicket(101, stageInitiated))
+
+	if first.ID == 0 || second.ID == 0 {
+		t.Fatalf("expected non zero IDs, got %d and %d", first.ID, second.ID)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("expected distinct IDs, both are %d", first.ID)
+	}
+
+	got, err := repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.ID != first.ID {
+		t.Errorf("ID = %d, want %d", got.ID, first.ID)
+	}
+	assertString(t, "NoSerial", got.NoSerial, "SN-100")
+	assertString(t, "StageProcess", got.StageProcess, stageInitiated)
+}
+
+func testCreateDuplicateTicketID(t *testing.T, repo store.TicketRepository) {
+	mustCreate(t, repo, newTicket(100, stageInitiated))
+
+	err := repo.Create(context.Background(), newTicket(100, stageInitiated))
+	if !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Create duplicate: got %v, want ErrConflict", err)
+	}
+}
+
+func testGetByIDUsesTicketID(t *testing.T, repo store.TicketRepository) {
+	mustCreate(t, repo, newTicket(500, stageInitiated))
+
+	got, err := repo.GetByID(context.Background(), 500)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.TicketID != 500 {
+		t.Errorf("TicketID = %d, want 500", got.TicketID)
+	}
+}
+
+func testGetByIDNotFound(t *testing.T, repo store.TicketRepository) {
+	_, err := repo.GetByID(context.Background(), 404)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetByID: got %v, want ErrNotFound", err)
+	}
+}
+
+func testGetByIDHidesZeroCapex(t *testing.T, repo store.TicketRepository) {
+	tk := newTicket(100, stageInitiated)
+	tk.Capex = valid("0")
+	mustCreate(t, repo, tk)
+
+	got, err := repo.GetByID(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Capex.Valid {
+		t.Errorf("Capex = %q, want NULL", got.Capex.String)
+	}
+}
+
+func testUpdate(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(100, stageInitiated))
+
+	got, err := repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+
+	got.OrderNumber = valid("PO-1")
+	got.Capex = valid("CX-9")
+	got.StageProcess = valid(stageProcurement)
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got, err = repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	assertString(t, "OrderNumber", got.OrderNumber, "PO-1")
+	assertString(t, "Capex", got.Capex, "CX-9")
+	assertString(t, "StageProcess", got.StageProcess, stageProcurement)
+	assertString(t, "NoSerial", got.NoSerial, "SN-100")
+}
+
+func testUpdateNotFound(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+
+	err := repo.Update(ctx, &store.AssetReplacementTicket{ID: 9999, TicketID: 9999})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Update missing: got %v, want ErrNotFound", err)
+	}
+
+	created := mustCreate(t, repo, newTicket(100, stageInitiated))
+	mustDelete(t, repo, 100)
+	err = repo.Update(ctx, created)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Update deleted: got %v, want ErrNotFound", err)
+	}
+}
+
+func testDeleteIsSoft(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(100, stageInitiated))
+
+	mustDelete(t, repo, 100)
+
+	if _, err := repo.GetByID(ctx, 100); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetByID after delete: got %v, want ErrNotFound", err)
+	}
+
+	// The row is still there: the ticket ID cannot be created again.
+	if err := repo.Create(ctx, newTicket(100, stageInitiated)); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Create after delete: got %v, want ErrConflict", err)
+	}
+}
+
+func testDeleteNotFound(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+
+	if err := repo.Delete(ctx, 404, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Delete missing: got %v, want ErrNotFound", err)
+	}
+
+	created := mustCreate(t, repo, newTicket(100, stageInitiated))
+	if err := repo.Delete(ctx, 100, created.Version); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, 100, created.Version+1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Delete twice: got %v, want ErrNotFound", err)
+	}
+}
+
+func testWritesBumpVersion(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	created := mustCreate(t, repo, newTicket(100, stageInitiated))
+	if created.Version != 1 {
+		t.Fatalf("Create: Version = %d, want 1", created.Version)
+	}
+
+	got, err := repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	got.Supplier = valid("ACME")
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got.Version != 2 {
+		t.Errorf("Update: Version = %d, want 2", got.Version)
+	}
+
+	if err := repo.Upsert(ctx, dto.TicketUpsertDTO{TicketID: 100, Capex: ptr("CX-1")}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := repo.UpsertMany(ctx, []dto.TicketUpsertDTO{{TicketID: 100, Capex: ptr("CX-2")}}); err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+
+	got, err = repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Version != 4 {
+		t.Errorf("after upserts: Version = %d, want 4", got.Version)
+	}
+}
+
+func testStaleVersionIsRejected(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(100, stageInitiated))
+
+	first, err := repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	second := *first
+
+	first.OrderNumber = valid("PO-1")
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	second.OrderNumber = valid("PO-2")
+	if err := repo.Update(ctx, &second); !errors.Is(err, store.ErrVersionMismatch) {
+		t.Fatalf("Update stale: got %v, want ErrVersionMismatch", err)
+	}
+	if err := repo.Delete(ctx, 100, second.Version); !errors.Is(err, store.ErrVersionMismatch) {
+		t.Fatalf("Delete stale: got %v, want ErrVersionMismatch", err)
+	}
+
+	got, err := repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	assertString(t, "OrderNumber", got.OrderNumber, "PO-1")
+	if got.Version != first.Version {
+		t.Errorf("Version = %d, want %d", got.Version, first.Version)
+	}
+}
+
+func testUpsertInserts(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+
+	err := repo.Upsert(ctx, dto.TicketUpsertDTO{
+		TicketID:    700,
+		NoSerial:    ptr("SN-700"),
+		OrderNumber: ptr("PO-700"),
+		Supplier:    ptr(""),
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, 700)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	assertString(t, "NoSerial", got.NoSerial, "SN-700")
+	assertString(t, "OrderNumber", got.OrderNumber, "PO-700")
+	assertNull(t, "Capex", got.Capex)
+	assertNull(t, "Supplier", got.Supplier)
+}
+
+func testUpsertMergesNonEmptyValues(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	tk := newTicket(100, stageInitiated)
+	tk.OrderNumber = valid("PO-OLD")
+	tk.Capex = valid("CX-OLD")
+	tk.Supplier = valid("ACME")
+	mustCreate(t, repo, tk)
+
+	err := repo.Upsert(ctx, dto.TicketUpsertDTO{
+		TicketID:      100,
+		OrderNumber:   ptr("PO-NEW"),
+		Capex:         nil,
+		InvoiceNumber: ptr("INV-1"),
+		Supplier:      ptr(""),
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	assertString(t, "OrderNumber", got.OrderNumber, "PO-NEW")
+	assertString(t, "Capex", got.Capex, "CX-OLD")
+	assertString(t, "InvoiceNumber", got.InvoiceNumber, "INV-1")
+	assertString(t, "Supplier", got.Supplier, "ACME")
+	assertString(t, "NoSerial", got.NoSerial, "SN-100")
+	assertString(t, "StageProcess", got.StageProcess, stageInitiated)
+}
+
+func testUpsertDoesNotRestoreDeleted(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(100, stageInitiated))
+	mustDelete(t, repo, 100)
+
+	if err := repo.Upsert(ctx, dto.TicketUpsertDTO{TicketID: 100, Capex: ptr("CX-1")}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	if _, err := repo.GetByID(ctx, 100); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetByID after upsert on deleted: got %v, want ErrNotFound", err)
+	}
+}
+
+func testExistsActiveOrderWithSerial(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+
+	active := newTicket(1, stageProcurement)
+	active.NoSerial = valid("SN-ACTIVE")
+	mustCreate(t, repo, active)
+
+	noStage := newTicket(2, "")
+	noStage.NoSerial = valid("SN-NOSTAGE")
+	mustCreate(t, repo, noStage)
+
+	completed := newTicket(3, stageCompleted)
+	completed.NoSerial = valid("SN-DONE")
+	mustCreate(t, repo, completed)
+
+	deleted := newTicket(4, stageInitiated)
+	deleted.NoSerial = valid("SN-DELETED")
+	mustCreate(t, repo, deleted)
+	mustDelete(t, repo, 4)
+
+	tests := []struct {
+		serial  string
+		exclude int64
+		want    bool
+	}{
+		{"SN-ACTIVE", 99, true},
+		{"SN-ACTIVE", 1, false},
+		{"SN-NOSTAGE", 99, true},
+		{"SN-DONE", 99, false},
+		{"SN-DELETED", 99, false},
+		{"SN-UNKNOWN", 99, false},
+	}
+
+	for _, tt := range tests {
+		got, err := repo.ExistsActiveOrderWithSerial(ctx, tt.serial, tt.exclude)
+		if err != nil {
+			t.Fatalf("ExistsActiveOrderWithSerial(%q, %d): %v", tt.serial, tt.exclude, err)
+		}
+		if got != tt.want {
+			t.Errorf("ExistsActiveOrderWithSerial(%q, %d) = %v, want %v", tt.serial, tt.exclude, got, tt.want)
+		}
+	}
+}
+
+// Deleted tickets are returned, marked, because Upsert still matches them.
+func testGetManyByIDSkipsMissingReturnsDeleted(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(1, stageInitiated))
+	mustCreate(t, repo, newTicket(2, stageProcurement))
+	mustCreate(t, repo, newTicket(3, stageInitiated))
+	mustDelete(t, repo, 3)
+
+	got, err := repo.GetManyByID(ctx, []int64{1, 2, 3, 99})
+	if err != nil {
+		t.Fatalf("GetManyByID: %v", err)
+	}
+	if len(got) != 3 || got[1] == nil || got[2] == nil || got[3] == nil {
+		t.Fatalf("GetManyByID returned %d tickets, want 1, 2 and 3", len(got))
+	}
+	assertString(t, "NoSerial", got[2].NoSerial, "SN-2")
+	if got[1].DeletedAt.Valid {
+		t.Errorf("ticket 1 DeletedAt = %q, want NULL", got[1].DeletedAt.String)
+	}
+	if !got[3].DeletedAt.Valid {
+		t.Errorf("deleted ticket 3 returned without DeletedAt")
+	}
+
+	empty, err := repo.GetManyByID(ctx, nil)
+	if err != nil {
+		t.Fatalf("GetManyByID(nil): %v", err)
+	}
+	if len(empty) != 0 {
+		t.Errorf("GetManyByID(nil) returned %d tickets", len(empty))
+	}
+}
+
+func testActiveSerialHolders(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+
+	active := newTicket(1, stageProcurement)
+	active.NoSerial = valid("SN-SHARED")
+	mustCreate(t, repo, active)
+
+	noStage := newTicket(2, "")
+	noStage.NoSerial = valid("SN-SHARED")
+	mustCreate(t, repo, noStage)
+
+	completed := newTicket(3, stageCompleted)
+	completed.NoSerial = valid("SN-DONE")
+	mustCreate(t, repo, completed)
+
+	deleted := newTicket(4, stageInitiated)
+	deleted.NoSerial = valid("SN-DELETED")
+	mustCreate(t, repo, deleted)
+	mustDelete(t, repo, 4)
+
+	got, err := repo.ActiveSerialHolders(ctx, []string{"SN-SHARED", "SN-DONE", "SN-DELETED", "SN-UNKNOWN"})
+	if err != nil {
+		t.Fatalf("ActiveSerialHolders: %v", err)
+	}
+	if len(got) != 1 || len(got["SN-SHARED"]) != 2 {
+		t.Fatalf("ActiveSerialHolders = %v, want only SN-SHARED held by 1 and 2", got)
+	}
+}
+
+func testUpsertManyAppliesRowsInOrder(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	tk := newTicket(1, stageInitiated)
+	tk.Supplier = valid("ACME")
+	mustCreate(t, repo, tk)
+
+	err := repo.UpsertMany(ctx, []dto.TicketUpsertDTO{
+		{TicketID: 1, OrderNumber: ptr("PO-1")},
+		{TicketID: 2, NoSerial: ptr("SN-NEW"), Supplier: ptr("")},
+		{TicketID: 1, OrderNumber: ptr("PO-2"), Supplier: nil},
+	})
+	if err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetByID(1): %v", err)
+	}
+	assertString(t, "OrderNumber", got.OrderNumber, "PO-2")
+	assertString(t, "Supplier", got.Supplier, "ACME")
+
+	inserted, err := repo.GetByID(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetByID(2): %v", err)
+	}
+	assertString(t, "NoSerial", inserted.NoSerial, "SN-NEW")
+	if inserted.Supplier.Valid {
+		t.Errorf("Supplier = %q, want NULL", inserted.Supplier.String)
+	}
+}
+
+func testGetByFiltersDefaultsToPendingStages(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(1, stageInitiated))
+	mustCreate(t, repo, newTicket(2, stageProcurement))
+	mustCreate(t, repo, newTicket(3, stageCompleted))
+	mustCreate(t, repo, newTicket(4, ""))
+	mustCreate(t, repo, newTicket(5, stageInitiated))
+	mustDelete(t, repo, 5)
+
+	tickets, total, err := repo.GetByFilters(ctx, store.TicketFilter{}, store.TicketPage{Limit: 50})
+	if err != nil {
+		t.Fatalf("GetByFilters: %v", err)
+	}
+	// Ticket 4 has no stage, which is the initial one.
+	if total != 3 {
+		t.Errorf("total = %d, want 3", total)
+	}
+	got := ticketIDs(tickets)
+	slices.Sort(got)
+	if !equalIDs(got, []int64{1, 2, 4}) {
+		t.Errorf("GetByFilters = %v, want [1 2 4]", got)
+	}
+}
+
+// A NULL STAGE_PROCESS is listed with workflow.Initial and only with it.
+func testGetByFiltersNullStageIsInitial(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(1, stageInitiated))
+	mustCreate(t, repo, newTicket(2, ""))
+	mustCreate(t, repo, newTicket(3, stageProcurement))
+
+	tests := []struct {
+		stages []workflow.Stage
+		want   []int64
+	}{
+		{[]workflow.Stage{workflow.Initial}, []int64{1, 2}},
+		{[]workflow.Stage{stageProcurement, workflow.Initial}, []int64{1, 2, 3}},
+		{[]workflow.Stage{stageProcurement}, []int64{3}},
+		{[]workflow.Stage{}, nil},
+	}
+
+	for _, tt := range tests {
+		filter := store.TicketFilter{Stages: tt.stages}
+		tickets, total, err := repo.GetByFilters(ctx, filter, store.TicketPage{Limit: 50})
+		if err != nil {
+			t.Fatalf("GetByFilters(%v): %v", tt.stages, err)
+		}
+		got := ticketIDs(tickets)
+		slices.Sort(got)
+		if total != len(tt.want) || !equalIDs(got, tt.want) {
+			t.Errorf("GetByFilters(%v) = %v (total %d), want %v", tt.stages, got, total, tt.want)
+		}
+
+		var each []int64
+		err = repo.ForEach(ctx, filter, func(tk *store.AssetReplacementTicket) error {
+			each = append(each, tk.TicketID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ForEach(%v): %v", tt.stages, err)
+		}
+		slices.Sort(each)
+		if !equalIDs(each, tt.want) {
+			t.Errorf("ForEach(%v) = %v, want %v", tt.stages, each, tt.want)
+		}
+	}
+}
+
+func testGetByFilters(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+
+	first := newTicket(1, stageInitiated)
+	first.CategoryID = sql.NullInt64{Int64: 7, Valid: true}
+	first.CenterDistID = sql.NullInt64{Int64: 30, Valid: true}
+	first.CenterDist = valid("Santiago")
+	first.Supplier = valid("ACME Chile")
+	first.InvoiceNumber = valid("F-100")
+	mustCreate(t, repo, first)
+
+	second := newTicket(2, stageProcurement)
+	second.Supplier = valid("Dell 100%")
+	second.Capex = valid("CX-9")
+	mustCreate(t, repo, second)
+
+	mustCreate(t, repo, newTicket(3, stageCompleted))
+
+	deleted := newTicket(4, stageInitiated)
+	deleted.Supplier = valid("ACME")
+	mustCreate(t, repo, deleted)
+	mustDelete(t, repo, 4)
+
+	yes, no := true, false
+	hourAgo, inAnHour := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	ptr := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name   string
+		filter store.TicketFilter
+		want   []int64
+	}{
+		{"stages", store.TicketFilter{Stages: []workflow.Stage{stageProcurement, stageCompleted}}, []int64{2, 3}},
+		{"no stages", store.TicketFilter{Stages: []workflow.Stage{}}, nil},
+		{"category", store.TicketFilter{CategoryID: ptr(7)}, []int64{1}},
+		{"center id", store.TicketFilter{CenterDistID: ptr(30)}, []int64{1}},
+		{"center", store.TicketFilter{CenterDist: "Santiago"}, []int64{1}},
+		{"supplier ignores case", store.TicketFilter{Supplier: "acme"}, []int64{1}},
+		{"supplier wildcard is literal", store.TicketFilter{Supplier: "%"}, []int64{2}},
+		{"capex", store.TicketFilter{Capex: "CX-9"}, []int64{2}},
+		{"with invoice", store.TicketFilter{HasInvoice: &yes}, []int64{1}},
+		{"without invoice", store.TicketFilter{HasInvoice: &no}, []int64{2}},
+		{"serial", store.TicketFilter{NoSerial: "SN-2"}, []int64{2}},
+		{"created in range", store.TicketFilter{CreatedFrom: &hourAgo, CreatedTo: &inAnHour}, []int64{1, 2}},
+		{"created before", store.TicketFilter{CreatedTo: &hourAgo}, nil},
+		{"updated after", store.TicketFilter{UpdatedFrom: &inAnHour}, nil},
+		{"q", store.TicketFilter{Q: "f-1"}, []int64{1}},
+		{"q ticket id", store.TicketFilter{Q: "2", Stages: workflow.Stages()}, []int64{2}},
+		{"combined", store.TicketFilter{Supplier: "acme", HasInvoice: &no}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tickets, total, err := repo.GetByFilters(ctx, tt.filter, store.TicketPage{Limit: 50})
+			if err != nil {
+				t.Fatalf("GetByFilters: %v", err)
+			}
+
+			got := make(map[int64]bool)
+			for _, tk := range tickets {
+				got[tk.TicketID] = true
+			}
+			if total != len(tt.want) || len(got) != len(tt.want) {
+				t.Fatalf("got %v (total %d), want %v", got, total, tt.want)
+			}
+			for _, id := range tt.want {
+				if !got[id] {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func testGetByFiltersSorts(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	for i, supplier := range []string{"Dell", "", "ACME", "Dell"} {
+		tk := newTicket(int64(i+1), stageInitiated)
+		if supplier != "" {
+			tk.Supplier = valid(supplier)
+		}
+		mustCreate(t, repo, tk)
+	}
+
+	sort, err := store.ParseSort("supplier,-ticket_id")
+	if err != nil {
+		t.Fatalf("ParseSort: %v", err)
+	}
+	tickets, _, err := repo.GetByFilters(ctx, store.TicketFilter{}, store.TicketPage{Sort: sort, Limit: 50})
+	if err != nil {
+		t.Fatalf("GetByFilters: %v", err)
+	}
+
+	// NULL suppliers sort first.
+	want := []int64{2, 3, 4, 1}
+	if got := ticketIDs(tickets); !equalIDs(got, want) {
+		t.Errorf("sorted by supplier,-ticket_id = %v, want %v", got, want)
+	}
+}
+
+// testGetByFiltersCursor walks a listing forward and back with cursors, with
+// a ticket inserted in the middle of the walk.
+func testGetByFiltersCursor(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	const n = 7
+	for i := int64(1); i <= n; i++ {
+		mustCreate(t, repo, newTicket(i, stageInitiated))
+	}
+
+	sort, _ := store.ParseSort("ticket_id")
+	page := store.TicketPage{Sort: sort, Limit: 3}
+
+	first, _, err := repo.GetByFilters(ctx, store.TicketFilter{}, page)
+	if err != nil {
+		t.Fatalf("GetByFilters: %v", err)
+	}
+	if got := ticketIDs(first); !equalIDs(got, []int64{1, 2, 3}) {
+		t.Fatalf("first page = %v", got)
+	}
+
+	// A ticket that sorts before the cursor does not shift the next page.
+	mustCreate(t, repo, newTicket(0, stageInitiated))
+
+	next := page
+	next.Cursor = page.CursorFor(&first[len(first)-1], false)
+	second, _, err := repo.GetByFilters(ctx, store.TicketFilter{}, next)
+	if err != nil {
+		t.Fatalf("GetByFilters(next): %v", err)
+	}
+	if got := ticketIDs(second); !equalIDs(got, []int64{4, 5, 6}) {
+		t.Fatalf("second page = %v, want [4 5 6]", got)
+	}
+
+	prev := page
+	prev.Cursor = page.CursorFor(&second[0], true)
+	back, _, err := repo.GetByFilters(ctx, store.TicketFilter{}, prev)
+	if err != nil {
+		t.Fatalf("GetByFilters(prev): %v", err)
+	}
+	if got := ticketIDs(back); !equalIDs(got, []int64{1, 2, 3}) {
+		t.Fatalf("previous page = %v, want [1 2 3]", got)
+	}
+
+	encoded := next.Cursor.Encode()
+	decoded, err := store.DecodeCursor(encoded, sort)
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if decoded.ID != next.Cursor.ID {
+		t.Errorf("decoded cursor ID = %d, want %d", decoded.ID, next.Cursor.ID)
+	}
+	if _, err := store.DecodeCursor(encoded, nil); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Errorf("DecodeCursor with another sort = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func ticketIDs(tickets []store.AssetReplacementTicket) []int64 {
+	ids := make([]int64, len(tickets))
+	for i, tk := range tickets {
+		ids[i] = tk.TicketID
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testForEachMatchesGetByFilters(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(1, stageInitiated))
+	mustCreate(t, repo, newTicket(2, stageProcurement))
+	mustCreate(t, repo, newTicket(3, stageCompleted))
+	mustCreate(t, repo, newTicket(4, stageInitiated))
+	mustDelete(t, repo, 4)
+
+	listed, _, err := repo.GetByFilters(ctx, store.TicketFilter{}, store.TicketPage{Limit: 50})
+	if err != nil {
+		t.Fatalf("GetByFilters: %v", err)
+	}
+
+	var streamed []int64
+	err = repo.ForEach(ctx, store.TicketFilter{}, func(tk *store.AssetReplacementTicket) error {
+		streamed = append(streamed, tk.TicketID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+	if len(streamed) != len(listed) {
+		t.Fatalf("ForEach streamed %v, GetByFilters listed %d tickets", streamed, len(listed))
+	}
+	for i, tk := range listed {
+		if streamed[i] != tk.TicketID {
+			t.Errorf("ticket %d = %d, want %d", i, streamed[i], tk.TicketID)
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.ForEach(ctx, store.TicketFilter{}, func(*store.AssetReplacementTicket) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ForEach after fn error = %v with %d calls, want stop after 1", err, calls)
+	}
+}
+
+func testGetByFiltersPagination(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	const n = 7
+	for i := int64(1); i <= n; i++ {
+		mustCreate(t, repo, newTicket(i, stageInitiated))
+	}
+
+	seen := make(map[int64]bool)
+	for offset := 0; offset < n; offset += 3 {
+		page, total, err := repo.GetByFilters(ctx, store.TicketFilter{}, store.TicketPage{Offset: offset, Limit: 3})
+		if err != nil {
+			t.Fatalf("GetByFilters(offset=%d): %v", offset, err)
+		}
+		if total != n {
+			t.Errorf("GetByFilters(offset=%d) total = %d, want %d", offset, total, n)
+		}
+
+		want := min(3, n-offset)
+		if len(page) != want {
+			t.Errorf("GetByFilters(offset=%d) returned %d tickets, want %d", offset, len(page), want)
+		}
+		for _, tk := range page {
+			if seen[tk.TicketID] {
+				t.Errorf("ticket %d returned on more than one page", tk.TicketID)
+			}
+			seen[tk.TicketID] = true
+		}
+	}
+	if len(seen) != n {
+		t.Errorf("pages covered %d tickets, want %d", len(seen), n)
+	}
+
+	page, _, err := repo.GetByFilters(ctx, store.TicketFilter{}, store.TicketPage{Offset: n, Limit: 3})
+	if err != nil {
+		t.Fatalf("GetByFilters past the end: %v", err)
+	}
+	if len(page) != 0 {
+		t.Errorf("GetByFilters past the end returned %d tickets", len(page))
+	}
+}
+
+func testGetBasicTicketsSkipsDeleted(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(1, stageInitiated))
+	mustCreate(t, repo, newTicket(2, stageCompleted))
+	mustCreate(t, repo, newTicket(3, stageInitiated))
+	mustDelete(t, repo, 3)
+
+	tickets, err := repo.GetBasicTickets(ctx)
+	if err != nil {
+		t.Fatalf("GetBasicTickets: %v", err)
+	}
+	if len(tickets) != 2 {
+		t.Fatalf("GetBasicTickets returned %d tickets, want 2", len(tickets))
+	}
+	for _, tk := range tickets {
+		if tk.TicketID == 3 {
+			t.Errorf("deleted ticket returned by GetBasicTickets")
+		}
+	}
+}
+
+// Every read returns the version and timestamps, so a client can build
+// If-Match from a list item as from GetByID.
+func testReadsReturnVersionAndTimestamps(t *testing.T, repo store.TicketRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, newTicket(100, stageInitiated))
+	got, err := repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	got.Supplier = valid("ACME")
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	check := func(read string, tk *store.AssetReplacementTicket) {
+		t.Helper()
+		if tk.Version != 2 {
+			t.Errorf("%s: Version = %d, want 2", read, tk.Version)
+		}
+		if tk.CreatedAt.IsZero() || tk.UpdatedAt.IsZero() || tk.UpdatedAt.Before(tk.CreatedAt) {
+			t.Errorf("%s: CreatedAt = %v, UpdatedAt = %v", read, tk.CreatedAt, tk.UpdatedAt)
+		}
+	}
+
+	byID, err := repo.GetByID(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	check("GetByID", byID)
+
+	listed, _, err := repo.GetByFilters(ctx, store.TicketFilter{}, store.TicketPage{Limit: 50})
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("GetByFilters = %d tickets, %v", len(listed), err)
+	}
+	check("GetByFilters", &listed[0])
+	if !listed[0].CreatedAt.Equal(byID.CreatedAt) || !listed[0].UpdatedAt.Equal(byID.UpdatedAt) {
+		t.Errorf("GetByFilters timestamps differ from GetByID: %+v, %+v", listed[0], byID)
+	}
+
+	err = repo.ForEach(ctx, store.TicketFilter{}, func(tk *store.AssetReplacementTicket) error {
+		check("ForEach", tk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+
+	many, err := repo.GetManyByID(ctx, []int64{100})
+	if err != nil || many[100] == nil {
+		t.Fatalf("GetManyByID = %v, %v", many, err)
+	}
+	check("GetManyByID", many[100])
+}
+
+func newTicket(ticketID int64, stage string) *store.AssetReplacementTicket {
+	t := &store.AssetReplacementTicket{
+		TicketID: ticketID,
+		NoSerial: valid("SN-" + strconv.FormatInt(ticketID, 10)),
+	}
+	if stage != "" {
+		t.StageProcess = valid(stage)
+	}
+	return t
+}
+
+func mustCreate(t *testing.T, repo store.TicketRepository, tk *store.AssetReplacementTicket) *store.AssetReplacementTicket {
+	t.Helper()
+	if err := repo.Create(context.Background(), tk); err != nil {
+		t.Fatalf("Create(%d): %v", tk.TicketID, err)
+	}
+	return tk
+}
+
+// mustDelete deletes the ticket at its current version.
+func mustDelete(t *testing.T, repo store.TicketRepository, ticketID int64) {
+	t.Helper()
+
+	got, err := repo.GetByID(context.Background(), ticketID)
+	if err != nil {
+		t.Fatalf("GetByID(%d): %v", ticketID, err)
+	}
+	if err := repo.Delete(context.Background(), ticketID, got.Version); err != nil {
+		t.Fatalf("Delete(%d): %v", ticketID, err)
+	}
+}
+
+func assertString(t *testing.T, field string, got sql.NullString, want string) {
+	t.Helper()
+	if !got.Valid || got.String != want {
+		t.Errorf("%s = %+v, want %q", field, got, want)
+	}
+}
+
+func assertNull(t *testing.T, field string, got sql.NullString) {
+	t.Helper()
+	if got.Valid {
+		t.Errorf("%s = %q, want NULL", field, got.String)
+	}
+}
+
+func valid(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: true}
+}
+
+func ptr(s string) *string {
+	return &s
+}
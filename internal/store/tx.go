@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Transactor runs fn in a transaction carried by the context it is given.
+// Repository methods called with that context take part in the transaction,
+// so services can compose them. The transaction is rolled back when fn
+// returns an error and committed otherwise. Nested calls join the outer
+// transaction.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// querier is the part of *sql.DB and *sql.Tx the stores use.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction in ctx, or db outside of one.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+type sqlTransactor struct {
+	db *sql.DB
+}
+
+func (t *sqlTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	return withTx(t.db, ctx, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+type memoryTxKey struct{}
+
+// memoryTransactor restores the memory stores to their state before fn when
+// it fails. Transactions are serialised with each other but writes made
+// outside of one are not isolated from them, which is enough for development
+// and tests.
+type memoryTransactor struct {
+	mu      sync.Mutex
+	tickets *MemoryTicketStore
+	audit   *MemoryAuditStore
+}
+
+func (t *memoryTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) != nil {
+		return fn(ctx)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tickets := t.tickets.snapshot()
+	entries := t.audit.snapshot()
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, true)); err != nil {
+		t.tickets.restore(tickets)
+		t.audit.restore(entries)
+		return err
+	}
+	return nil
+}
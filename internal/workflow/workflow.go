@@ -0,0 +1,183 @@
+package workflow
+
+import (
+	"strings"
+
+	"github.com/MislavaGuzman/AssetsReplacementManagementAPI/internal/apierror"
+)
+
+var (
+	ErrUnknownStage      = apierror.New(apierror.CodeUnknownStage, "unknown stage")
+	ErrIllegalTransition = apierror.New(apierror.CodeIllegalTransition, "illegal stage transition")
+	ErrGuardFailed       = apierror.New(apierror.CodeTransitionGuardFailed, "transition guard failed")
+)
+
+// Stage is the value stored in STAGE_PROCESS.
+type Stage string
+
+const (
+	StageRequestInitiated Stage = "Request Initiated"
+	StageProcurement      Stage = "Procurement Phase"
+	StageInvoiced         Stage = "Invoiced"
+	StageCompleted        Stage = "COMPLETED"
+)
+
+// Initial is the stage of a new ticket and of tickets whose STAGE_PROCESS is NULL.
+const Initial = StageRequestInitiated
+
+var stages = []Stage{
+	StageRequestInitiated,
+	StageProcurement,
+	StageInvoiced,
+	StageCompleted,
+}
+
+// Stages returns every declared stage in workflow order.
+func Stages() []Stage {
+	return append([]Stage(nil), stages...)
+}
+
+// PendingStages are the stages listed by the ticket board.
+func PendingStages() []Stage {
+	return []Stage{StageRequestInitiated, StageProcurement}
+}
+
+// FinalStages are the stages in which a ticket no longer holds its serial.
+func FinalStages() []Stage {
+	return []Stage{StageCompleted}
+}
+
+func (s Stage) IsPending() bool {
+	return contains(PendingStages(), s)
+}
+
+func (s Stage) IsFinal() bool {
+	return contains(FinalStages(), s)
+}
+
+func (s Stage) String() string {
+	return string(s)
+}
+
+// Parse resolves a stage name, ignoring case and surrounding spaces.
+func Parse(name string) (Stage, error) {
+	name = strings.TrimSpace(name)
+	for _, s := range stages {
+		if strings.EqualFold(string(s), name) {
+			return s, nil
+		}
+	}
+	return "", apierror.Wrapf(ErrUnknownStage, "%q", name)
+}
+
+// Ticket is the view of a ticket that guards are evaluated against.
+type Ticket struct {
+	NoSerial      string
+	OrderNumber   string
+	Capex         string
+	InvoiceNumber string
+}
+
+// Guard checks that a ticket satisfies a condition to enter a stage. Name
+// describes the condition and is reported when the guard fails.
+type Guard struct {
+	Name  string
+	Check func(t Ticket) bool
+}
+
+// Require returns a guard that fails when the value returned by field is blank.
+func Require(name string, field func(t Ticket) string) Guard {
+	return Guard{
+		Name: name + " is required",
+		Check: func(t Ticket) bool {
+			return strings.TrimSpace(field(t)) != ""
+		},
+	}
+}
+
+type Transition struct {
+	From   Stage
+	To     Stage
+	Guards []Guard
+}
+
+type Machine struct {
+	transitions map[Stage]map[Stage][]Guard
+}
+
+func NewMachine(transitions ...Transition) *Machine {
+	m := &Machine{transitions: make(map[Stage]map[Stage][]Guard)}
+	for _, t := range transitions {
+		if m.transitions[t.From] == nil {
+			m.transitions[t.From] = make(map[Stage][]Guard)
+		}
+		m.transitions[t.From][t.To] = t.Guards
+	}
+	return m
+}
+
+// Default is the replacement ticket workflow:
+//
+//	Request Initiated <-> Procurement Phase -> Invoiced -> COMPLETED
+var Default = NewMachine(
+	Transition{From: StageRequestInitiated, To: StageProcurement},
+	Transition{From: StageProcurement, To: StageRequestInitiated},
+	Transition{
+		From: StageProcurement,
+		To:   StageInvoiced,
+		Guards: []Guard{
+			Require("order_number", func(t Ticket) string { return t.OrderNumber }),
+			Require("invoice_number", func(t Ticket) string { return t.InvoiceNumber }),
+		},
+	},
+	Transition{From: StageInvoiced, To: StageCompleted},
+)
+
+// Next returns the stages reachable from from, ignoring guards.
+func (m *Machine) Next(from Stage) []Stage {
+	var next []Stage
+	for _, s := range stages {
+		if _, ok := m.transitions[from][s]; ok {
+			next = append(next, s)
+		}
+	}
+	return next
+}
+
+// Check reports whether t may move from one stage to another. The error wraps
+// ErrIllegalTransition when the move is not declared and ErrGuardFailed when a
+// guard rejects the ticket.
+func (m *Machine) Check(t Ticket, from, to Stage) error {
+	if !contains(stages, from) {
+		return apierror.Wrapf(ErrUnknownStage, "%q", from)
+	}
+	if !contains(stages, to) {
+		return apierror.Wrapf(ErrUnknownStage, "%q", to)
+	}
+
+	guards, ok := m.transitions[from][to]
+	if !ok {
+		return apierror.Wrapf(ErrIllegalTransition, "%s -> %s", from, to)
+	}
+
+	var failed []string
+	for _, g := range guards {
+		if !g.Check(t) {
+			failed = append(failed, g.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return apierror.Wrapf(ErrGuardFailed, "%s -> %s: %s", from, to, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+func contains(list []Stage, s Stage) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package workflow
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Stage
+	}{
+		{"Request Initiated", StageRequestInitiated},
+		{"request initiated", StageRequestInitiated},
+		{"  PROCUREMENT PHASE ", StageProcurement},
+		{"invoiced", StageInvoiced},
+		{"Completed", StageCompleted},
+	}
+	for _, tt := range tests {
+		if got, err := Parse(tt.name); err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	for _, name := range []string{"", "Done", "Procurement"} {
+		if got, err := Parse(name); !errors.Is(err, ErrUnknownStage) {
+			t.Errorf("Parse(%q) = %q, %v, want ErrUnknownStage", name, got, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	complete := Ticket{OrderNumber: "PO-1", InvoiceNumber: "INV-1"}
+
+	tests := []struct {
+		name     string
+		ticket   Ticket
+		from, to Stage
+		want     error
+	}{
+		{"forward", Ticket{}, StageRequestInitiated, StageProcurement, nil},
+		{"back to initiated", Ticket{}, StageProcurement, StageRequestInitiated, nil},
+		{"guards pass", complete, StageProcurement, StageInvoiced, nil},
+		{"complete", Ticket{}, StageInvoiced, StageCompleted, nil},
+
+		{"skip procurement", complete, StageRequestInitiated, StageCompleted, ErrIllegalTransition},
+		{"skip invoiced", complete, StageProcurement, StageCompleted, ErrIllegalTransition},
+		{"reopen", complete, StageCompleted, StageRequestInitiated, ErrIllegalTransition},
+		{"same stage", Ticket{}, StageProcurement, StageProcurement, ErrIllegalTransition},
+
+		{"unknown from", Ticket{}, "Draft", StageProcurement, ErrUnknownStage},
+		{"unknown to", Ticket{}, StageProcurement, "Draft", ErrUnknownStage},
+		// Stages are stored canonically; Check does not fold case.
+		{"not canonical", Ticket{}, "request initiated", StageProcurement, ErrUnknownStage},
+
+		{"blank invoice", Ticket{OrderNumber: "PO-1", InvoiceNumber: "  "}, StageProcurement, StageInvoiced, ErrGuardFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Default.Check(tt.ticket, tt.from, tt.to)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Check = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Check = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// Every failed guard is listed, not only the first one.
+func TestCheckListsFailedGuards(t *testing.T) {
+	tests := []struct {
+		ticket Ticket
+		want   string
+	}{
+		{Ticket{}, "transition guard failed: Procurement Phase -> Invoiced: order_number is required, invoice_number is required"},
+		{Ticket{OrderNumber: "PO-1"}, "transition guard failed: Procurement Phase -> Invoiced: invoice_number is required"},
+	}
+	for _, tt := range tests {
+		err := Default.Check(tt.ticket, StageProcurement, StageInvoiced)
+		if !errors.Is(err, ErrGuardFailed) || err.Error() != tt.want {
+			t.Errorf("Check(%+v) = %v, want %q", tt.ticket, err, tt.want)
+		}
+	}
+
+	// Custom guards are reported by name too.
+	m := NewMachine(Transition{From: StageInvoiced, To: StageCompleted, Guards: []Guard{
+		{Name: "capex approved", Check: func(t Ticket) bool { return t.Capex != "" }},
+		{Name: "serial recorded", Check: func(t Ticket) bool { return t.NoSerial != "" }},
+	}})
+	want := "transition guard failed: Invoiced -> COMPLETED: capex approved, serial recorded"
+	if err := m.Check(Ticket{}, StageInvoiced, StageCompleted); err == nil || err.Error() != want {
+		t.Errorf("Check = %v, want %q", err, want)
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		from Stage
+		want []Stage
+	}{
+		{StageRequestInitiated, []Stage{StageProcurement}},
+		{StageProcurement, []Stage{StageRequestInitiated, StageInvoiced}},
+		{StageInvoiced, []Stage{StageCompleted}},
+		{StageCompleted, nil},
+	}
+	for _, tt := range tests {
+		if got := Default.Next(tt.from); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.from, got, tt.want)
+		}
+	}
+}